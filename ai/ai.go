@@ -0,0 +1,133 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Supported providers
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderOllama    = "ollama"
+)
+
+// Message is a single chat message
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Request describes a completion request
+type Request struct {
+	Messages []Message
+	// Schema is the JSON schema the reply must follow, if any
+	Schema json.RawMessage
+}
+
+// Usage holds token accounting and the estimated cost of a completion
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	CostUSD          float64
+}
+
+// Response holds a completion result
+type Response struct {
+	Content string
+	Model   string
+	Usage   Usage
+	Latency time.Duration
+}
+
+// Client is implemented by AI backends
+type Client interface {
+	Complete(ctx context.Context, req Request) (Response, error)
+}
+
+// Pricing is the price of a model in USD per million tokens
+type Pricing struct {
+	Prompt     float64
+	Completion float64
+}
+
+// Cost estimates the price of the given token counts
+func (p Pricing) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.Prompt + float64(completionTokens)*p.Completion) / 1e6
+}
+
+// Options configures a backend client
+type Options struct {
+	Provider string
+	BaseURL  string
+	Model    string
+	APIKey   string
+	// Timeout bounds a whole completion; zero uses the provider default
+	Timeout time.Duration
+	// Stream requests a streamed response, which keeps long generations
+	// from hitting idle timeouts on proxies
+	Stream    bool
+	MaxTokens int
+	Pricing   Pricing
+	Transport http.RoundTripper
+}
+
+// DefaultTimeout returns the completion timeout used for a provider when
+// none is configured. Local models get more time since they are usually slower.
+func DefaultTimeout(provider string) time.Duration {
+	switch provider {
+	case ProviderAnthropic:
+		return 180 * time.Second
+	case ProviderOllama:
+		return 300 * time.Second
+	default:
+		return 120 * time.Second
+	}
+}
+
+// NewClient creates a client for the configured provider
+func NewClient(opts Options) (Client, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout(opts.Provider)
+	}
+	switch opts.Provider {
+	case "", ProviderOpenAI:
+		return NewOpenAIClient(opts), nil
+	case ProviderAnthropic:
+		return NewAnthropicClient(opts), nil
+	case ProviderOllama:
+		return NewOllamaClient(opts), nil
+	}
+	return nil, fmt.Errorf("unsupported ai provider: %s", opts.Provider)
+}
+
+// newHTTPClient creates the resty client shared by the provider adapters
+func newHTTPClient(opts Options) *resty.Client {
+	httpClient := resty.New().
+		SetTimeout(opts.Timeout).
+		SetRetryCount(2).
+		SetRetryWaitTime(2 * time.Second)
+	if opts.Transport != nil {
+		httpClient.SetTransport(opts.Transport)
+	}
+	return httpClient
+}
+
+// errorBody returns the start of the body of a failed streamed response
+func errorBody(body io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(body, 4096))
+	return strings.TrimSpace(string(data))
+}
+
+// drain closes a streamed response body after reading what is left of it
+func drain(body io.ReadCloser) {
+	io.Copy(io.Discard, body)
+	body.Close()
+}
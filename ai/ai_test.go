@@ -0,0 +1,101 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeProvider serves the canned reply of handle and records the last
+// request body and headers
+type fakeProvider struct {
+	t      *testing.T
+	path   string
+	body   map[string]any
+	header http.Header
+	handle func(w http.ResponseWriter)
+}
+
+func (f *fakeProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != f.path {
+		f.t.Errorf("request %s %s, want POST %s", r.Method, r.URL.Path, f.path)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	data, _ := io.ReadAll(r.Body)
+	f.body = nil
+	if err := json.Unmarshal(data, &f.body); err != nil {
+		f.t.Errorf("request body is not JSON: %v: %s", err, data)
+	}
+	f.header = r.Header.Clone()
+	f.handle(w)
+}
+
+// serve starts a fake provider answering at path and returns its base URL
+func serve(t *testing.T, path string, handle func(w http.ResponseWriter)) (*fakeProvider, string) {
+	t.Helper()
+	f := &fakeProvider{t: t, path: path, handle: handle}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv.URL
+}
+
+// reply writes body with the given status and content type
+func reply(status int, contentType, body string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}
+}
+
+// testPricing is $2 per million prompt tokens and $8 per million
+// completion tokens
+var testPricing = Pricing{Prompt: 2, Completion: 8}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-12
+}
+
+func TestPricingCost(t *testing.T) {
+	tests := []struct {
+		pricing            Pricing
+		prompt, completion int
+		want               float64
+	}{
+		{testPricing, 0, 0, 0},
+		{testPricing, 1_000_000, 0, 2},
+		{testPricing, 0, 1_000_000, 8},
+		{testPricing, 1200, 300, 0.0024 + 0.0024},
+		{Pricing{}, 5000, 5000, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.pricing.Cost(tt.prompt, tt.completion); !closeTo(got, tt.want) {
+			t.Errorf("%+v.Cost(%d, %d) = %v, want %v", tt.pricing, tt.prompt, tt.completion, got, tt.want)
+		}
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	for provider, want := range map[string]any{
+		"":                &OpenAIClient{},
+		ProviderOpenAI:    &OpenAIClient{},
+		ProviderAnthropic: &AnthropicClient{},
+		ProviderOllama:    &OllamaClient{},
+	} {
+		c, err := NewClient(Options{Provider: provider})
+		if err != nil {
+			t.Fatalf("NewClient(%q): %v", provider, err)
+		}
+		if got, want := fmt.Sprintf("%T", c), fmt.Sprintf("%T", want); got != want {
+			t.Errorf("NewClient(%q) = %s, want %s", provider, got, want)
+		}
+	}
+	if _, err := NewClient(Options{Provider: "gemini"}); err == nil {
+		t.Error("NewClient accepted an unknown provider")
+	}
+}
@@ -0,0 +1,169 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	anthropicVersion          = "2023-06-01"
+	anthropicDefaultMaxTokens = 4096
+)
+
+// AnthropicClient talks to the Anthropic Messages API
+type AnthropicClient struct {
+	httpClient *resty.Client
+	endpoint   string
+	opts       Options
+}
+
+// NewAnthropicClient creates a client for the Messages API under
+// opts.BaseURL (e.g. https://api.anthropic.com)
+func NewAnthropicClient(opts Options) *AnthropicClient {
+	endpoint := strings.TrimRight(opts.BaseURL, "/")
+	if endpoint == "" {
+		endpoint = "https://api.anthropic.com"
+	}
+	if !strings.HasSuffix(endpoint, "/v1/messages") {
+		endpoint += "/v1/messages"
+	}
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = anthropicDefaultMaxTokens
+	}
+	return &AnthropicClient{
+		httpClient: newHTTPClient(opts),
+		endpoint:   endpoint,
+		opts:       opts,
+	}
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// Complete sends the conversation to the Messages API. System messages are
+// moved to the top-level system field as the API requires; the JSON schema
+// is enforced through the prompt and the caller's validation.
+func (c *AnthropicClient) Complete(ctx context.Context, req Request) (Response, error) {
+	start := time.Now()
+	var system []string
+	messages := make([]Message, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+			continue
+		}
+		messages = append(messages, m)
+	}
+	body := map[string]interface{}{
+		"model":      c.opts.Model,
+		"max_tokens": c.opts.MaxTokens,
+		"messages":   messages,
+	}
+	if len(system) > 0 {
+		body["system"] = strings.Join(system, "\n\n")
+	}
+	if c.opts.Stream {
+		body["stream"] = true
+	}
+
+	resp, err := c.httpClient.R().
+		SetContext(ctx).
+		SetHeader("x-api-key", c.opts.APIKey).
+		SetHeader("anthropic-version", anthropicVersion).
+		SetBody(body).
+		SetDoNotParseResponse(c.opts.Stream).
+		Post(c.endpoint)
+	if err != nil {
+		return Response{}, fmt.Errorf("messages request failed: %w", err)
+	}
+
+	var out Response
+	var usage anthropicUsage
+	if c.opts.Stream {
+		defer drain(resp.RawBody())
+		if resp.IsError() {
+			return Response{}, fmt.Errorf("messages returned %d: %s", resp.StatusCode(), errorBody(resp.RawBody()))
+		}
+		var sb strings.Builder
+		err = readSSE(resp.RawBody(), func(event, data string) error {
+			var ev struct {
+				Type    string `json:"type"`
+				Message struct {
+					Model string         `json:"model"`
+					Usage anthropicUsage `json:"usage"`
+				} `json:"message"`
+				Delta struct {
+					Type string `json:"type"`
+					Text string `json:"text"`
+				} `json:"delta"`
+				Usage *anthropicUsage `json:"usage"`
+				Error struct {
+					Type    string `json:"type"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal([]byte(data), &ev); err != nil {
+				return fmt.Errorf("unmarshal messages event failed: %w", err)
+			}
+			switch ev.Type {
+			case "message_start":
+				out.Model = ev.Message.Model
+				usage.InputTokens = ev.Message.Usage.InputTokens
+			case "content_block_delta":
+				if ev.Delta.Type == "text_delta" {
+					sb.WriteString(ev.Delta.Text)
+				}
+			case "message_delta":
+				if ev.Usage != nil {
+					usage.OutputTokens = ev.Usage.OutputTokens
+				}
+			case "error":
+				return fmt.Errorf("messages stream error: %s: %s", ev.Error.Type, ev.Error.Message)
+			}
+			return nil
+		})
+		if err != nil {
+			return Response{}, fmt.Errorf("read messages stream failed: %w", err)
+		}
+		out.Content = sb.String()
+	} else {
+		if resp.IsError() {
+			return Response{}, fmt.Errorf("messages returned %d: %s", resp.StatusCode(), resp.String())
+		}
+		var result struct {
+			Model   string         `json:"model"`
+			Usage   anthropicUsage `json:"usage"`
+			Content []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+		}
+		if err := json.Unmarshal(resp.Body(), &result); err != nil {
+			return Response{}, fmt.Errorf("unmarshal messages response failed: %w", err)
+		}
+		var sb strings.Builder
+		for _, block := range result.Content {
+			if block.Type == "text" {
+				sb.WriteString(block.Text)
+			}
+		}
+		out.Content = sb.String()
+		out.Model = result.Model
+		usage = result.Usage
+	}
+
+	out.Usage = Usage{
+		PromptTokens:     usage.InputTokens,
+		CompletionTokens: usage.OutputTokens,
+		CostUSD:          c.opts.Pricing.Cost(usage.InputTokens, usage.OutputTokens),
+	}
+	out.Latency = time.Since(start)
+	return out, nil
+}
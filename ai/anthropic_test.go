@@ -0,0 +1,119 @@
+package ai
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAnthropicComplete(t *testing.T) {
+	fake, base := serve(t, "/v1/messages", reply(http.StatusOK, "application/json", `{
+		"model": "claude-sonnet-4-5",
+		"content": [{"type": "text", "text": "{\"trend\":"}, {"type": "text", "text": "\"up\"}"}],
+		"usage": {"input_tokens": 1200, "output_tokens": 300}
+	}`))
+	c := NewAnthropicClient(Options{BaseURL: base, Model: "claude-sonnet-4-5", APIKey: "key", Pricing: testPricing})
+	resp, err := c.Complete(context.Background(), Request{Messages: []Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "system", Content: "answer in JSON"},
+		{Role: "user", Content: "BTC?"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != `{"trend":"up"}` || resp.Model != "claude-sonnet-4-5" {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp.Usage.PromptTokens != 1200 || resp.Usage.CompletionTokens != 300 || !closeTo(resp.Usage.CostUSD, 0.0048) {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+
+	if fake.header.Get("X-Api-Key") != "key" || fake.header.Get("Anthropic-Version") != anthropicVersion {
+		t.Errorf("headers = %v", fake.header)
+	}
+	if fake.body["system"] != "be brief\n\nanswer in JSON" {
+		t.Errorf("system = %q", fake.body["system"])
+	}
+	if messages, _ := fake.body["messages"].([]any); len(messages) != 1 {
+		t.Errorf("system messages left in messages: %v", fake.body["messages"])
+	}
+	if fake.body["max_tokens"] != float64(anthropicDefaultMaxTokens) {
+		t.Errorf("max_tokens = %v", fake.body["max_tokens"])
+	}
+}
+
+func TestAnthropicCompleteStream(t *testing.T) {
+	stream := strings.Join([]string{
+		"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"model\":\"claude-sonnet-4-5\",\"usage\":{\"input_tokens\":1200,\"output_tokens\":1}}}",
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}",
+		"event: ping\ndata: {\"type\":\"ping\"}",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"{\\\"trend\\\":\"}}",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"\\\"up\\\"}\"}}",
+		"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}",
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":300}}",
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}",
+	}, "\n\n") + "\n\n"
+	fake, base := serve(t, "/v1/messages", reply(http.StatusOK, "text/event-stream", stream))
+	c := NewAnthropicClient(Options{BaseURL: base + "/v1/messages", Model: "claude-sonnet-4-5", Stream: true, MaxTokens: 1024, Pricing: testPricing})
+	resp, err := c.Complete(context.Background(), Request{Messages: []Message{{Role: "user", Content: "BTC?"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != `{"trend":"up"}` || resp.Model != "claude-sonnet-4-5" {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp.Usage.PromptTokens != 1200 || resp.Usage.CompletionTokens != 300 || !closeTo(resp.Usage.CostUSD, 0.0048) {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+	if fake.body["stream"] != true || fake.body["max_tokens"] != float64(1024) {
+		t.Errorf("request body = %v", fake.body)
+	}
+	if _, ok := fake.body["system"]; ok {
+		t.Error("sent a system prompt without system messages")
+	}
+}
+
+func TestAnthropicCompleteErrors(t *testing.T) {
+	overloaded := `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`
+	tests := []struct {
+		name    string
+		stream  bool
+		handle  func(w http.ResponseWriter)
+		wantErr string
+	}{
+		{
+			name:    "error body",
+			handle:  reply(http.StatusBadRequest, "application/json", `{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens: too large"}}`),
+			wantErr: `messages returned 400: {"type":"error","error":{"type":"invalid_request_error","message":"max_tokens: too large"}}`,
+		},
+		{
+			name:    "malformed body",
+			handle:  reply(http.StatusOK, "application/json", `{"content":`),
+			wantErr: "unmarshal messages response failed",
+		},
+		{
+			name:    "stream status",
+			stream:  true,
+			handle:  reply(529, "application/json", overloaded),
+			wantErr: "messages returned 529: " + overloaded,
+		},
+		{
+			name:    "stream error event",
+			stream:  true,
+			handle:  reply(http.StatusOK, "text/event-stream", "event: error\ndata: "+overloaded+"\n\n"),
+			wantErr: "messages stream error: overloaded_error: Overloaded",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, base := serve(t, "/v1/messages", tt.handle)
+			c := NewAnthropicClient(Options{BaseURL: base, Stream: tt.stream})
+			c.httpClient.SetRetryCount(0)
+			_, err := c.Complete(context.Background(), Request{Messages: []Message{{Role: "user", Content: "BTC?"}}})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// OllamaClient talks to a local Ollama server's chat API
+type OllamaClient struct {
+	httpClient *resty.Client
+	endpoint   string
+	opts       Options
+}
+
+// NewOllamaClient creates a client for the chat API under opts.BaseURL
+// (e.g. http://localhost:11434)
+func NewOllamaClient(opts Options) *OllamaClient {
+	endpoint := strings.TrimRight(opts.BaseURL, "/")
+	if endpoint == "" {
+		endpoint = "http://localhost:11434"
+	}
+	if !strings.HasSuffix(endpoint, "/api/chat") {
+		endpoint += "/api/chat"
+	}
+	return &OllamaClient{
+		httpClient: newHTTPClient(opts),
+		endpoint:   endpoint,
+		opts:       opts,
+	}
+}
+
+// ollamaChunk is a chat response, or one line of a streamed response
+type ollamaChunk struct {
+	Model   string  `json:"model"`
+	Message Message `json:"message"`
+	Done    bool    `json:"done"`
+	// PromptEvalCount and EvalCount are only set on the final chunk
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	Error           string `json:"error"`
+}
+
+// Complete sends the chat messages to Ollama, passing the schema as the
+// structured output format
+func (c *OllamaClient) Complete(ctx context.Context, req Request) (Response, error) {
+	start := time.Now()
+	body := map[string]interface{}{
+		"model":    c.opts.Model,
+		"messages": req.Messages,
+		"stream":   c.opts.Stream,
+	}
+	if len(req.Schema) > 0 {
+		body["format"] = req.Schema
+	}
+	if c.opts.MaxTokens > 0 {
+		body["options"] = map[string]interface{}{"num_predict": c.opts.MaxTokens}
+	}
+
+	resp, err := c.httpClient.R().
+		SetContext(ctx).
+		SetBody(body).
+		SetDoNotParseResponse(c.opts.Stream).
+		Post(c.endpoint)
+	if err != nil {
+		return Response{}, fmt.Errorf("ollama chat request failed: %w", err)
+	}
+
+	var out Response
+	var final ollamaChunk
+	if c.opts.Stream {
+		defer drain(resp.RawBody())
+		if resp.IsError() {
+			return Response{}, fmt.Errorf("ollama chat returned %d: %s", resp.StatusCode(), errorBody(resp.RawBody()))
+		}
+		var sb strings.Builder
+		err = readLines(resp.RawBody(), func(line []byte) error {
+			var chunk ollamaChunk
+			if err := json.Unmarshal(line, &chunk); err != nil {
+				return fmt.Errorf("unmarshal ollama chunk failed: %w", err)
+			}
+			if chunk.Error != "" {
+				return fmt.Errorf("ollama stream error: %s", chunk.Error)
+			}
+			sb.WriteString(chunk.Message.Content)
+			if chunk.Done {
+				final = chunk
+			}
+			return nil
+		})
+		if err != nil {
+			return Response{}, fmt.Errorf("read ollama stream failed: %w", err)
+		}
+		out.Content = sb.String()
+	} else {
+		if resp.IsError() {
+			return Response{}, fmt.Errorf("ollama chat returned %d: %s", resp.StatusCode(), resp.String())
+		}
+		if err := json.Unmarshal(resp.Body(), &final); err != nil {
+			return Response{}, fmt.Errorf("unmarshal ollama response failed: %w", err)
+		}
+		out.Content = final.Message.Content
+	}
+
+	out.Model = final.Model
+	out.Usage = Usage{
+		PromptTokens:     final.PromptEvalCount,
+		CompletionTokens: final.EvalCount,
+		CostUSD:          c.opts.Pricing.Cost(final.PromptEvalCount, final.EvalCount),
+	}
+	out.Latency = time.Since(start)
+	return out, nil
+}
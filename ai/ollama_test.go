@@ -0,0 +1,113 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOllamaComplete(t *testing.T) {
+	fake, base := serve(t, "/api/chat", reply(http.StatusOK, "application/json", `{
+		"model": "qwen2.5:14b",
+		"message": {"role": "assistant", "content": "{\"trend\":\"up\"}"},
+		"done": true,
+		"prompt_eval_count": 1200,
+		"eval_count": 300
+	}`))
+	c := NewOllamaClient(Options{BaseURL: base + "/", Model: "qwen2.5:14b", MaxTokens: 512, Pricing: testPricing})
+	resp, err := c.Complete(context.Background(), Request{
+		Messages: []Message{{Role: "user", Content: "BTC?"}},
+		Schema:   json.RawMessage(`{"type":"object"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != `{"trend":"up"}` || resp.Model != "qwen2.5:14b" {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp.Usage.PromptTokens != 1200 || resp.Usage.CompletionTokens != 300 || !closeTo(resp.Usage.CostUSD, 0.0048) {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+
+	if fake.body["stream"] != false {
+		t.Errorf("stream = %v", fake.body["stream"])
+	}
+	if format, _ := fake.body["format"].(map[string]any); format["type"] != "object" {
+		t.Errorf("format = %v", fake.body["format"])
+	}
+	if options, _ := fake.body["options"].(map[string]any); options["num_predict"] != float64(512) {
+		t.Errorf("options = %v", fake.body["options"])
+	}
+}
+
+func TestOllamaCompleteStream(t *testing.T) {
+	stream := strings.Join([]string{
+		`{"model":"qwen2.5:14b","message":{"role":"assistant","content":"{\"trend\":"},"done":false}`,
+		``,
+		`{"model":"qwen2.5:14b","message":{"role":"assistant","content":"\"up\"}"},"done":false}`,
+		`{"model":"qwen2.5:14b","message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":1200,"eval_count":300}`,
+	}, "\n") + "\n"
+	fake, base := serve(t, "/api/chat", reply(http.StatusOK, "application/x-ndjson", stream))
+	c := NewOllamaClient(Options{BaseURL: base, Model: "qwen2.5:14b", Stream: true, Pricing: testPricing})
+	resp, err := c.Complete(context.Background(), Request{Messages: []Message{{Role: "user", Content: "BTC?"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != `{"trend":"up"}` || resp.Model != "qwen2.5:14b" {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp.Usage.PromptTokens != 1200 || resp.Usage.CompletionTokens != 300 || !closeTo(resp.Usage.CostUSD, 0.0048) {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+	if fake.body["stream"] != true {
+		t.Errorf("stream = %v", fake.body["stream"])
+	}
+	if _, ok := fake.body["options"]; ok {
+		t.Error("sent options without max tokens")
+	}
+}
+
+func TestOllamaCompleteErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  bool
+		handle  func(w http.ResponseWriter)
+		wantErr string
+	}{
+		{
+			name:    "error body",
+			handle:  reply(http.StatusNotFound, "application/json", `{"error":"model \"llama9\" not found, try pulling it first"}`),
+			wantErr: `ollama chat returned 404: {"error":"model \"llama9\" not found, try pulling it first"}`,
+		},
+		{
+			name:    "malformed body",
+			handle:  reply(http.StatusOK, "application/json", `not json`),
+			wantErr: "unmarshal ollama response failed",
+		},
+		{
+			name:    "stream status",
+			stream:  true,
+			handle:  reply(http.StatusInternalServerError, "application/json", `{"error":"out of memory"}`),
+			wantErr: `ollama chat returned 500: {"error":"out of memory"}`,
+		},
+		{
+			name:    "stream error line",
+			stream:  true,
+			handle:  reply(http.StatusOK, "application/x-ndjson", `{"model":"qwen2.5:14b","message":{"content":"{"},"done":false}`+"\n"+`{"error":"llama runner process has terminated"}`+"\n"),
+			wantErr: "ollama stream error: llama runner process has terminated",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, base := serve(t, "/api/chat", tt.handle)
+			c := NewOllamaClient(Options{BaseURL: base, Stream: tt.stream})
+			c.httpClient.SetRetryCount(0)
+			_, err := c.Complete(context.Background(), Request{Messages: []Message{{Role: "user", Content: "BTC?"}}})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
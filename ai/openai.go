@@ -0,0 +1,140 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// OpenAIClient talks to an OpenAI-compatible chat completions API
+type OpenAIClient struct {
+	httpClient *resty.Client
+	endpoint   string
+	opts       Options
+}
+
+// NewOpenAIClient creates a client for the chat completions API under
+// opts.BaseURL (e.g. https://api.openai.com/v1)
+func NewOpenAIClient(opts Options) *OpenAIClient {
+	endpoint := strings.TrimRight(opts.BaseURL, "/")
+	if !strings.HasSuffix(endpoint, "/chat/completions") {
+		endpoint += "/chat/completions"
+	}
+	return &OpenAIClient{
+		httpClient: newHTTPClient(opts),
+		endpoint:   endpoint,
+		opts:       opts,
+	}
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// Complete sends the chat messages and returns the first choice
+func (c *OpenAIClient) Complete(ctx context.Context, req Request) (Response, error) {
+	start := time.Now()
+	body := map[string]interface{}{
+		"model":    c.opts.Model,
+		"messages": req.Messages,
+	}
+	if c.opts.MaxTokens > 0 {
+		body["max_tokens"] = c.opts.MaxTokens
+	}
+	if len(req.Schema) > 0 {
+		body["response_format"] = map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   "market_report",
+				"schema": req.Schema,
+			},
+		}
+	}
+	if c.opts.Stream {
+		body["stream"] = true
+		body["stream_options"] = map[string]interface{}{"include_usage": true}
+	}
+
+	r := c.httpClient.R().SetContext(ctx).SetBody(body).SetDoNotParseResponse(c.opts.Stream)
+	if c.opts.APIKey != "" {
+		r.SetAuthToken(c.opts.APIKey)
+	}
+	resp, err := r.Post(c.endpoint)
+	if err != nil {
+		return Response{}, fmt.Errorf("chat completion request failed: %w", err)
+	}
+
+	var out Response
+	var usage openAIUsage
+	if c.opts.Stream {
+		defer drain(resp.RawBody())
+		if resp.IsError() {
+			return Response{}, fmt.Errorf("chat completion returned %d: %s", resp.StatusCode(), errorBody(resp.RawBody()))
+		}
+		var sb strings.Builder
+		err = readSSE(resp.RawBody(), func(_, data string) error {
+			if data == "[DONE]" {
+				return nil
+			}
+			var chunk struct {
+				Model   string       `json:"model"`
+				Usage   *openAIUsage `json:"usage"`
+				Choices []struct {
+					Delta struct {
+						Content string `json:"content"`
+					} `json:"delta"`
+				} `json:"choices"`
+			}
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return fmt.Errorf("unmarshal chat completion chunk failed: %w", err)
+			}
+			if chunk.Model != "" {
+				out.Model = chunk.Model
+			}
+			if chunk.Usage != nil {
+				usage = *chunk.Usage
+			}
+			for _, choice := range chunk.Choices {
+				sb.WriteString(choice.Delta.Content)
+			}
+			return nil
+		})
+		if err != nil {
+			return Response{}, fmt.Errorf("read chat completion stream failed: %w", err)
+		}
+		out.Content = sb.String()
+	} else {
+		if resp.IsError() {
+			return Response{}, fmt.Errorf("chat completion returned %d: %s", resp.StatusCode(), resp.String())
+		}
+		var result struct {
+			Model   string      `json:"model"`
+			Usage   openAIUsage `json:"usage"`
+			Choices []struct {
+				Message Message `json:"message"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal(resp.Body(), &result); err != nil {
+			return Response{}, fmt.Errorf("unmarshal chat completion failed: %w", err)
+		}
+		if len(result.Choices) == 0 {
+			return Response{}, fmt.Errorf("chat completion returned no choices")
+		}
+		out.Content = result.Choices[0].Message.Content
+		out.Model = result.Model
+		usage = result.Usage
+	}
+
+	out.Usage = Usage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		CostUSD:          c.opts.Pricing.Cost(usage.PromptTokens, usage.CompletionTokens),
+	}
+	out.Latency = time.Since(start)
+	return out, nil
+}
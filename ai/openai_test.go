@@ -0,0 +1,127 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOpenAIComplete(t *testing.T) {
+	fake, base := serve(t, "/v1/chat/completions", reply(http.StatusOK, "application/json", `{
+		"model": "gpt-4o-2024-08-06",
+		"choices": [{"message": {"role": "assistant", "content": "{\"trend\":\"up\"}"}}],
+		"usage": {"prompt_tokens": 1200, "completion_tokens": 300}
+	}`))
+	c := NewOpenAIClient(Options{BaseURL: base + "/v1/", Model: "gpt-4o", APIKey: "sk-test", MaxTokens: 512, Pricing: testPricing})
+	resp, err := c.Complete(context.Background(), Request{
+		Messages: []Message{{Role: "system", Content: "be brief"}, {Role: "user", Content: "BTC?"}},
+		Schema:   json.RawMessage(`{"type":"object"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != `{"trend":"up"}` || resp.Model != "gpt-4o-2024-08-06" {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp.Usage.PromptTokens != 1200 || resp.Usage.CompletionTokens != 300 || !closeTo(resp.Usage.CostUSD, 0.0048) {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+
+	if got := fake.header.Get("Authorization"); got != "Bearer sk-test" {
+		t.Errorf("authorization = %q", got)
+	}
+	if fake.body["model"] != "gpt-4o" || fake.body["max_tokens"] != float64(512) {
+		t.Errorf("request body = %v", fake.body)
+	}
+	if _, ok := fake.body["stream"]; ok {
+		t.Error("non-streaming request asked for a stream")
+	}
+	format, _ := fake.body["response_format"].(map[string]any)
+	if format["type"] != "json_schema" {
+		t.Errorf("response_format = %v", fake.body["response_format"])
+	}
+	if messages, _ := fake.body["messages"].([]any); len(messages) != 2 {
+		t.Errorf("messages = %v", fake.body["messages"])
+	}
+}
+
+func TestOpenAICompleteStream(t *testing.T) {
+	stream := strings.Join([]string{
+		`data: {"model":"gpt-4o-2024-08-06","choices":[{"delta":{"role":"assistant","content":""}}]}`,
+		`data: {"model":"gpt-4o-2024-08-06","choices":[{"delta":{"content":"{\"trend\":"}}]}`,
+		`data: {"model":"gpt-4o-2024-08-06","choices":[{"delta":{"content":"\"up\"}"}}]}`,
+		`data: {"model":"gpt-4o-2024-08-06","choices":[],"usage":{"prompt_tokens":1200,"completion_tokens":300}}`,
+		`data: [DONE]`,
+	}, "\n\n") + "\n\n"
+	fake, base := serve(t, "/v1/chat/completions", reply(http.StatusOK, "text/event-stream", stream))
+	c := NewOpenAIClient(Options{BaseURL: base + "/v1", Model: "gpt-4o", Stream: true, Pricing: testPricing})
+	resp, err := c.Complete(context.Background(), Request{Messages: []Message{{Role: "user", Content: "BTC?"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != `{"trend":"up"}` || resp.Model != "gpt-4o-2024-08-06" {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp.Usage.PromptTokens != 1200 || resp.Usage.CompletionTokens != 300 || !closeTo(resp.Usage.CostUSD, 0.0048) {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+	if fake.body["stream"] != true {
+		t.Errorf("stream = %v", fake.body["stream"])
+	}
+	if opts, _ := fake.body["stream_options"].(map[string]any); opts["include_usage"] != true {
+		t.Errorf("stream_options = %v", fake.body["stream_options"])
+	}
+	if fake.header.Get("Authorization") != "" {
+		t.Error("sent an authorization header without an API key")
+	}
+}
+
+func TestOpenAICompleteErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  bool
+		handle  func(w http.ResponseWriter)
+		wantErr string
+	}{
+		{
+			name:    "error body",
+			handle:  reply(http.StatusUnauthorized, "application/json", `{"error":{"message":"Incorrect API key provided"}}`),
+			wantErr: "chat completion returned 401: {\"error\":{\"message\":\"Incorrect API key provided\"}}",
+		},
+		{
+			name:    "no choices",
+			handle:  reply(http.StatusOK, "application/json", `{"model":"gpt-4o","choices":[]}`),
+			wantErr: "chat completion returned no choices",
+		},
+		{
+			name:    "malformed body",
+			handle:  reply(http.StatusOK, "application/json", `<html>`),
+			wantErr: "unmarshal chat completion failed",
+		},
+		{
+			name:    "stream status",
+			stream:  true,
+			handle:  reply(http.StatusTooManyRequests, "application/json", `{"error":{"message":"Rate limit reached"}}`),
+			wantErr: "chat completion returned 429: {\"error\":{\"message\":\"Rate limit reached\"}}",
+		},
+		{
+			name:    "malformed chunk",
+			stream:  true,
+			handle:  reply(http.StatusOK, "text/event-stream", "data: {\"choices\":\n\n"),
+			wantErr: "unmarshal chat completion chunk failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, base := serve(t, "/chat/completions", tt.handle)
+			c := NewOpenAIClient(Options{BaseURL: base, Stream: tt.stream})
+			c.httpClient.SetRetryCount(0)
+			_, err := c.Complete(context.Background(), Request{Messages: []Message{{Role: "user", Content: "BTC?"}}})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
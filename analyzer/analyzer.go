@@ -3,39 +3,82 @@ package analyzer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
+	"slices"
+	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/exchange"
+	"github.com/songzhibin97/CryptoPulse/metrics"
 	"github.com/songzhibin97/CryptoPulse/models"
+	"github.com/songzhibin97/CryptoPulse/queue"
 	"github.com/songzhibin97/CryptoPulse/report"
+	"github.com/songzhibin97/CryptoPulse/tracing"
+	"github.com/songzhibin97/CryptoPulse/usage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Global storage for pending prompts
-var globalPendingPrompts = make(map[string]string)
+// pendingPrompt is a prompt awaiting a manual response, with the owner,
+// workspace and monitor the response's report belongs to
+type pendingPrompt struct {
+	prompt      string
+	symbol      string
+	intervals   []string
+	ownerID     string
+	workspaceID string
+	monitorID   string
+	// price and klines are the market data the prompt was made from
+	price  float64
+	klines map[string][]models.Kline
+	// basket is set for the prompts of basket analyses
+	basket *basketData
+}
+
+// Global storage for pending prompts, keyed by analysis ID
+var globalPendingPrompts = make(map[string]pendingPrompt)
 var globalPromptsMu sync.RWMutex
 
 // MarketAnalyzer handles market data analysis
 type MarketAnalyzer struct {
 	httpClient      *resty.Client
 	wsConn          *websocket.Conn
-	aiEndpoint      string
+	transport       http.RoundTripper
+	ai              aiSettings
+	budget          config.AIBudget
+	workspaceBudget config.AIBudget
+	aiMu            sync.RWMutex
+	monitorID       string
+	ownerID         string
+	workspaceID     string
+	usage           *usage.Tracker
 	extEndpoint     string
 	proxyURL        string
 	wsProxyURL      string
 	symbol          string
 	intervals       []string
 	orderBook       models.OrderBook
+	bookTime        time.Time
 	klines          map[string][]models.Kline
+	basket          *basket
 	trades          []map[string]interface{}
 	sentiment       string
+	closeDelay      time.Duration
+	fetchQueue      *queue.Pool
+	aiQueue         *queue.Pool
+	priority        queue.Priority
+	skipped         atomic.Int64
 	ctx             context.Context
 	cancel          context.CancelFunc
 	logger          zerolog.Logger
@@ -44,38 +87,42 @@ type MarketAnalyzer struct {
 	latestChartData map[string]interface{}
 }
 
+// ErrBudgetExceeded is returned when a monitor's AI calls are paused because
+// its budget is used up
+var ErrBudgetExceeded = errors.New("ai budget exceeded")
+
+// ErrUnknownAnalysis is returned for manual responses to analyses that have
+// no pending prompt
+var ErrUnknownAnalysis = errors.New("unknown analysis id")
+
 // AnalysisResponse holds the response from AI analysis
 type AnalysisResponse struct {
 	AnalysisID string
 	ReportID   string
 }
 
-// NewMarketAnalyzer creates a new MarketAnalyzer instance
-func NewMarketAnalyzer(symbol string, intervals []string, aiEndpoint, extEndpoint, proxyURL, wsProxyURL string, logger zerolog.Logger, reportMgr *report.ReportManager) *MarketAnalyzer {
+// NewMarketAnalyzer creates a new MarketAnalyzer instance. Its monitor loop
+// and requests stop when ctx is cancelled or Stop is called.
+func NewMarketAnalyzer(ctx context.Context, symbol string, intervals []string, cfg config.Config, logger zerolog.Logger, reportMgr *report.ReportManager) *MarketAnalyzer {
+	proxyURL, wsProxyURL := cfg.ProxyURL.String(), cfg.WSProxyURL.String()
 	logger.Debug().Str("proxy_url", proxyURL).Str("ws_proxy_url", wsProxyURL).Msg("Configuring proxies")
-	var transport *http.Transport
-	if proxyURL != "" {
-		proxy, err := url.Parse(proxyURL)
-		if err != nil {
-			logger.Error().Err(err).Str("proxy_url", proxyURL).Msg("Invalid HTTP proxy URL")
-			transport = &http.Transport{}
-		} else {
-			logger.Info().Str("proxy_url", proxyURL).Msg("Using HTTP proxy")
-			transport = &http.Transport{Proxy: http.ProxyURL(proxy)}
-		}
-	} else {
-		transport = &http.Transport{}
+	base := &http.Transport{}
+	if cfg.ProxyURL.IsSet() {
+		logger.Info().Str("proxy_url", proxyURL).Msg("Using HTTP proxy")
+		base.Proxy = http.ProxyURL(cfg.ProxyURL.URL)
 	}
-	httpClient := resty.New().
+	transport := tracing.Transport(base)
+	httpClient := metrics.InstrumentUpstream(resty.New().
 		SetTransport(transport).
-		SetTimeout(10 * time.Second).
+		SetTimeout(10*time.Second).
 		SetRetryCount(3).
-		SetRetryWaitTime(2 * time.Second)
-	ctx, cancel := context.WithCancel(context.Background())
+		SetRetryWaitTime(2*time.Second), "binance")
+	ctx, cancel := context.WithCancel(ctx)
 	return &MarketAnalyzer{
 		httpClient:  httpClient,
-		aiEndpoint:  aiEndpoint,
-		extEndpoint: extEndpoint,
+		transport:   transport,
+		ai:          newAISettings(cfg, transport, logger),
+		extEndpoint: cfg.ExtEndpoint.String(),
 		proxyURL:    proxyURL,
 		wsProxyURL:  wsProxyURL,
 		symbol:      symbol,
@@ -86,6 +133,7 @@ func NewMarketAnalyzer(symbol string, intervals []string, aiEndpoint, extEndpoin
 		},
 		klines:          make(map[string][]models.Kline),
 		trades:          make([]map[string]interface{}, 0),
+		closeDelay:      cfg.Scheduler.Delay(),
 		ctx:             ctx,
 		cancel:          cancel,
 		logger:          logger,
@@ -94,10 +142,136 @@ func NewMarketAnalyzer(symbol string, intervals []string, aiEndpoint, extEndpoin
 	}
 }
 
+// TrackUsage attributes the analyzer's AI usage to a monitor and enforces budget
+func (ma *MarketAnalyzer) TrackUsage(monitorID string, tracker *usage.Tracker, budget config.AIBudget) {
+	ma.monitorID = monitorID
+	ma.usage = tracker
+	ma.SetBudget(budget)
+}
+
+// UseQueues runs the analyzer's market data fetches and AI calls on shared
+// worker pools with the given priority
+func (ma *MarketAnalyzer) UseQueues(fetch, ai *queue.Pool, prio queue.Priority) {
+	ma.fetchQueue, ma.aiQueue, ma.priority = fetch, ai, prio
+}
+
+// SkippedCycles returns how many monitor cycles were skipped because the
+// previous one was still running
+func (ma *MarketAnalyzer) SkippedCycles() int64 {
+	return ma.skipped.Load()
+}
+
+// enqueue runs fn on a pool, or directly when the analyzer uses none
+func (ma *MarketAnalyzer) enqueue(ctx context.Context, pool *queue.Pool, fn func(context.Context) error) error {
+	if pool == nil {
+		return fn(ctx)
+	}
+	return pool.Do(ctx, ma.priority, fn)
+}
+
+// SetOwner attributes the analyzer's reports and usage to a user
+func (ma *MarketAnalyzer) SetOwner(userID string) {
+	ma.ownerID = userID
+}
+
+// SetWorkspace scopes the analyzer's reports and usage to a workspace and
+// pauses AI calls when the workspace's shared budget is used up
+func (ma *MarketAnalyzer) SetWorkspace(workspaceID string, budget config.AIBudget) {
+	ma.aiMu.Lock()
+	defer ma.aiMu.Unlock()
+	ma.workspaceID = workspaceID
+	ma.workspaceBudget = budget
+}
+
+// SetBudget replaces the monitor's AI budget
+func (ma *MarketAnalyzer) SetBudget(budget config.AIBudget) {
+	ma.aiMu.Lock()
+	defer ma.aiMu.Unlock()
+	ma.budget = budget
+}
+
+// AIPaused reports whether AI calls are paused by the monitor's budget
+func (ma *MarketAnalyzer) AIPaused() (bool, string) {
+	if ma.usage == nil {
+		return false, ""
+	}
+	ma.aiMu.RLock()
+	budget, workspaceID, workspaceBudget := ma.budget, ma.workspaceID, ma.workspaceBudget
+	ma.aiMu.RUnlock()
+	now := time.Now()
+	if paused, reason := ma.usage.Exceeded(ma.monitorID, budget, now); paused {
+		return paused, reason
+	}
+	if workspaceID == "" {
+		return false, ""
+	}
+	return ma.usage.WorkspaceExceeded(workspaceID, workspaceBudget, now)
+}
+
+// Symbol returns the analyzed trading pair
+func (ma *MarketAnalyzer) Symbol() string {
+	return ma.symbol
+}
+
+// lastPrice returns the close of the most recent candle fetched, or 0
+// before any were
+func (ma *MarketAnalyzer) lastPrice() float64 {
+	ma.mu.RLock()
+	defer ma.mu.RUnlock()
+	var latest models.Kline
+	for _, klines := range ma.klines {
+		if n := len(klines); n > 0 && klines[n-1].OpenTime > latest.OpenTime {
+			latest = klines[n-1]
+		}
+	}
+	return parseNum(latest.Close)
+}
+
+// recentKlines returns copies of the last n candles of each interval, or
+// nil before any were fetched
+func (ma *MarketAnalyzer) recentKlines(n int) map[string][]models.Kline {
+	ma.mu.RLock()
+	defer ma.mu.RUnlock()
+	if len(ma.klines) == 0 {
+		return nil
+	}
+	out := make(map[string][]models.Kline, len(ma.klines))
+	for interval, klines := range ma.klines {
+		out[interval] = slices.Clone(klines[max(len(klines)-n, 0):])
+	}
+	return out
+}
+
+// SetIntervals replaces the analyzed kline intervals, dropping data of
+// intervals no longer in use. Call it while the monitor loop is not running.
+func (ma *MarketAnalyzer) SetIntervals(intervals []string) {
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+	keep := make(map[string]bool, len(intervals))
+	for _, interval := range intervals {
+		keep[interval] = true
+	}
+	for interval := range ma.klines {
+		if !keep[interval] {
+			delete(ma.klines, interval)
+		}
+	}
+	if ma.basket != nil {
+		for _, byInterval := range ma.basket.klines {
+			for interval := range byInterval {
+				if !keep[interval] {
+					delete(byInterval, interval)
+				}
+			}
+		}
+	}
+	ma.intervals = intervals
+}
+
 // ConnectWebSocket establishes a WebSocket connection to Binance
-func (ma *MarketAnalyzer) ConnectWebSocket() error {
+func (ma *MarketAnalyzer) ConnectWebSocket(ctx context.Context) error {
 	ma.logger.Info().Msg("Skipping WebSocket, using HTTP fallback for debugging")
-	if err := ma.FetchRealtimeData(); err != nil {
+	if err := ma.FetchRealtimeData(ctx); err != nil {
 		ma.logger.Error().Err(err).Msg("Failed to fetch real-time data via HTTP")
 		return err
 	}
@@ -137,6 +311,9 @@ func (ma *MarketAnalyzer) GenerateChartData() map[string]interface{} {
 			"asks": ma.orderBook.Asks,
 		},
 	}
+	if ma.basket != nil {
+		chartData["snapshots"] = ma.basket.snapshots
+	}
 	ma.logger.Info().
 		Int("kline_count", klineCount).
 		Int("bids_count", len(ma.orderBook.Bids)).
@@ -151,41 +328,107 @@ func (ma *MarketAnalyzer) GetLatestChartData() map[string]interface{} {
 	return ma.latestChartData
 }
 
-// FetchRealtimeData fetches real-time data via HTTP API
-func (ma *MarketAnalyzer) FetchRealtimeData() error {
+// OrderBook returns the order book of the last fetch, best levels first,
+// and when it was fetched; the time is zero before the first fetch
+func (ma *MarketAnalyzer) OrderBook() (exchange.Book, time.Time) {
+	ma.mu.RLock()
+	defer ma.mu.RUnlock()
+	book := exchange.Book{Venue: exchange.Binance, Symbol: ma.symbol, Time: ma.bookTime.UnixMilli()}
+	side := func(levels map[string]float64) []exchange.Level {
+		out := make([]exchange.Level, 0, len(levels))
+		for price, qty := range levels {
+			out = append(out, exchange.Level{Price: parseNum(price), Qty: qty})
+		}
+		return out
+	}
+	book.Bids, book.Asks = side(ma.orderBook.Bids), side(ma.orderBook.Asks)
+	sort.Slice(book.Bids, func(i, j int) bool { return book.Bids[i].Price > book.Bids[j].Price })
+	sort.Slice(book.Asks, func(i, j int) bool { return book.Asks[i].Price < book.Asks[j].Price })
+	return book, ma.bookTime
+}
+
+// get requests a Binance endpoint inside a span named after it
+func (ma *MarketAnalyzer) get(ctx context.Context, name, url string) (*resty.Response, error) {
+	ctx, span := tracing.Tracer().Start(ctx, name, trace.WithAttributes(attribute.String("symbol", ma.symbol)))
+	defer span.End()
+	resp, err := ma.httpClient.R().SetContext(ctx).Get(url)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode()))
+	return resp, nil
+}
+
+// parseKlines decodes a klines response. Candles whose close time has
+// passed are marked closed.
+func parseKlines(body []byte) ([]models.Kline, error) {
+	var raw [][]interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	klines := make([]models.Kline, 0, len(raw))
+	for _, k := range raw {
+		closeTime := int64(k[6].(float64))
+		klines = append(klines, models.Kline{
+			OpenTime:  int64(k[0].(float64)),
+			Open:      k[1].(string),
+			High:      k[2].(string),
+			Low:       k[3].(string),
+			Close:     k[4].(string),
+			Volume:    k[5].(string),
+			CloseTime: closeTime,
+			Closed:    closeTime < now,
+		})
+	}
+	return klines, nil
+}
+
+// FetchRealtimeData fetches real-time data via HTTP API on the fetch queue
+func (ma *MarketAnalyzer) FetchRealtimeData(ctx context.Context) error {
+	if ma.basket != nil {
+		return ma.enqueue(ctx, ma.fetchQueue, ma.fetchBasket)
+	}
+	return ma.enqueue(ctx, ma.fetchQueue, ma.fetchRealtimeData)
+}
+
+func (ma *MarketAnalyzer) fetchRealtimeData(ctx context.Context) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "FetchRealtimeData", trace.WithAttributes(
+		attribute.String("symbol", ma.symbol),
+		attribute.StringSlice("intervals", ma.intervals),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	ma.logger.Info().Msg("Fetching real-time data via HTTP")
 	for _, interval := range ma.intervals {
 		url := fmt.Sprintf("https://api1.binance.com/api/v3/klines?symbol=%s&interval=%s&limit=100", ma.symbol, interval)
-		resp, err := ma.httpClient.R().Get(url)
+		resp, err := ma.get(ctx, "binance.klines "+interval, url)
 		if err != nil {
 			ma.logger.Error().Err(err).Str("url", url).Msg("Fetch klines error")
 			return fmt.Errorf("fetch klines failed: %w", err)
 		}
 		ma.logger.Debug().Str("url", url).Int("status", resp.StatusCode()).Msg("Fetched klines via HTTP")
-		var klines [][]interface{}
-		if err := json.Unmarshal(resp.Body(), &klines); err != nil {
+		klines, err := parseKlines(resp.Body())
+		if err != nil {
 			ma.logger.Error().Err(err).Msg("Unmarshal klines error")
 			return fmt.Errorf("unmarshal klines failed: %w", err)
 		}
 		ma.mu.Lock()
-		ma.klines[interval] = make([]models.Kline, 0, len(klines))
-		for _, k := range klines {
-			ma.klines[interval] = append(ma.klines[interval], models.Kline{
-				OpenTime:  int64(k[0].(float64)),
-				Open:      k[1].(string),
-				High:      k[2].(string),
-				Low:       k[3].(string),
-				Close:     k[4].(string),
-				Volume:    k[5].(string),
-				CloseTime: int64(k[6].(float64)),
-			})
-		}
+		ma.klines[interval] = klines
 		ma.mu.Unlock()
 		ma.logger.Info().Int("kline_count", len(klines)).Str("interval", interval).Msg("Fetched klines")
 	}
 
 	url := fmt.Sprintf("https://api1.binance.com/api/v3/depth?symbol=%s&limit=1000", ma.symbol)
-	resp, err := ma.httpClient.R().Get(url)
+	resp, err := ma.get(ctx, "binance.depth", url)
 	if err != nil {
 		ma.logger.Error().Err(err).Str("url", url).Msg("Fetch depth error")
 		return fmt.Errorf("fetch depth failed: %w", err)
@@ -202,6 +445,7 @@ func (ma *MarketAnalyzer) FetchRealtimeData() error {
 	}
 	ma.mu.Lock()
 	ma.orderBook.LastUpdateID = depth.LastUpdateID
+	ma.bookTime = time.Now()
 	ma.orderBook.Bids = make(map[string]float64)
 	ma.orderBook.Asks = make(map[string]float64)
 	for _, bid := range depth.Bids {
@@ -216,7 +460,7 @@ func (ma *MarketAnalyzer) FetchRealtimeData() error {
 	ma.logger.Info().Int("bids_count", len(depth.Bids)).Int("asks_count", len(depth.Asks)).Msg("Fetched order book")
 
 	url = fmt.Sprintf("https://api1.binance.com/api/v3/aggTrades?symbol=%s&limit=500", ma.symbol)
-	resp, err = ma.httpClient.R().Get(url)
+	resp, err = ma.get(ctx, "binance.aggTrades", url)
 	if err != nil {
 		ma.logger.Error().Err(err).Str("url", url).Msg("Fetch trades error")
 		return fmt.Errorf("fetch trades failed: %w", err)
@@ -236,116 +480,221 @@ func (ma *MarketAnalyzer) FetchRealtimeData() error {
 	return nil
 }
 
-// CallAIAnalysis calls AI for analysis
-func (ma *MarketAnalyzer) CallAIAnalysis() (AnalysisResponse, error) {
-	ma.mu.Lock()
-	analysisID := uuid.New().String()
-	prompt := ma.GeneratePrompt()
-	globalPromptsMu.Lock()
-	globalPendingPrompts[analysisID] = prompt
-	ma.logger.Info().Str("analysis_id", analysisID).Msg("Stored pending prompt")
-	globalPromptsMu.Unlock()
-	ma.mu.Unlock()
+// CallAIAnalysis calls AI for analysis on the AI queue
+func (ma *MarketAnalyzer) CallAIAnalysis(ctx context.Context) (AnalysisResponse, error) {
+	var resp AnalysisResponse
+	err := ma.enqueue(ctx, ma.aiQueue, func(ctx context.Context) (err error) {
+		if ma.basket != nil {
+			resp, err = ma.callBasketAnalysis(ctx)
+			return err
+		}
+		resp, err = ma.callAIAnalysis(ctx)
+		return err
+	})
+	return resp, err
+}
 
-	if ma.aiEndpoint == "manual" {
-		ma.logger.Info().Str("analysis_id", analysisID).Msg("Manual AI mode")
+func (ma *MarketAnalyzer) callAIAnalysis(ctx context.Context) (resp AnalysisResponse, err error) {
+	analysisID := uuid.New().String()
+	ctx, span := tracing.Tracer().Start(ctx, "CallAIAnalysis", trace.WithAttributes(
+		attribute.String("symbol", ma.symbol),
+		attribute.String("analysis_id", analysisID),
+	))
+	defer func() {
+		span.SetAttributes(attribute.String("report_id", resp.ReportID))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	_, promptSpan := tracing.Tracer().Start(ctx, "GeneratePrompt")
+	prompt, tokens := ma.GeneratePrompt()
+	promptSpan.SetAttributes(attribute.Int("estimated_tokens", tokens))
+	promptSpan.End()
+	settings := ma.aiConfig()
+
+	if settings.primary != nil || len(settings.ensemble) > 0 {
+		if paused, reason := ma.AIPaused(); paused {
+			ma.logger.Warn().Str("monitor_id", ma.monitorID).Str("reason", reason).Msg("AI calls paused by budget")
+			return AnalysisResponse{AnalysisID: analysisID}, fmt.Errorf("%w: %s", ErrBudgetExceeded, reason)
+		}
+	}
+	if len(settings.ensemble) > 0 {
+		ma.logger.Info().Str("analysis_id", analysisID).Int("estimated_tokens", tokens).Int("members", len(settings.ensemble)).Msg("Requesting ensemble AI analysis")
+		return ma.runEnsemble(ctx, settings, analysisID, prompt)
+	}
+	if settings.primary == nil {
+		globalPromptsMu.Lock()
+		globalPendingPrompts[analysisID] = ma.pending(prompt)
+		globalPromptsMu.Unlock()
+		ma.logger.Info().Str("analysis_id", analysisID).Int("estimated_tokens", tokens).Msg("Manual AI mode, stored pending prompt")
 		return AnalysisResponse{AnalysisID: analysisID}, nil
 	}
-	return AnalysisResponse{}, fmt.Errorf("unsupported AI endpoint: %s", ma.aiEndpoint)
+	ma.logger.Info().Str("analysis_id", analysisID).Int("estimated_tokens", tokens).Msg("Requesting AI analysis")
+	res, err := ma.runStructuredAnalysis(ctx, *settings.primary, settings.maxRepairs, analysisID, "", prompt)
+	return AnalysisResponse{AnalysisID: analysisID, ReportID: res.reportID}, err
 }
 
-// GeneratePrompt generates the AI analysis prompt
-func (ma *MarketAnalyzer) GeneratePrompt() string {
+// GeneratePrompt generates the AI analysis prompt and its estimated token count
+func (ma *MarketAnalyzer) GeneratePrompt() (string, int) {
 	analysisType := "monitor"
 	cycle := "continuous"
 	startTs, endTs := int64(0), int64(0)
 
-	promptStr := ma.generatePrompt(analysisType, cycle, startTs, endTs)
+	return ma.generatePrompt(analysisType, cycle, startTs, endTs)
+}
+
+// wrapPrompt wraps a rendered prompt in the JSON object sent to the AI
+func (ma *MarketAnalyzer) wrapPrompt(prompt string) string {
 	data := map[string]interface{}{
-		"prompt": promptStr,
+		"prompt": prompt,
 	}
 	promptBytes, err := json.Marshal(data)
 	if err != nil {
 		ma.logger.Error().Err(err).Msg("Failed to marshal prompt data")
-		return promptStr
+		return prompt
 	}
 	return string(promptBytes)
 }
 
+// pending records a prompt of the analyzer awaiting a manual response
+func (ma *MarketAnalyzer) pending(prompt string) pendingPrompt {
+	return pendingPrompt{
+		prompt:      prompt,
+		symbol:      ma.symbol,
+		intervals:   ma.intervals,
+		ownerID:     ma.ownerID,
+		workspaceID: ma.workspace(),
+		monitorID:   ma.monitorID,
+		price:       ma.lastPrice(),
+		klines:      ma.recentKlines(reportKlines),
+	}
+}
+
 // GetPendingPrompt retrieves a pending prompt
 func (ma *MarketAnalyzer) GetPendingPrompt(analysisID string) (string, bool) {
 	globalPromptsMu.RLock()
 	defer globalPromptsMu.RUnlock()
-	prompt, ok := globalPendingPrompts[analysisID]
-	return prompt, ok
+	p, ok := globalPendingPrompts[analysisID]
+	return p.prompt, ok
 }
 
-// SubmitManualResponse submits a manual AI response
-func (ma *MarketAnalyzer) SubmitManualResponse(analysisID, responseJSON string) (AnalysisResponse, error) {
+// submits reports whether a user of a workspace may answer the prompt:
+// prompts belong to their workspace, or to their owner for monitors from
+// before workspaces existed
+func (p pendingPrompt) submits(workspaceID, userID string) bool {
+	if p.workspaceID == "" {
+		return p.ownerID == userID
+	}
+	return p.workspaceID == workspaceID
+}
+
+// SubmitManualResponse validates and saves a manual AI response to a
+// pending prompt that the user userID of workspaceID may answer; prompts
+// of other workspaces are reported as ErrUnknownAnalysis. The report is
+// attributed to the owner, workspace and monitor of the prompt and takes
+// its symbol and market data from the prompt, not from the response. Each
+// prompt is answered once; invalid responses are stored as failed analyses
+// and a *report.ValidationError is returned so the caller can correct and
+// resubmit.
+func SubmitManualResponse(ctx context.Context, cfg config.Config, logger zerolog.Logger, reportMgr *report.ReportManager, workspaceID, userID, analysisID, responseJSON string) (AnalysisResponse, error) {
+	if _, err := uuid.Parse(analysisID); err != nil {
+		return AnalysisResponse{}, fmt.Errorf("%w: %q", ErrUnknownAnalysis, analysisID)
+	}
+	// The prompt is taken out while the response is handled, so concurrent
+	// submissions cannot both answer it, and put back if it fails
 	globalPromptsMu.Lock()
-	delete(globalPendingPrompts, analysisID)
+	p, pending := globalPendingPrompts[analysisID]
+	pending = pending && p.submits(workspaceID, userID)
+	if pending {
+		delete(globalPendingPrompts, analysisID)
+	}
 	globalPromptsMu.Unlock()
-
-	reportID := uuid.New().String()
-	if err := ma.reportMgr.SaveReport(reportID, responseJSON); err != nil {
-		return AnalysisResponse{}, err
+	if !pending {
+		return AnalysisResponse{}, fmt.Errorf("%w: %q", ErrUnknownAnalysis, analysisID)
 	}
-	return AnalysisResponse{ReportID: reportID}, nil
+	ma := NewMarketAnalyzer(ctx, p.symbol, p.intervals, cfg, logger, reportMgr)
+	defer ma.Stop()
+	ma.ownerID, ma.workspaceID, ma.monitorID = p.ownerID, p.workspaceID, p.monitorID
+	resp, err := ma.answer(analysisID, responseJSON, p)
+	if err != nil {
+		globalPromptsMu.Lock()
+		globalPendingPrompts[analysisID] = p
+		globalPromptsMu.Unlock()
+	}
+	return resp, err
 }
 
-// generatePrompt generates the AI prompt with real-time data
-func (ma *MarketAnalyzer) generatePrompt(analysisType, cycle string, startTime, endTime int64) string {
-	ma.mu.Lock()
-	defer ma.mu.Unlock()
-
-	limitedKlines := make(map[string][]models.Kline)
-	for interval, klines := range ma.klines {
-		filteredKlines := klines
-		if len(klines) > 10 {
-			filteredKlines = klines[len(klines)-10:]
-		}
-		limitedKlines[interval] = filteredKlines
+// answer validates and saves a manual response to the pending prompt p
+func (ma *MarketAnalyzer) answer(analysisID, responseJSON string, p pendingPrompt) (AnalysisResponse, error) {
+	if p.basket != nil {
+		return ma.submitBasketResponse(analysisID, responseJSON, *p.basket)
 	}
-
-	limitedTrades := ma.trades
-	if len(ma.trades) > 50 {
-		limitedTrades = ma.trades[len(ma.trades)-50:]
+	rep, err := report.Parse(responseJSON)
+	if err != nil {
+		ma.saveFailed(analysisID, []string{responseJSON}, err, nil)
+		return AnalysisResponse{AnalysisID: analysisID}, err
 	}
+	rep.Manual = true
+	rep.Symbol, rep.Price, rep.Klines = p.symbol, p.price, p.klines
+	reportID, err := ma.saveReport(rep)
+	if err != nil {
+		return AnalysisResponse{}, err
+	}
+	return AnalysisResponse{AnalysisID: analysisID, ReportID: reportID}, nil
+}
+
+// generatePrompt generates the AI prompt with real-time data, compacting the
+// market data until the estimated token count of the wrapped prompt fits
+// the analyzer's budget
+func (ma *MarketAnalyzer) generatePrompt(analysisType, cycle string, startTime, endTime int64) (string, int) {
+	budget := ma.aiConfig().tokenBudget
+	ma.mu.RLock()
+	defer ma.mu.RUnlock()
 
-	limitedBids := make(map[string]float64)
-	limitedAsks := make(map[string]float64)
-	bidCount, askCount := 0, 0
-	for price, qty := range ma.orderBook.Bids {
-		if bidCount < 50 {
-			limitedBids[price] = qty
-			bidCount++
+	var prompt string
+	var tokens int
+	for i, level := range compactLevels {
+		data := compactMarketData(ma.intervals, ma.klines, ma.orderBook, ma.trades, level)
+		prompt = ma.wrapPrompt(ma.renderPrompt(data, analysisType, cycle, 0, budget))
+		tokens = EstimateTokens(prompt)
+		// Re-render with the final estimate; the number itself only adds a few tokens
+		prompt = ma.wrapPrompt(ma.renderPrompt(data, analysisType, cycle, tokens, budget))
+		if tokens <= budget || i == len(compactLevels)-1 {
+			break
 		}
 	}
-	for price, qty := range ma.orderBook.Asks {
-		if askCount < 50 {
-			limitedAsks[price] = qty
-			askCount++
-		}
+	if tokens > budget {
+		ma.logger.Warn().Int("estimated_tokens", tokens).Int("token_budget", budget).Msg("Prompt exceeds token budget at leanest compaction")
 	}
+	ma.logger.Debug().Int("estimated_tokens", tokens).Int("token_budget", budget).Msg("Generated compact prompt")
+	return prompt, tokens
+}
 
-	klinesJSON, _ := json.Marshal(limitedKlines)
-	orderBookJSON, _ := json.Marshal(map[string]interface{}{
-		"bids": limitedBids,
-		"asks": limitedAsks,
-	})
-	tradesJSON, _ := json.Marshal(limitedTrades)
-
+// renderPrompt fills the analysis template with compacted market data
+func (ma *MarketAnalyzer) renderPrompt(data compactData, analysisType, cycle string, tokens, budget int) string {
 	return fmt.Sprintf(`## 数字资产市场动态分析报告
 
-**输入数据**:
+**输入数据**（CSV 表格，时间为 UTC，较早的 K 线已汇总为统计行）:
 - 交易对: %s
-- K线数据: 周期包括 %v
-  - 数据: %s
-- 订单簿深度: %s
-- 成交数据: %s
+- K线数据: 周期包括 %v；表格中均为已收盘 K 线，live 行为尚未收盘的当前 K 线，其数值仍会变化，不应视为确认信号
+`+"```"+`
+%s
+`+"```"+`
+- 订单簿深度（按距中间价区间聚合 + 最优档位）:
+`+"```"+`
+%s
+`+"```"+`
+- 成交数据（按时间分桶聚合 + 大额成交）:
+`+"```"+`
+%s
+`+"```"+`
 - 外部情绪: %s
 - 分析类型: %s
 - 监控周期: %s
+- 估算 Token 数: %d（预算 %d）
 ## 分析任务	
 1. 资金流动态势
 - 主动买卖方向识别
@@ -368,46 +717,149 @@ func (ma *MarketAnalyzer) generatePrompt(analysisType, cycle string, startTime,
 5. 风险预警系统
 - 价格异动实时预警
 - 市场操纵识别模型
+
+## 输出格式
+仅输出一个 JSON 对象，须符合以下 JSON Schema（trend_direction 取 bullish/bearish/neutral）：
+`+"```json"+`
+%s
+`+"```"+`
 `,
-		ma.symbol, ma.intervals, string(klinesJSON),
-		string(orderBookJSON), string(tradesJSON), ma.sentiment, analysisType, cycle)
+		ma.symbol, ma.intervals, data.klines, data.orderBook, data.trades,
+		ma.sentiment, analysisType, cycle, tokens, budget, reportSchemaText)
 }
 
-// RunMonitor runs the monitoring loop
-func (ma *MarketAnalyzer) RunMonitor(cycle string) error {
-	ma.logger.Info().Str("cycle", cycle).Msg("Starting monitor")
-	duration, err := time.ParseDuration(cycle)
-	if err != nil {
-		ma.logger.Error().Err(err).Str("cycle", cycle).Msg("Invalid cycle duration")
-		return err
+// RunMonitor runs the monitoring loop until ctx is cancelled or the
+// analyzer is stopped. Cancelling ctx alone pauses the monitor: it can be
+// run again later. When align names a candle interval, cycles run shortly
+// after each close of that candle instead of every cycle. A cycle that is
+// due while the previous one still runs is skipped; RunMonitor returns once
+// the running cycle has ended.
+func (ma *MarketAnalyzer) RunMonitor(ctx context.Context, cycle, align string) error {
+	ma.logger.Info().Str("cycle", cycle).Str("align", align).Msg("Starting monitor")
+	var next func() time.Duration
+	if align != "" {
+		if _, ok := models.IntervalDuration(align); !ok {
+			err := fmt.Errorf("unsupported candle interval %q", align)
+			ma.logger.Error().Err(err).Msg("Invalid align interval")
+			return err
+		}
+		next = func() time.Duration { return ma.untilClose(align) }
+	} else {
+		duration, err := time.ParseDuration(cycle)
+		if err != nil {
+			ma.logger.Error().Err(err).Str("cycle", cycle).Msg("Invalid cycle duration")
+			return err
+		}
+		next = func() time.Duration { return duration }
 	}
 
-	ticker := time.NewTicker(duration)
-	defer ticker.Stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(ma.ctx, cancel)()
+
+	timer := time.NewTimer(next())
+	defer timer.Stop()
+
+	var inFlight sync.WaitGroup
+	var running atomic.Bool
+	defer inFlight.Wait()
 
 	for {
 		select {
-		case <-ma.ctx.Done():
+		case <-ctx.Done():
 			ma.logger.Info().Msg("Monitor stopped")
 			return nil
-		case <-ticker.C:
-			ma.logger.Debug().Msg("Running monitor cycle")
-			if err := ma.FetchRealtimeData(); err != nil {
-				ma.logger.Error().Err(err).Msg("Monitor fetch data failed")
+		case <-timer.C:
+			if ctx.Err() != nil {
 				continue
 			}
-			chartData := ma.GenerateChartData()
-			ma.mu.Lock()
-			ma.latestChartData = chartData
-			ma.mu.Unlock()
-			resp, err := ma.CallAIAnalysis()
-			if err != nil {
-				ma.logger.Error().Err(err).Msg("Monitor AI analysis failed")
+			if !running.CompareAndSwap(false, true) {
+				ma.skipped.Add(1)
+				metrics.CountSkippedCycle()
+				ma.logger.Warn().Str("monitor_id", ma.monitorID).Msg("Previous monitor cycle still running, skipping cycle")
+				timer.Reset(next())
 				continue
 			}
-			ma.logger.Info().Str("analysis_id", resp.AnalysisID).Msg("Monitor cycle completed")
+			inFlight.Add(1)
+			go func() {
+				defer inFlight.Done()
+				defer running.Store(false)
+				ma.observeCycle(ctx)
+			}()
+			timer.Reset(next())
+		}
+	}
+}
+
+// observeCycle runs one monitor cycle inside a span and records its outcome
+func (ma *MarketAnalyzer) observeCycle(ctx context.Context) {
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "monitor.cycle", trace.WithAttributes(
+		attribute.String("symbol", ma.symbol),
+		attribute.String("monitor_id", ma.monitorID),
+	))
+	outcome := ma.runCycle(ctx)
+	span.SetAttributes(attribute.String("outcome", outcome))
+	if outcome != "ok" && outcome != "ai_paused" && outcome != "canceled" {
+		span.SetStatus(codes.Error, outcome)
+	}
+	span.End()
+	metrics.ObserveCycle(outcome, time.Since(start))
+}
+
+// untilClose returns how long to wait for the next close of an interval's
+// candle plus the close delay. The close time of the fetched live candle is
+// preferred; the interval's UTC boundary is used before any data arrived.
+func (ma *MarketAnalyzer) untilClose(interval string) time.Duration {
+	now := time.Now()
+	next, _ := models.NextClose(interval, now)
+	ma.mu.RLock()
+	if ks := ma.klines[interval]; len(ks) > 0 {
+		if at := time.UnixMilli(ks[len(ks)-1].CloseTime + 1); at.After(now) {
+			next = at
 		}
 	}
+	ma.mu.RUnlock()
+	return next.Sub(now) + ma.closeDelay
+}
+
+// runCycle fetches fresh data and requests an analysis, returning the
+// cycle's outcome for metrics
+func (ma *MarketAnalyzer) runCycle(ctx context.Context) string {
+	ma.logger.Debug().Msg("Running monitor cycle")
+	if err := ma.FetchRealtimeData(ctx); err != nil {
+		if ctx.Err() != nil {
+			return "canceled"
+		}
+		if errors.Is(err, queue.ErrFull) {
+			ma.logger.Warn().Msg("Fetch queue full, dropping monitor cycle")
+			return "queue_full"
+		}
+		ma.logger.Error().Err(err).Msg("Monitor fetch data failed")
+		return "fetch_error"
+	}
+	chartData := ma.GenerateChartData()
+	ma.mu.Lock()
+	ma.latestChartData = chartData
+	ma.mu.Unlock()
+	resp, err := ma.CallAIAnalysis(ctx)
+	if errors.Is(err, ErrBudgetExceeded) {
+		return "ai_paused"
+	}
+	if errors.Is(err, queue.ErrFull) {
+		ma.logger.Warn().Msg("AI queue full, dropping monitor cycle")
+		return "queue_full"
+	}
+	if err != nil && ctx.Err() != nil {
+		ma.logger.Info().Str("analysis_id", resp.AnalysisID).Msg("Monitor cycle canceled during AI analysis")
+		return "canceled"
+	}
+	if err != nil {
+		ma.logger.Error().Err(err).Msg("Monitor AI analysis failed")
+		return "ai_error"
+	}
+	ma.logger.Info().Str("analysis_id", resp.AnalysisID).Str("report_id", resp.ReportID).Msg("Monitor cycle completed")
+	return "ok"
 }
 
 // Stop stops the MarketAnalyzer
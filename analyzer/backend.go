@@ -0,0 +1,94 @@
+package analyzer
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/ai"
+	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/tracing"
+)
+
+// aiBackend is a configured AI backend the analyzer can send prompts to
+type aiBackend struct {
+	name     string
+	provider string
+	model    string
+	client   ai.Client
+}
+
+// newAIBackend creates the provider adapter for a configured backend
+func newAIBackend(cfg config.Config, b config.AIBackend, transport http.RoundTripper) (aiBackend, error) {
+	provider := b.Provider
+	if provider == "" {
+		provider = ai.ProviderOpenAI
+	}
+	if provider == ai.ProviderOllama {
+		// Ollama usually runs locally, so don't send it through the market data proxy
+		transport = tracing.Transport(nil)
+	}
+	pricing := cfg.AIPricing[b.Model]
+	client, err := ai.NewClient(ai.Options{
+		Provider:  provider,
+		BaseURL:   b.Endpoint.String(),
+		Model:     b.Model,
+		APIKey:    b.APIKey,
+		Timeout:   time.Duration(b.Timeout),
+		Stream:    b.Stream,
+		MaxTokens: b.MaxTokens,
+		Pricing:   ai.Pricing{Prompt: pricing.Prompt, Completion: pricing.Completion},
+		Transport: transport,
+	})
+	if err != nil {
+		return aiBackend{}, err
+	}
+	return aiBackend{name: b.Name, provider: provider, model: b.Model, client: client}, nil
+}
+
+// aiSettings are the hot-reloadable AI settings of an analyzer
+type aiSettings struct {
+	primary         *aiBackend
+	ensemble        []aiBackend
+	ensembleTimeout time.Duration
+	maxRepairs      int
+	tokenBudget     int
+}
+
+// newAISettings builds the AI backends selected by cfg
+func newAISettings(cfg config.Config, transport http.RoundTripper, logger zerolog.Logger) aiSettings {
+	var primary *aiBackend
+	if b, ok := cfg.PrimaryBackend(); ok {
+		backend, err := newAIBackend(cfg, b, transport)
+		if err != nil {
+			logger.Error().Err(err).Str("backend", b.Name).Msg("Invalid AI backend, falling back to manual mode")
+		} else {
+			primary = &backend
+		}
+	}
+	ensemble, tokenBudget := newEnsemble(cfg, transport, logger)
+	return aiSettings{
+		primary:         primary,
+		ensemble:        ensemble,
+		ensembleTimeout: cfg.EnsembleTimeout(),
+		maxRepairs:      cfg.MaxRepairs(),
+		tokenBudget:     tokenBudget,
+	}
+}
+
+// aiConfig returns a snapshot of the current AI settings
+func (ma *MarketAnalyzer) aiConfig() aiSettings {
+	ma.aiMu.RLock()
+	defer ma.aiMu.RUnlock()
+	return ma.ai
+}
+
+// ReloadAI rebuilds the AI backends from cfg. Analyses already in flight
+// finish with the settings they started with.
+func (ma *MarketAnalyzer) ReloadAI(cfg config.Config) {
+	settings := newAISettings(cfg, ma.transport, ma.logger)
+	ma.aiMu.Lock()
+	ma.ai = settings
+	ma.aiMu.Unlock()
+	ma.logger.Info().Str("symbol", ma.symbol).Bool("manual", settings.primary == nil && len(settings.ensemble) == 0).Int("ensemble", len(settings.ensemble)).Msg("Reloaded AI settings")
+}
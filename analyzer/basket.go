@@ -0,0 +1,411 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/metrics"
+	"github.com/songzhibin97/CryptoPulse/models"
+	"github.com/songzhibin97/CryptoPulse/report"
+	"github.com/songzhibin97/CryptoPulse/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// basketKlineLimit is the number of candles fetched per symbol and interval
+// of a basket; fewer than for a single symbol to save request weight and
+// prompt tokens
+const basketKlineLimit = 50
+
+// basket is the market data of an analyzer covering a watchlist
+type basket struct {
+	name    string
+	symbols []string
+	// klines maps symbol and interval to the fetched candles, including
+	// the benchmark's
+	klines    map[string]map[string][]models.Kline
+	snapshots []models.SymbolSnapshot
+	// benchmark, window and stats configure the correlation statistics;
+	// stats adds them to prompts
+	benchmark string
+	window    int
+	stats     bool
+}
+
+// basketData is what a basket analysis is based on
+type basketData struct {
+	watchlist string
+	symbols   []string
+	intervals []string
+	snapshots []models.SymbolSnapshot
+}
+
+// NewBasketAnalyzer creates an analyzer that covers every symbol of a
+// watchlist with one cycle: each cycle produces per-symbol snapshots and a
+// single basket report
+func NewBasketAnalyzer(ctx context.Context, watchlist string, symbols, intervals []string, cfg config.Config, logger zerolog.Logger, reportMgr *report.ReportManager) *MarketAnalyzer {
+	ma := NewMarketAnalyzer(ctx, watchlist, intervals, cfg, logger, reportMgr)
+	ma.basket = &basket{
+		name:      watchlist,
+		symbols:   symbols,
+		klines:    make(map[string]map[string][]models.Kline),
+		benchmark: cfg.Correlation.Bench(),
+		window:    cfg.Correlation.Returns(),
+		stats:     cfg.Correlation.Prompt,
+	}
+	return ma
+}
+
+// Snapshots returns the per-symbol snapshots of the last basket fetch, or
+// nil for a single-symbol analyzer
+func (ma *MarketAnalyzer) Snapshots() []models.SymbolSnapshot {
+	if ma.basket == nil {
+		return nil
+	}
+	ma.mu.RLock()
+	defer ma.mu.RUnlock()
+	return ma.basket.snapshots
+}
+
+// Symbols returns the symbols a basket analyzer covers
+func (ma *MarketAnalyzer) Symbols() []string {
+	if ma.basket == nil {
+		return []string{ma.symbol}
+	}
+	return ma.basket.symbols
+}
+
+// ticker24h is a 24h rolling window ticker from the exchange
+type ticker24h struct {
+	Symbol             string `json:"symbol"`
+	LastPrice          string `json:"lastPrice"`
+	PriceChangePercent string `json:"priceChangePercent"`
+	HighPrice          string `json:"highPrice"`
+	LowPrice           string `json:"lowPrice"`
+	QuoteVolume        string `json:"quoteVolume"`
+}
+
+// fetchBasket fetches the 24h tickers of all symbols in one batched request
+// and the klines of each symbol. A symbol whose klines fail is reported in
+// its snapshot instead of failing the basket.
+func (ma *MarketAnalyzer) fetchBasket(ctx context.Context) (err error) {
+	b := ma.basket
+	ctx, span := tracing.Tracer().Start(ctx, "FetchBasket", trace.WithAttributes(
+		attribute.String("watchlist", b.name),
+		attribute.Int("symbols", len(b.symbols)),
+		attribute.StringSlice("intervals", ma.intervals),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	list, _ := json.Marshal(b.symbols)
+	tickerURL := "https://api1.binance.com/api/v3/ticker/24hr?symbols=" + url.QueryEscape(string(list))
+	resp, err := ma.get(ctx, "binance.ticker24hr", tickerURL)
+	if err != nil {
+		ma.logger.Error().Err(err).Str("watchlist", b.name).Msg("Fetch basket tickers error")
+		return fmt.Errorf("fetch tickers failed: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("fetch tickers failed: status %d: %s", resp.StatusCode(), resp.String())
+	}
+	var tickers []ticker24h
+	if err := json.Unmarshal(resp.Body(), &tickers); err != nil {
+		return fmt.Errorf("unmarshal tickers failed: %w", err)
+	}
+	bySymbol := make(map[string]ticker24h, len(tickers))
+	for _, t := range tickers {
+		bySymbol[t.Symbol] = t
+	}
+
+	klines := make(map[string]map[string][]models.Kline, len(b.symbols)+1)
+	snapshots := make([]models.SymbolSnapshot, 0, len(b.symbols))
+	// The correlation window needs window+1 closed candles and the live one
+	limit := max(basketKlineLimit, b.window+2)
+	now := time.Now().UnixMilli()
+	for _, symbol := range b.symbols {
+		t := bySymbol[symbol]
+		snap := models.SymbolSnapshot{
+			Symbol:         symbol,
+			LastPrice:      parseNum(t.LastPrice),
+			ChangePct24h:   parseNum(t.PriceChangePercent),
+			High24h:        parseNum(t.HighPrice),
+			Low24h:         parseNum(t.LowPrice),
+			QuoteVolume24h: parseNum(t.QuoteVolume),
+			Changes:        make(map[string]float64, len(ma.intervals)),
+			Timestamp:      now,
+		}
+		klines[symbol] = make(map[string][]models.Kline, len(ma.intervals))
+		for _, interval := range ma.intervals {
+			klineURL := fmt.Sprintf("https://api1.binance.com/api/v3/klines?symbol=%s&interval=%s&limit=%d", symbol, interval, limit)
+			resp, err := ma.get(ctx, "binance.klines "+interval, klineURL)
+			if err != nil && ctx.Err() != nil {
+				return fmt.Errorf("fetch klines failed: %w", err)
+			}
+			var ks []models.Kline
+			if err == nil {
+				ks, err = parseKlines(resp.Body())
+			}
+			if err != nil {
+				ma.logger.Warn().Err(err).Str("symbol", symbol).Str("interval", interval).Msg("Fetch basket klines error")
+				snap.Error = fmt.Sprintf("%s klines: %v", interval, err)
+				continue
+			}
+			klines[symbol][interval] = ks
+			if closed := closedKlines(ks); len(closed) > 0 {
+				if open := parseNum(closed[0].Open); open > 0 {
+					snap.Changes[interval] = (parseNum(closed[len(closed)-1].Close) - open) / open * 100
+				}
+			}
+		}
+		snapshots = append(snapshots, snap)
+	}
+	if !slices.Contains(b.symbols, b.benchmark) {
+		klines[b.benchmark] = make(map[string][]models.Kline, len(ma.intervals))
+		for _, interval := range ma.intervals {
+			klineURL := fmt.Sprintf("https://api1.binance.com/api/v3/klines?symbol=%s&interval=%s&limit=%d", b.benchmark, interval, limit)
+			resp, err := ma.get(ctx, "binance.klines "+interval, klineURL)
+			if err != nil && ctx.Err() != nil {
+				return fmt.Errorf("fetch klines failed: %w", err)
+			}
+			var ks []models.Kline
+			if err == nil {
+				ks, err = parseKlines(resp.Body())
+			}
+			if err != nil {
+				ma.logger.Warn().Err(err).Str("symbol", b.benchmark).Str("interval", interval).Msg("Fetch benchmark klines error")
+				continue
+			}
+			klines[b.benchmark][interval] = ks
+		}
+	}
+
+	ma.mu.Lock()
+	b.klines, b.snapshots = klines, snapshots
+	ma.mu.Unlock()
+	ma.logger.Info().Str("watchlist", b.name).Int("symbols", len(b.symbols)).Msg("Fetched basket")
+	return nil
+}
+
+// closedKlines drops the live candle from the end of a series
+func closedKlines(ks []models.Kline) []models.Kline {
+	if n := len(ks); n > 0 && !ks[n-1].Closed {
+		return ks[:n-1]
+	}
+	return ks
+}
+
+// basketData returns a copy of what the next basket analysis is based on
+func (ma *MarketAnalyzer) basketData() basketData {
+	ma.mu.RLock()
+	defer ma.mu.RUnlock()
+	return basketData{
+		watchlist: ma.basket.name,
+		symbols:   ma.basket.symbols,
+		intervals: ma.intervals,
+		snapshots: ma.basket.snapshots,
+	}
+}
+
+// basketPrompt renders the basket prompt, leaving out kline detail until
+// the estimated token count fits the analyzer's budget
+func (ma *MarketAnalyzer) basketPrompt() (string, int) {
+	budget := ma.aiConfig().tokenBudget
+	ma.mu.RLock()
+	defer ma.mu.RUnlock()
+	var prompt string
+	var tokens int
+	// Levels: summaries with the live candle, summaries only, tickers only
+	for detail := 2; detail >= 0; detail-- {
+		data := ma.compactBasket(detail)
+		prompt = ma.renderBasketPrompt(data, 0, budget)
+		tokens = EstimateTokens(prompt)
+		prompt = ma.renderBasketPrompt(data, tokens, budget)
+		if tokens <= budget {
+			break
+		}
+	}
+	if tokens > budget {
+		ma.logger.Warn().Int("estimated_tokens", tokens).Int("token_budget", budget).Msg("Basket prompt exceeds token budget at leanest compaction")
+	}
+	return prompt, tokens
+}
+
+// compactBasket encodes the basket's tickers and, depending on detail, a
+// summary line per symbol and interval and the live candle
+func (ma *MarketAnalyzer) compactBasket(detail int) [3]string {
+	var tickers strings.Builder
+	tickers.WriteString("symbol,last,chg24h%,high24h,low24h,quote_vol24h\n")
+	for _, s := range ma.basket.snapshots {
+		fmt.Fprintf(&tickers, "%s,%s,%+.2f,%s,%s,%s\n", s.Symbol, formatNum(s.LastPrice), s.ChangePct24h,
+			formatNum(s.High24h), formatNum(s.Low24h), formatNum(s.QuoteVolume24h))
+	}
+	if detail == 0 {
+		return [3]string{strings.TrimRight(tickers.String(), "\n"), "omitted", ""}
+	}
+	var klines strings.Builder
+	for _, symbol := range ma.basket.symbols {
+		for _, interval := range ma.intervals {
+			ks := ma.basket.klines[symbol][interval]
+			if closed := closedKlines(ks); len(closed) > 0 {
+				fmt.Fprintf(&klines, "[%s %s] %s\n", symbol, interval, summarizeKlines(closed))
+			}
+			if n := len(ks); detail == 2 && n > 0 && !ks[n-1].Closed {
+				live := ks[n-1]
+				fmt.Fprintf(&klines, "[%s %s] live (in progress until %s): open=%s high=%s low=%s close=%s vol=%s\n",
+					symbol, interval, formatMillis(live.CloseTime+1),
+					trimNum(live.Open), trimNum(live.High), trimNum(live.Low), trimNum(live.Close), trimNum(live.Volume))
+			}
+		}
+	}
+	var stats string
+	if ma.basket.stats {
+		stats = ma.compactCorrelation()
+	}
+	return [3]string{strings.TrimRight(tickers.String(), "\n"), strings.TrimRight(klines.String(), "\n"), stats}
+}
+
+// renderBasketPrompt fills the basket analysis template
+func (ma *MarketAnalyzer) renderBasketPrompt(data [3]string, tokens, budget int) string {
+	var stats string
+	if data[2] != "" {
+		stats = "- 联动统计: 按周期列出各交易对在最近若干根已收盘 K 线上的收益率、相对基准的超额收益、与基准的相关系数、beta 以及与篮子内其他交易对的平均相关系数，按收益率排名，可据此判断行情由大盘驱动还是个别交易对独立走势\n```\n" + data[2] + "\n```\n"
+	}
+	return fmt.Sprintf(`## 数字资产篮子动态分析报告
+
+**输入数据**（CSV 表格，时间为 UTC）:
+- 观察列表: %s（%d 个交易对）
+- 24 小时行情:
+`+"```"+`
+%s
+`+"```"+`
+- K线统计: 周期包括 %v；每个交易对每个周期一行，summary 只统计已收盘 K 线，live 行为尚未收盘的当前 K 线，其数值仍会变化，不应视为确认信号
+`+"```"+`
+%s
+`+"```"+`
+%s- 估算 Token 数: %d（预算 %d）
+## 分析任务
+1. 篮子整体趋势与强弱
+2. 领涨/领跌交易对、相对强弱与板块内轮动迹象
+3. 逐个交易对的简要研判
+4. 篮子层面的风险预警（同涨同跌、放量异动、单一交易对拖累等）
+
+## 输出格式
+仅输出一个 JSON 对象，须符合以下 JSON Schema（trend_direction 取 bullish/bearish/neutral，leaders、laggards 与 symbol_views 中的交易对须来自上面的列表）：
+`+"```json"+`
+%s
+`+"```"+`
+`,
+		ma.basket.name, len(ma.basket.symbols), data[0], ma.intervals, data[1], stats, tokens, budget, basketSchemaText)
+}
+
+// basketSchemaText is the minified basket schema embedded in prompts
+var basketSchemaText = func() string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, report.BasketSchema()); err != nil {
+		return string(report.BasketSchema())
+	}
+	return buf.String()
+}()
+
+// callBasketAnalysis requests the basket report. Baskets are analyzed by a
+// single backend: the primary one, or else the first ensemble member.
+func (ma *MarketAnalyzer) callBasketAnalysis(ctx context.Context) (resp AnalysisResponse, err error) {
+	analysisID := uuid.New().String()
+	ctx, span := tracing.Tracer().Start(ctx, "CallBasketAnalysis", trace.WithAttributes(
+		attribute.String("watchlist", ma.basket.name),
+		attribute.String("analysis_id", analysisID),
+	))
+	defer func() {
+		span.SetAttributes(attribute.String("report_id", resp.ReportID))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	prompt, tokens := ma.basketPrompt()
+	data := ma.basketData()
+	settings := ma.aiConfig()
+	backend := settings.primary
+	if backend == nil && len(settings.ensemble) > 0 {
+		backend = &settings.ensemble[0]
+	}
+	if backend == nil {
+		p := ma.pending(prompt)
+		p.basket = &data
+		globalPromptsMu.Lock()
+		globalPendingPrompts[analysisID] = p
+		globalPromptsMu.Unlock()
+		ma.logger.Info().Str("analysis_id", analysisID).Int("estimated_tokens", tokens).Msg("Manual AI mode, stored pending basket prompt")
+		return AnalysisResponse{AnalysisID: analysisID}, nil
+	}
+	if paused, reason := ma.AIPaused(); paused {
+		ma.logger.Warn().Str("monitor_id", ma.monitorID).Str("reason", reason).Msg("AI calls paused by budget")
+		return AnalysisResponse{AnalysisID: analysisID}, fmt.Errorf("%w: %s", ErrBudgetExceeded, reason)
+	}
+	ma.logger.Info().Str("analysis_id", analysisID).Str("watchlist", data.watchlist).Int("estimated_tokens", tokens).Msg("Requesting basket AI analysis")
+	res, err := ma.runStructured(ctx, *backend, settings.maxRepairs, analysisID, prompt, report.BasketSchema(), func(raw string, usage models.Usage) (string, error) {
+		rep, err := report.ParseBasket(raw, data.symbols)
+		if err != nil {
+			return "", err
+		}
+		rep.Usage = &usage
+		return ma.saveBasketReport(rep, data)
+	})
+	return AnalysisResponse{AnalysisID: analysisID, ReportID: res.reportID}, err
+}
+
+// submitBasketResponse validates and saves a manual reply to a basket prompt
+func (ma *MarketAnalyzer) submitBasketResponse(analysisID, responseJSON string, data basketData) (AnalysisResponse, error) {
+	rep, err := report.ParseBasket(responseJSON, data.symbols)
+	if err != nil {
+		ma.saveFailed(analysisID, []string{responseJSON}, err, nil)
+		return AnalysisResponse{AnalysisID: analysisID}, err
+	}
+	reportID, err := ma.saveBasketReport(rep, data)
+	if err != nil {
+		return AnalysisResponse{}, err
+	}
+	return AnalysisResponse{AnalysisID: analysisID, ReportID: reportID}, nil
+}
+
+// saveBasketReport fills in the basket's data and stores a validated report
+func (ma *MarketAnalyzer) saveBasketReport(rep models.BasketReport, data basketData) (string, error) {
+	reportID := uuid.New().String()
+	rep.ReportID = reportID
+	rep.Kind = "basket"
+	rep.Watchlist = data.watchlist
+	rep.Symbols = data.symbols
+	rep.Timeframe = data.intervals
+	rep.Timestamp = time.Now().UnixMilli()
+	rep.Snapshots = data.snapshots
+	rep.OwnerID = ma.ownerID
+	rep.WorkspaceID = ma.workspace()
+	rep.MonitorID = ma.monitorID
+	out, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal report failed: %w", err)
+	}
+	if err := ma.reportMgr.SaveReport(reportID, string(out)); err != nil {
+		return "", err
+	}
+	metrics.CountReport("basket")
+	return reportID, nil
+}
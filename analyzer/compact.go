@@ -0,0 +1,332 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/songzhibin97/CryptoPulse/models"
+)
+
+// compactLevel controls how much raw data a compacted prompt keeps
+type compactLevel struct {
+	recentKlines int
+	tradeBuckets int
+	largeTrades  int
+	bookLevels   int
+}
+
+// compactLevels are tried from richest to leanest until the prompt fits the budget
+var compactLevels = []compactLevel{
+	{recentKlines: 30, tradeBuckets: 30, largeTrades: 10, bookLevels: 25},
+	{recentKlines: 20, tradeBuckets: 20, largeTrades: 8, bookLevels: 15},
+	{recentKlines: 12, tradeBuckets: 12, largeTrades: 5, bookLevels: 10},
+	{recentKlines: 6, tradeBuckets: 6, largeTrades: 3, bookLevels: 5},
+}
+
+// compactData holds the tabular encodings of the market data sections
+type compactData struct {
+	klines    string
+	orderBook string
+	trades    string
+}
+
+// EstimateTokens gives a rough token count for a prompt: about four ASCII
+// characters per token, and one token per non-ASCII rune (CJK text)
+func EstimateTokens(s string) int {
+	ascii, other := 0, 0
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// compactMarketData encodes klines, order book and trades at the given level
+func compactMarketData(intervals []string, klines map[string][]models.Kline, book models.OrderBook, trades []map[string]interface{}, level compactLevel) compactData {
+	return compactData{
+		klines:    compactKlines(intervals, klines, level.recentKlines),
+		orderBook: compactOrderBook(book, level.bookLevels),
+		trades:    compactTrades(trades, level.tradeBuckets, level.largeTrades),
+	}
+}
+
+// compactKlines summarizes older klines into statistics and keeps the most
+// recent ones as CSV rows. The live candle that is still forming is kept out
+// of both and shown on its own line.
+func compactKlines(intervals []string, klines map[string][]models.Kline, recent int) string {
+	var sb strings.Builder
+	for _, interval := range intervals {
+		ks := klines[interval]
+		var live *models.Kline
+		if n := len(ks); n > 0 && !ks[n-1].Closed {
+			live, ks = &ks[n-1], ks[:n-1]
+		}
+		if len(ks) == 0 && live == nil {
+			continue
+		}
+		fmt.Fprintf(&sb, "[%s]\n", interval)
+		if len(ks) > 0 {
+			writeClosedKlines(&sb, ks, recent)
+		}
+		if live != nil {
+			fmt.Fprintf(&sb, "live (in progress until %s): %s,%s,%s,%s,%s,%s\n", formatMillis(live.CloseTime+1), formatMillis(live.OpenTime),
+				trimNum(live.Open), trimNum(live.High), trimNum(live.Low), trimNum(live.Close), trimNum(live.Volume))
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// writeClosedKlines writes a summary of the older closed klines and the most
+// recent ones as CSV rows
+func writeClosedKlines(sb *strings.Builder, ks []models.Kline, recent int) {
+	split := len(ks) - recent
+	if split < 0 {
+		split = 0
+	}
+	if split > 0 {
+		sb.WriteString(summarizeKlines(ks[:split]))
+		sb.WriteByte('\n')
+	}
+	sb.WriteString("time,open,high,low,close,volume\n")
+	for _, k := range ks[split:] {
+		fmt.Fprintf(sb, "%s,%s,%s,%s,%s,%s\n", formatMillis(k.OpenTime),
+			trimNum(k.Open), trimNum(k.High), trimNum(k.Low), trimNum(k.Close), trimNum(k.Volume))
+	}
+}
+
+// summarizeKlines reduces a kline series to a single statistics line
+func summarizeKlines(ks []models.Kline) string {
+	first, last := ks[0], ks[len(ks)-1]
+	open, closePrice := parseNum(first.Open), parseNum(last.Close)
+	high, low := math.Inf(-1), math.Inf(1)
+	var volume float64
+	returns := make([]float64, 0, len(ks))
+	prevClose := open
+	for _, k := range ks {
+		high = math.Max(high, parseNum(k.High))
+		low = math.Min(low, parseNum(k.Low))
+		volume += parseNum(k.Volume)
+		c := parseNum(k.Close)
+		if prevClose > 0 {
+			returns = append(returns, (c-prevClose)/prevClose)
+		}
+		prevClose = c
+	}
+	change := 0.0
+	if open > 0 {
+		change = (closePrice - open) / open * 100
+	}
+	return fmt.Sprintf("summary n=%d from=%s to=%s open=%s high=%s low=%s close=%s chg=%+.2f%% vol=%s avg_vol=%s ret_sd=%.3f%%",
+		len(ks), formatMillis(first.OpenTime), formatMillis(last.OpenTime),
+		formatNum(open), formatNum(high), formatNum(low), formatNum(closePrice), change,
+		formatNum(volume), formatNum(volume/float64(len(ks))), stddev(returns)*100)
+}
+
+// compactOrderBook keeps the levels closest to the spread and aggregates
+// depth into bands around the mid price
+func compactOrderBook(book models.OrderBook, levels int) string {
+	bids := sortedLevels(book.Bids, true)
+	asks := sortedLevels(book.Asks, false)
+	if len(bids) == 0 || len(asks) == 0 {
+		return "empty"
+	}
+	mid := (bids[0].price + asks[0].price) / 2
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "mid=%s spread=%s\n", formatNum(mid), formatNum(asks[0].price-bids[0].price))
+	sb.WriteString("band,bid_qty,ask_qty,imbalance\n")
+	for _, band := range []float64{0.001, 0.005, 0.01, 0.02} {
+		bidQty := sumWithin(bids, mid, band)
+		askQty := sumWithin(asks, mid, band)
+		imbalance := 0.0
+		if bidQty+askQty > 0 {
+			imbalance = (bidQty - askQty) / (bidQty + askQty)
+		}
+		fmt.Fprintf(&sb, "%.1f%%,%s,%s,%+.2f\n", band*100, formatNum(bidQty), formatNum(askQty), imbalance)
+	}
+	sb.WriteString("side,price,qty\n")
+	for i := 0; i < levels && i < len(bids); i++ {
+		fmt.Fprintf(&sb, "b,%s,%s\n", formatNum(bids[i].price), formatNum(bids[i].qty))
+	}
+	for i := 0; i < levels && i < len(asks); i++ {
+		fmt.Fprintf(&sb, "a,%s,%s\n", formatNum(asks[i].price), formatNum(asks[i].qty))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// compactTrades aggregates aggTrades into time buckets and lists the largest trades
+func compactTrades(trades []map[string]interface{}, buckets, largest int) string {
+	parsed := parseTrades(trades)
+	if len(parsed) == 0 || buckets <= 0 {
+		return "empty"
+	}
+	start, end := parsed[0].time, parsed[len(parsed)-1].time
+	width := (end - start + int64(buckets)) / int64(buckets)
+	if width <= 0 {
+		width = 1
+	}
+
+	type bucket struct {
+		count           int
+		buyQty, sellQty float64
+		notional, qty   float64
+	}
+	agg := make([]bucket, buckets)
+	var buyQty, sellQty float64
+	for _, t := range parsed {
+		i := int((t.time - start) / width)
+		if i >= buckets {
+			i = buckets - 1
+		}
+		b := &agg[i]
+		b.count++
+		b.notional += t.price * t.qty
+		b.qty += t.qty
+		if t.buyerMaker {
+			b.sellQty += t.qty
+			sellQty += t.qty
+		} else {
+			b.buyQty += t.qty
+			buyQty += t.qty
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "trades=%d from=%s to=%s bucket=%s buy_qty=%s sell_qty=%s\n",
+		len(parsed), formatMillis(start), formatMillis(end), time.Duration(width)*time.Millisecond,
+		formatNum(buyQty), formatNum(sellQty))
+	sb.WriteString("time,n,buy_qty,sell_qty,vwap\n")
+	for i, b := range agg {
+		if b.count == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "%s,%d,%s,%s,%s\n", formatMillis(start+int64(i)*width), b.count,
+			formatNum(b.buyQty), formatNum(b.sellQty), formatNum(b.notional/b.qty))
+	}
+
+	sort.Slice(parsed, func(i, j int) bool { return parsed[i].qty > parsed[j].qty })
+	sb.WriteString("large: time,side,price,qty\n")
+	for i := 0; i < largest && i < len(parsed); i++ {
+		t := parsed[i]
+		side := "buy"
+		if t.buyerMaker {
+			side = "sell"
+		}
+		fmt.Fprintf(&sb, "%s,%s,%s,%s\n", formatMillis(t.time), side, formatNum(t.price), formatNum(t.qty))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+type bookLevel struct {
+	price float64
+	qty   float64
+}
+
+// sortedLevels orders book levels from the best price outwards
+func sortedLevels(side map[string]float64, desc bool) []bookLevel {
+	levels := make([]bookLevel, 0, len(side))
+	for p, q := range side {
+		price, err := strconv.ParseFloat(p, 64)
+		if err != nil || q <= 0 {
+			continue
+		}
+		levels = append(levels, bookLevel{price: price, qty: q})
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		if desc {
+			return levels[i].price > levels[j].price
+		}
+		return levels[i].price < levels[j].price
+	})
+	return levels
+}
+
+// sumWithin sums quantity of levels within band (fraction) of mid
+func sumWithin(levels []bookLevel, mid, band float64) float64 {
+	var total float64
+	for _, l := range levels {
+		if math.Abs(l.price-mid)/mid > band {
+			break
+		}
+		total += l.qty
+	}
+	return total
+}
+
+type aggTrade struct {
+	time       int64
+	price      float64
+	qty        float64
+	buyerMaker bool
+}
+
+// parseTrades converts raw aggTrades into typed trades sorted by time
+func parseTrades(trades []map[string]interface{}) []aggTrade {
+	parsed := make([]aggTrade, 0, len(trades))
+	for _, t := range trades {
+		p, _ := t["p"].(string)
+		q, _ := t["q"].(string)
+		ts, _ := t["T"].(float64)
+		m, _ := t["m"].(bool)
+		price, qty := parseNum(p), parseNum(q)
+		if price <= 0 || qty <= 0 {
+			continue
+		}
+		parsed = append(parsed, aggTrade{time: int64(ts), price: price, qty: qty, buyerMaker: m})
+	}
+	sort.Slice(parsed, func(i, j int) bool { return parsed[i].time < parsed[j].time })
+	return parsed
+}
+
+func stddev(xs []float64) float64 {
+	if len(xs) < 2 {
+		return 0
+	}
+	var mean float64
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	var variance float64
+	for _, x := range xs {
+		variance += (x - mean) * (x - mean)
+	}
+	return math.Sqrt(variance / float64(len(xs)-1))
+}
+
+func parseNum(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// trimNum strips trailing zeros from Binance decimal strings
+func trimNum(s string) string {
+	if !strings.Contains(s, ".") {
+		return s
+	}
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// formatNum prints a float rounded to 8 significant digits without exponent
+// or trailing zeros
+func formatNum(f float64) string {
+	if f == 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	scale := math.Pow(10, 7-math.Floor(math.Log10(math.Abs(f))))
+	return strconv.FormatFloat(math.Round(f*scale)/scale, 'f', -1, 64)
+}
+
+// formatMillis prints a millisecond timestamp as a compact UTC time
+func formatMillis(ms int64) string {
+	return time.UnixMilli(ms).UTC().Format("01-02T15:04")
+}
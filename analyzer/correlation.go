@@ -0,0 +1,207 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/songzhibin97/CryptoPulse/models"
+)
+
+// errNotBasket is returned for watchlist statistics of a single-symbol analyzer
+var errNotBasket = errors.New("analyzer does not cover a watchlist")
+
+// Correlation returns the return correlations, betas and relative strength
+// of the basket's symbols over the last window candles of interval, taken
+// from the klines of the last fetch. A window of zero uses the configured one.
+func (ma *MarketAnalyzer) Correlation(interval string, window int) (models.CorrelationMatrix, error) {
+	if ma.basket == nil {
+		return models.CorrelationMatrix{}, errNotBasket
+	}
+	ma.mu.RLock()
+	defer ma.mu.RUnlock()
+	if window <= 0 {
+		window = ma.basket.window
+	}
+	return correlate(interval, ma.basket.klines, ma.basket.symbols, ma.basket.benchmark, window)
+}
+
+// correlate computes the statistics over the returns between the last
+// window+1 closes that all series share
+func correlate(interval string, klines map[string]map[string][]models.Kline, symbols []string, benchmark string, window int) (models.CorrelationMatrix, error) {
+	out := models.CorrelationMatrix{Interval: interval, Benchmark: benchmark, Timestamp: time.Now().UnixMilli()}
+	series := symbols
+	if !slices.Contains(symbols, benchmark) {
+		series = append(slices.Clone(symbols), benchmark)
+	}
+
+	// Closes by close time, and the close times every series has
+	closes := make(map[string]map[int64]float64, len(series))
+	var common map[int64]bool
+	for _, symbol := range series {
+		ks := closedKlines(klines[symbol][interval])
+		if len(ks) == 0 {
+			out.Missing = append(out.Missing, symbol)
+			continue
+		}
+		byTime := make(map[int64]float64, len(ks))
+		for _, k := range ks {
+			byTime[k.CloseTime] = parseNum(k.Close)
+		}
+		closes[symbol] = byTime
+		out.Symbols = append(out.Symbols, symbol)
+		if common == nil {
+			common = make(map[int64]bool, len(byTime))
+			for t := range byTime {
+				common[t] = true
+			}
+			continue
+		}
+		for t := range common {
+			if _, ok := byTime[t]; !ok {
+				delete(common, t)
+			}
+		}
+	}
+	times := make([]int64, 0, len(common))
+	for t := range common {
+		times = append(times, t)
+	}
+	slices.Sort(times)
+	if len(times) > window+1 {
+		times = times[len(times)-window-1:]
+	}
+	if len(times) < 3 {
+		return out, fmt.Errorf("not enough overlapping %s candles", interval)
+	}
+	out.Window = len(times) - 1
+
+	returns := make(map[string][]float64, len(out.Symbols))
+	totals := make(map[string]float64, len(out.Symbols))
+	for _, symbol := range out.Symbols {
+		rs := make([]float64, 0, out.Window)
+		for i := 1; i < len(times); i++ {
+			prev := closes[symbol][times[i-1]]
+			if prev == 0 {
+				rs = append(rs, 0)
+				continue
+			}
+			rs = append(rs, closes[symbol][times[i]]/prev-1)
+		}
+		returns[symbol] = rs
+		if first := closes[symbol][times[0]]; first > 0 {
+			totals[symbol] = (closes[symbol][times[len(times)-1]]/first - 1) * 100
+		}
+	}
+
+	n := len(out.Symbols)
+	out.Matrix = make([][]float64, n)
+	for i, a := range out.Symbols {
+		out.Matrix[i] = make([]float64, n)
+		for j, b := range out.Symbols {
+			c := 1.0
+			if i != j {
+				c = round(pearson(returns[a], returns[b]), 4)
+			}
+			out.Matrix[i][j] = c
+			out.Heatmap = append(out.Heatmap, models.HeatmapCell{X: b, Y: a, Value: c})
+		}
+	}
+
+	bench, hasBench := returns[benchmark]
+	for i, symbol := range out.Symbols {
+		if !slices.Contains(symbols, symbol) {
+			continue
+		}
+		rs := models.RelativeStrength{Symbol: symbol, ReturnPct: round(totals[symbol], 2)}
+		if hasBench {
+			rs.ExcessPct = round(totals[symbol]-totals[benchmark], 2)
+			rs.Correlation = round(pearson(returns[symbol], bench), 4)
+			rs.Beta = round(beta(returns[symbol], bench), 4)
+		}
+		var sum float64
+		var others int
+		for j, other := range out.Symbols {
+			if j != i && slices.Contains(symbols, other) {
+				sum += out.Matrix[i][j]
+				others++
+			}
+		}
+		if others > 0 {
+			rs.AvgCorrelation = round(sum/float64(others), 4)
+		}
+		out.Ranking = append(out.Ranking, rs)
+	}
+	sort.SliceStable(out.Ranking, func(i, j int) bool { return out.Ranking[i].ReturnPct > out.Ranking[j].ReturnPct })
+	for i := range out.Ranking {
+		out.Ranking[i].Rank = i + 1
+	}
+	return out, nil
+}
+
+// compactCorrelation encodes the ranking of each interval for the prompt
+func (ma *MarketAnalyzer) compactCorrelation() string {
+	var sb strings.Builder
+	for _, interval := range ma.intervals {
+		m, err := correlate(interval, ma.basket.klines, ma.basket.symbols, ma.basket.benchmark, ma.basket.window)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&sb, "[%s, last %d returns, benchmark %s]\n", interval, m.Window, m.Benchmark)
+		sb.WriteString("rank,symbol,ret%,excess%,corr_bench,beta,avg_corr\n")
+		for _, rs := range m.Ranking {
+			fmt.Fprintf(&sb, "%d,%s,%+.2f,%+.2f,%.2f,%.2f,%.2f\n", rs.Rank, rs.Symbol, rs.ReturnPct, rs.ExcessPct, rs.Correlation, rs.Beta, rs.AvgCorrelation)
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// pearson returns the correlation of two equally long series, or zero when
+// either does not vary
+func pearson(a, b []float64) float64 {
+	ma, mb := mean(a), mean(b)
+	var cov, va, vb float64
+	for i := range a {
+		cov += (a[i] - ma) * (b[i] - mb)
+		va += (a[i] - ma) * (a[i] - ma)
+		vb += (b[i] - mb) * (b[i] - mb)
+	}
+	if va == 0 || vb == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(va*vb)
+}
+
+// beta returns the sensitivity of a's returns to the benchmark's
+func beta(a, bench []float64) float64 {
+	ma, mb := mean(a), mean(bench)
+	var cov, vb float64
+	for i := range a {
+		cov += (a[i] - ma) * (bench[i] - mb)
+		vb += (bench[i] - mb) * (bench[i] - mb)
+	}
+	if vb == 0 {
+		return 0
+	}
+	return cov / vb
+}
+
+func mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+func round(f float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(f*p) / p
+}
@@ -0,0 +1,114 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/models"
+	"github.com/songzhibin97/CryptoPulse/report"
+)
+
+// newEnsemble builds the backends for cfg.AIEnsemble and returns the prompt
+// token budget, which is the smallest budget among the ensemble's models
+func newEnsemble(cfg config.Config, transport http.RoundTripper, logger zerolog.Logger) ([]aiBackend, int) {
+	budget := cfg.TokenBudget()
+	if len(cfg.AIEnsemble) == 0 {
+		return nil, budget
+	}
+	members := make([]aiBackend, 0, len(cfg.AIEnsemble))
+	budget = 0
+	for _, name := range cfg.AIEnsemble {
+		b, ok := cfg.Backend(name)
+		if !ok {
+			logger.Error().Str("backend", name).Msg("Unknown AI backend in ensemble")
+			continue
+		}
+		member, err := newAIBackend(cfg, b, transport)
+		if err != nil {
+			logger.Error().Err(err).Str("backend", name).Msg("Invalid AI backend in ensemble")
+			continue
+		}
+		members = append(members, member)
+		if tb := cfg.TokenBudgetFor(b.Model); budget == 0 || tb < budget {
+			budget = tb
+		}
+	}
+	if budget == 0 {
+		budget = cfg.TokenBudget()
+	}
+	return members, budget
+}
+
+// runEnsemble sends the same prompt to every ensemble member concurrently,
+// waits up to the ensemble timeout, stores each member's report for
+// comparison and merges the successful ones into a consensus report, which
+// is the only one published
+func (ma *MarketAnalyzer) runEnsemble(ctx context.Context, settings aiSettings, analysisID, prompt string) (AnalysisResponse, error) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, settings.ensembleTimeout)
+	defer cancel()
+
+	type result struct {
+		analysisResult
+		err error
+	}
+	results := make([]result, len(settings.ensemble))
+	var wg sync.WaitGroup
+	for i, m := range settings.ensemble {
+		wg.Add(1)
+		go func(i int, m aiBackend) {
+			defer wg.Done()
+			res, err := ma.runStructuredAnalysis(ctx, m, settings.maxRepairs, analysisID+"-"+m.name, analysisID, prompt)
+			results[i] = result{analysisResult: res, err: err}
+		}(i, m)
+	}
+	wg.Wait()
+
+	var reports []models.Report
+	usage := models.Usage{Backend: "ensemble"}
+	members := make([]models.EnsembleMember, 0, len(settings.ensemble))
+	for i, m := range settings.ensemble {
+		r := results[i]
+		usage.Add(r.usage)
+		member := models.EnsembleMember{Backend: m.name, Model: m.model, ReportID: r.reportID}
+		if r.err != nil {
+			member.Error = r.err.Error()
+			if errors.Is(r.err, context.DeadlineExceeded) {
+				member.Error = "timed out"
+			}
+			ma.logger.Warn().Err(r.err).Str("analysis_id", analysisID).Str("backend", m.name).Msg("Ensemble member failed")
+		} else {
+			member.TrendDirection = r.report.TechnicalAnalysis.TrendDirection
+			reports = append(reports, r.report)
+		}
+		members = append(members, member)
+	}
+	if len(reports) == 0 {
+		return AnalysisResponse{AnalysisID: analysisID}, fmt.Errorf("all %d ensemble members failed", len(members))
+	}
+
+	consensus := report.Consensus(reports)
+	consensus.Ensemble.Members = members
+	usage.LatencyMs = time.Since(start).Milliseconds()
+	consensus.Usage = &usage
+	reportID, err := ma.saveReport(consensus)
+	if err != nil {
+		return AnalysisResponse{AnalysisID: analysisID}, err
+	}
+	ma.logger.Info().
+		Str("analysis_id", analysisID).
+		Str("report_id", reportID).
+		Int("succeeded", len(reports)).
+		Int("members", len(members)).
+		Str("trend_direction", consensus.TechnicalAnalysis.TrendDirection).
+		Float64("trend_agreement", consensus.Ensemble.TrendAgreement).
+		Float64("cost_usd", usage.CostUSD).
+		Msg("Saved consensus report")
+	return AnalysisResponse{AnalysisID: analysisID, ReportID: reportID}, nil
+}
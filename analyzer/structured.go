@@ -0,0 +1,283 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/songzhibin97/CryptoPulse/ai"
+	"github.com/songzhibin97/CryptoPulse/metrics"
+	"github.com/songzhibin97/CryptoPulse/models"
+	"github.com/songzhibin97/CryptoPulse/report"
+	"github.com/songzhibin97/CryptoPulse/tracing"
+	"github.com/songzhibin97/CryptoPulse/usage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const systemPrompt = "你是一名专业的数字资产市场分析师。只输出一个符合给定 JSON Schema 的 JSON 对象，不要输出任何其他内容。"
+
+// reportKlines is the number of candles per interval stored with a report
+const reportKlines = 50
+
+// reportSchemaText is the minified report schema embedded in prompts
+var reportSchemaText = func() string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, report.Schema()); err != nil {
+		return string(report.Schema())
+	}
+	return buf.String()
+}()
+
+// analysisResult is the outcome of one backend's structured analysis
+type analysisResult struct {
+	reportID string
+	report   models.Report
+	usage    models.Usage
+}
+
+// replyHandler validates an AI reply and stores the report it describes,
+// returning the report ID. A *report.ValidationError has the model repair
+// its reply.
+type replyHandler func(raw string, usage models.Usage) (string, error)
+
+// runStructuredAnalysis sends the prompt to an AI backend, validates the
+// reply against the report schema and re-prompts with the validation errors
+// up to maxRepairs times. Token usage, latency and cost are accumulated over
+// all attempts and stored on the report. A reply that never validates is
+// stored as a failed analysis together with every raw response. Reports
+// of ensemble members name the consensus analysis in memberOf.
+func (ma *MarketAnalyzer) runStructuredAnalysis(ctx context.Context, b aiBackend, maxRepairs int, analysisID, memberOf, prompt string) (analysisResult, error) {
+	var rep models.Report
+	res, err := ma.runStructured(ctx, b, maxRepairs, analysisID, prompt, report.Schema(), func(raw string, usage models.Usage) (string, error) {
+		parsed, err := report.Parse(raw)
+		if err != nil {
+			return "", err
+		}
+		parsed.Usage = &usage
+		parsed.EnsembleMemberOf = memberOf
+		rep = parsed
+		return ma.saveReport(parsed)
+	})
+	res.report = rep
+	return res, err
+}
+
+// runStructured is runStructuredAnalysis for any report schema; handle
+// validates and stores the replies
+func (ma *MarketAnalyzer) runStructured(ctx context.Context, b aiBackend, maxRepairs int, analysisID, prompt string, schema json.RawMessage, handle replyHandler) (analysisResult, error) {
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "ai.analysis", trace.WithAttributes(
+		attribute.String("ai.backend", b.name),
+		attribute.String("ai.provider", b.provider),
+		attribute.String("ai.model", b.model),
+	))
+	defer span.End()
+	res, err := ma.structuredAttempts(ctx, b, maxRepairs, analysisID, prompt, schema, handle)
+	outcome := "ok"
+	var verr *report.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		outcome = "invalid"
+	case err != nil:
+		outcome = "error"
+	}
+	span.SetAttributes(
+		attribute.String("ai.outcome", outcome),
+		attribute.Int("ai.attempts", res.usage.Attempts),
+		attribute.Int("ai.prompt_tokens", res.usage.PromptTokens),
+		attribute.Int("ai.completion_tokens", res.usage.CompletionTokens),
+		attribute.Float64("ai.cost_usd", res.usage.CostUSD),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, outcome)
+	}
+	metrics.ObserveAICall(b.name, outcome, time.Since(start))
+	ma.recordUsage(analysisID, res.usage, err != nil)
+	return res, err
+}
+
+// structuredAttempts runs the request/validate/repair loop for runStructured
+func (ma *MarketAnalyzer) structuredAttempts(ctx context.Context, b aiBackend, maxRepairs int, analysisID, prompt string, schema json.RawMessage, handle replyHandler) (analysisResult, error) {
+	messages := []ai.Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: prompt},
+	}
+	res := analysisResult{usage: models.Usage{Backend: b.name, Provider: b.provider, Model: b.model}}
+	var rawResponses []string
+	var lastErr error
+	for attempt := 0; attempt <= maxRepairs; attempt++ {
+		resp, err := b.client.Complete(ctx, ai.Request{Messages: messages, Schema: schema})
+		if err != nil {
+			ma.logger.Error().Err(err).Str("analysis_id", analysisID).Str("backend", b.name).Int("attempt", attempt+1).Msg("AI request failed")
+			if len(rawResponses) > 0 {
+				ma.saveFailed(analysisID, rawResponses, err, &res.usage)
+			}
+			return res, fmt.Errorf("ai request failed: %w", err)
+		}
+		rawResponses = append(rawResponses, resp.Content)
+		if resp.Model != "" {
+			res.usage.Model = resp.Model
+		}
+		res.usage.Add(models.Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			Attempts:         1,
+			LatencyMs:        resp.Latency.Milliseconds(),
+			CostUSD:          resp.Usage.CostUSD,
+		})
+
+		reportID, err := handle(resp.Content, res.usage)
+		if err == nil {
+			res.reportID = reportID
+			ma.logger.Info().
+				Str("analysis_id", analysisID).
+				Str("report_id", reportID).
+				Str("backend", b.name).
+				Int("attempts", attempt+1).
+				Int("prompt_tokens", res.usage.PromptTokens).
+				Int("completion_tokens", res.usage.CompletionTokens).
+				Float64("cost_usd", res.usage.CostUSD).
+				Msg("AI analysis validated")
+			return res, nil
+		}
+		lastErr = err
+
+		var verr *report.ValidationError
+		if !errors.As(err, &verr) {
+			break
+		}
+		ma.logger.Warn().Str("analysis_id", analysisID).Int("attempt", attempt+1).Strs("problems", verr.Problems).Msg("AI response failed validation")
+		messages = append(messages,
+			ai.Message{Role: "assistant", Content: resp.Content},
+			ai.Message{Role: "user", Content: repairPrompt(verr)},
+		)
+	}
+
+	ma.saveFailed(analysisID, rawResponses, lastErr, &res.usage)
+	return res, fmt.Errorf("ai response invalid after %d attempts: %w", len(rawResponses), lastErr)
+}
+
+// repairPrompt asks the model to fix the listed validation problems
+func repairPrompt(verr *report.ValidationError) string {
+	return "上一次输出未通过校验，问题如下：\n- " + strings.Join(verr.Problems, "\n- ") +
+		"\n请修正以上问题，重新输出完整的 JSON 对象，不要输出任何其他内容。"
+}
+
+// recordUsage stores the usage of one backend's analysis in the usage tracker
+func (ma *MarketAnalyzer) recordUsage(analysisID string, u models.Usage, failed bool) {
+	if ma.usage == nil {
+		return
+	}
+	err := ma.usage.Record(usage.Record{
+		AnalysisID:       analysisID,
+		MonitorID:        ma.monitorID,
+		OwnerID:          ma.ownerID,
+		WorkspaceID:      ma.workspace(),
+		Symbol:           ma.symbol,
+		Backend:          u.Backend,
+		Provider:         u.Provider,
+		Model:            u.Model,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		Attempts:         u.Attempts,
+		LatencyMs:        u.LatencyMs,
+		CostUSD:          u.CostUSD,
+		Failed:           failed,
+	})
+	if err != nil {
+		ma.logger.Error().Err(err).Str("analysis_id", analysisID).Msg("Failed to record AI usage")
+	}
+}
+
+// workspace returns the workspace the analyzer's output belongs to
+func (ma *MarketAnalyzer) workspace() string {
+	ma.aiMu.RLock()
+	defer ma.aiMu.RUnlock()
+	return ma.workspaceID
+}
+
+// saveReport fills in analyzer metadata and stores a validated report.
+// Reports of ensemble members are only stored.
+func (ma *MarketAnalyzer) saveReport(rep models.Report) (string, error) {
+	reportID := uuid.New().String()
+	rep.ReportID = reportID
+	rep.OwnerID = ma.ownerID
+	rep.WorkspaceID = ma.workspace()
+	rep.MonitorID = ma.monitorID
+	if len(rep.Timeframe) == 0 {
+		rep.Timeframe = ma.intervals
+	}
+	if rep.Symbol == "" {
+		rep.Symbol = ma.symbol
+	}
+	if rep.Timestamp == 0 {
+		rep.Timestamp = time.Now().UnixMilli()
+	}
+	if rep.Price == 0 {
+		rep.Price = ma.lastPrice()
+	}
+	if rep.Klines == nil {
+		rep.Klines = ma.recentKlines(reportKlines)
+	}
+	member := rep.EnsembleMemberOf != ""
+	if prev, ok := ma.reportMgr.Latest(rep.WorkspaceID, rep.Symbol); ok && !member {
+		changes := report.Diff(prev, rep)
+		rep.Changes = &changes
+	}
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal report failed: %w", err)
+	}
+	if err := ma.reportMgr.SaveReport(reportID, string(data)); err != nil {
+		return "", err
+	}
+	if member {
+		return reportID, nil
+	}
+	ma.reportMgr.Publish(rep)
+	if rep.Ensemble != nil {
+		metrics.CountReport("consensus")
+	} else {
+		metrics.CountReport("report")
+	}
+	return reportID, nil
+}
+
+// saveFailed stores raw AI responses that did not produce a valid report
+func (ma *MarketAnalyzer) saveFailed(analysisID string, rawResponses []string, cause error, usage *models.Usage) {
+	failed := report.FailedAnalysis{
+		AnalysisID:   analysisID,
+		Symbol:       ma.symbol,
+		Status:       "failed",
+		Attempts:     len(rawResponses),
+		RawResponses: rawResponses,
+		Usage:        usage,
+		CreatedAt:    time.Now().UnixMilli(),
+		OwnerID:      ma.ownerID,
+		WorkspaceID:  ma.workspace(),
+		MonitorID:    ma.monitorID,
+	}
+	var verr *report.ValidationError
+	if errors.As(cause, &verr) {
+		failed.Problems = verr.Problems
+		if len(rawResponses) > 0 && json.Valid([]byte(report.ExtractJSON(rawResponses[len(rawResponses)-1]))) {
+			failed.Status = "partial"
+		}
+	} else if cause != nil {
+		failed.Problems = []string{cause.Error()}
+	}
+	if err := ma.reportMgr.SaveFailed(failed); err != nil {
+		ma.logger.Error().Err(err).Str("analysis_id", analysisID).Msg("Failed to save failed analysis")
+		return
+	}
+	metrics.CountReport("failed")
+	ma.logger.Info().Str("analysis_id", analysisID).Str("status", failed.Status).Msg("Saved failed analysis")
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/auth"
+)
+
+const (
+	sessionCookie = "cryptopulse_session"
+	principalKey  = "principal"
+)
+
+// anonymous is the principal of every request when auth is disabled
+var anonymous = auth.Principal{UserName: "anonymous", Scopes: []string{auth.ScopeAdmin}}
+
+// authenticate resolves the caller from an API key (Authorization: Bearer
+// or X-API-Key) or the session cookie and rejects unauthenticated requests.
+// With a nil service auth is disabled and every caller is an admin.
+func authenticate(svc *auth.Service, logger zerolog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if svc == nil {
+			c.Set(principalKey, anonymous)
+			c.Next()
+			return
+		}
+		key := c.GetHeader("X-API-Key")
+		if bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			key = bearer
+		}
+		if key != "" {
+			p, ok := svc.Authenticate(key)
+			if !ok {
+				logger.Warn().Str("path", c.FullPath()).Str("client_ip", c.ClientIP()).Msg("Invalid API key")
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
+				return
+			}
+			c.Set(principalKey, p)
+			c.Next()
+			return
+		}
+		if token, err := c.Cookie(sessionCookie); err == nil {
+			if p, ok := svc.Session(token); ok {
+				c.Set(principalKey, p)
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+	}
+}
+
+// requireScope rejects callers that were not granted scope
+func requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !principalOf(c).Has(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "missing scope " + scope})
+			return
+		}
+		c.Next()
+	}
+}
+
+// principalOf returns the caller set by authenticate
+func principalOf(c *gin.Context) auth.Principal {
+	if v, ok := c.Get(principalKey); ok {
+		return v.(auth.Principal)
+	}
+	return auth.Principal{}
+}
+
+// setupAuthRoutes registers login, account, API key and user management
+// routes. public routes need no credentials; authed routes run authenticate.
+func setupAuthRoutes(public, authed gin.IRoutes, svc *auth.Service, logger zerolog.Logger) {
+	authed.GET("/api/me", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"auth_enabled": svc != nil, "principal": principalOf(c)})
+	})
+	if svc == nil {
+		return
+	}
+
+	public.POST("/api/login", func(c *gin.Context) {
+		start := time.Now()
+		var req struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			logger.Error().Err(err).Msg("Invalid request body")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		token, p, err := svc.Login(req.Username, req.Password)
+		if err != nil {
+			logger.Warn().Str("username", req.Username).Str("client_ip", c.ClientIP()).Msg("Login failed")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(sessionCookie, token, 0, "/", "", c.Request.TLS != nil, true)
+		logger.Info().Str("user", p.UserName).Dur("duration_ms", time.Since(start)).Msg("Processed /api/login")
+		c.JSON(http.StatusOK, gin.H{"principal": p})
+	})
+
+	public.POST("/api/logout", func(c *gin.Context) {
+		if token, err := c.Cookie(sessionCookie); err == nil {
+			svc.Logout(token)
+		}
+		c.SetCookie(sessionCookie, "", -1, "/", "", c.Request.TLS != nil, true)
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+	})
+
+	authed.POST("/api/password", func(c *gin.Context) {
+		start := time.Now()
+		var req struct {
+			Current string `json:"current"`
+			New     string `json:"new"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			logger.Error().Err(err).Msg("Invalid request body")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		p := principalOf(c)
+		if p.KeyID != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "password changes require a login session"})
+			return
+		}
+		if err := svc.ChangePassword(p.UserID, req.Current, req.New); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, auth.ErrInvalidCredentials) {
+				status = http.StatusForbidden
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info().Str("user", p.UserName).Dur("duration_ms", time.Since(start)).Msg("Processed /api/password")
+		c.JSON(http.StatusOK, gin.H{"message": "Password changed"})
+	})
+
+	authed.GET("/api/keys", func(c *gin.Context) {
+		c.JSON(http.StatusOK, svc.Keys(principalOf(c).UserID))
+	})
+
+	authed.POST("/api/keys", func(c *gin.Context) {
+		start := time.Now()
+		var req struct {
+			Name   string   `json:"name"`
+			Scopes []string `json:"scopes"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			logger.Error().Err(err).Msg("Invalid request body")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		p := principalOf(c)
+		key, secret, err := svc.CreateKey(p.UserID, req.Name, req.Scopes)
+		if err != nil {
+			logger.Warn().Err(err).Str("user", p.UserName).Msg("Create API key failed")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info().Str("user", p.UserName).Str("key_id", key.ID).Strs("scopes", key.Scopes).Dur("duration_ms", time.Since(start)).Msg("Processed /api/keys")
+		c.JSON(http.StatusOK, gin.H{"key": key, "secret": secret})
+	})
+
+	authed.DELETE("/api/keys/:id", func(c *gin.Context) {
+		p := principalOf(c)
+		if err := svc.RevokeKey(p.UserID, c.Param("id")); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+			return
+		}
+		logger.Info().Str("user", p.UserName).Str("key_id", c.Param("id")).Msg("Revoked API key")
+		c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+	})
+
+	authed.GET("/api/users", requireScope(auth.ScopeAdmin), func(c *gin.Context) {
+		c.JSON(http.StatusOK, svc.Users())
+	})
+
+	authed.POST("/api/users", requireScope(auth.ScopeAdmin), func(c *gin.Context) {
+		start := time.Now()
+		var req struct {
+			Name     string   `json:"name"`
+			Password string   `json:"password"`
+			Scopes   []string `json:"scopes"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			logger.Error().Err(err).Msg("Invalid request body")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(req.Scopes) == 0 {
+			req.Scopes = []string{auth.ScopeReadReports, auth.ScopeManageMonitors, auth.ScopeSubmitResponses}
+		}
+		user, err := svc.CreateUser(req.Name, req.Password, req.Scopes)
+		if errors.Is(err, auth.ErrUserExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user.PasswordHash = ""
+		logger.Info().Str("user", user.Name).Strs("scopes", user.Scopes).Dur("duration_ms", time.Since(start)).Msg("Processed /api/users")
+		c.JSON(http.StatusOK, user)
+	})
+}
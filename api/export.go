@@ -0,0 +1,39 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/songzhibin97/CryptoPulse/models"
+	"github.com/songzhibin97/CryptoPulse/report"
+)
+
+// errUnknownFormat is returned for a report format with no renderer
+var errUnknownFormat = errors.New("format must be json, markdown, html, levels.csv or alerts.csv")
+
+// renderReport renders a report in a format of GET /api/report and returns
+// its content type and file extension
+func renderReport(rep models.Report, format string) (body []byte, contentType, ext string, err error) {
+	switch format {
+	case "markdown", "md":
+		return report.Markdown(rep), "text/markdown; charset=utf-8", ".md", nil
+	case "html":
+		body, err = report.HTML(rep)
+		return body, "text/html; charset=utf-8", ".html", err
+	case "levels.csv":
+		body, err = report.LevelsCSV(rep)
+		return body, "text/csv; charset=utf-8", "-levels.csv", err
+	case "alerts.csv":
+		body, err = report.AlertsCSV(rep)
+		return body, "text/csv; charset=utf-8", "-alerts.csv", err
+	}
+	return nil, "", "", errUnknownFormat
+}
+
+// renderErrorStatus maps a rendering error to its HTTP status
+func renderErrorStatus(err error) int {
+	if errors.Is(err, errUnknownFormat) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
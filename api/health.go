@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/metrics"
+	"github.com/songzhibin97/CryptoPulse/report"
+	"github.com/songzhibin97/CryptoPulse/tracing"
+	"github.com/songzhibin97/CryptoPulse/usage"
+)
+
+// upstreamCheckTTL is how long an upstream reachability result is reused so
+// frequent probes do not spend Binance request weight
+const upstreamCheckTTL = 15 * time.Second
+
+// upstreamCheck caches whether the Binance API is reachable
+type upstreamCheck struct {
+	store     *config.Store
+	checkedAt time.Time
+	err       error
+	mu        sync.Mutex
+}
+
+func (u *upstreamCheck) check(ctx context.Context) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if time.Since(u.checkedAt) < upstreamCheckTTL {
+		return u.err
+	}
+	client := resty.New().SetTimeout(5 * time.Second)
+	base := &http.Transport{}
+	if cfg := u.store.Get(); cfg.ProxyURL.IsSet() {
+		base.Proxy = http.ProxyURL(cfg.ProxyURL.URL)
+	}
+	client.SetTransport(tracing.Transport(base))
+	metrics.InstrumentUpstream(client, "binance")
+	resp, err := client.R().SetContext(ctx).Get("https://api1.binance.com/api/v3/ping")
+	if err == nil && resp.StatusCode() != http.StatusOK {
+		err = fmt.Errorf("binance ping returned %d", resp.StatusCode())
+	}
+	u.checkedAt, u.err = time.Now(), err
+	return err
+}
+
+// setupHealthRoutes registers the unauthenticated liveness, readiness and
+// metrics endpoints
+func setupHealthRoutes(r gin.IRoutes, store *config.Store, logger zerolog.Logger, reportMgr *report.ReportManager, usageTracker *usage.Tracker) {
+	upstream := &upstreamCheck{store: store}
+
+	r.GET("/metrics", metrics.Handler())
+
+	r.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	r.GET("/readyz", func(c *gin.Context) {
+		checks := map[string]error{
+			"binance": upstream.check(c.Request.Context()),
+			"reports": reportMgr.Check(),
+			"usage":   usageTracker.Check(),
+		}
+		status, code := "ready", http.StatusOK
+		results := make(map[string]string, len(checks))
+		for name, err := range checks {
+			results[name] = "ok"
+			if err != nil {
+				results[name] = err.Error()
+				status, code = "not ready", http.StatusServiceUnavailable
+				logger.Warn().Err(err).Str("check", name).Msg("Readiness check failed")
+			}
+		}
+		c.JSON(code, gin.H{"status": status, "checks": results})
+	})
+}
@@ -0,0 +1,442 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/analyzer"
+	"github.com/songzhibin97/CryptoPulse/auth"
+	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/metrics"
+	"github.com/songzhibin97/CryptoPulse/models"
+	"github.com/songzhibin97/CryptoPulse/queue"
+	"github.com/songzhibin97/CryptoPulse/watchlist"
+)
+
+// Monitor statuses
+const (
+	statusRunning = "running"
+	statusPaused  = "paused"
+	statusStopped = "stopped"
+)
+
+// maxMonitorEvents bounds each monitor's event log; older events are dropped
+const maxMonitorEvents = 100
+
+// validIntervals are the kline intervals a monitor may analyze
+var validIntervals = map[string]bool{"1m": true, "5m": true, "15m": true, "1h": true, "4h": true, "1d": true}
+
+// errMonitorQuota is returned when a workspace already runs its maximum
+// number of monitors
+var errMonitorQuota = errors.New("workspace monitor quota reached")
+
+// monitorEvent is an entry in a monitor's event log
+type monitorEvent struct {
+	Time int64 `json:"time"`
+	// Action is started, cloned, paused, resumed or updated
+	Action string `json:"action"`
+	// Status is the monitor's status after the event
+	Status  string `json:"status"`
+	UserID  string `json:"user_id,omitempty"`
+	Details string `json:"details,omitempty"`
+}
+
+// recordLocked appends an event to the monitor's log; callers hold reg.mu
+func (m *monitor) recordLocked(action, userID, details string) {
+	m.events = append(m.events, monitorEvent{
+		Time:    time.Now().Unix(),
+		Action:  action,
+		Status:  m.status,
+		UserID:  userID,
+		Details: details,
+	})
+	if len(m.events) > maxMonitorEvents {
+		m.events = m.events[len(m.events)-maxMonitorEvents:]
+	}
+}
+
+// validateSchedule checks a monitor's intervals and cycle, including the
+// workspace's minimum cycle. A monitor aligned to candle closes runs once
+// per candle of align, which must be one of its intervals, and ignores cycle.
+func validateSchedule(intervals []string, cycle, align string, quota config.WorkspaceQuota) error {
+	if len(intervals) == 0 {
+		return errors.New("intervals are required")
+	}
+	for _, interval := range intervals {
+		if !validIntervals[interval] {
+			return fmt.Errorf("invalid interval: %s", interval)
+		}
+	}
+	if align != "" {
+		if !slices.Contains(intervals, align) {
+			return fmt.Errorf("align interval %s must be one of the monitor's intervals", align)
+		}
+		duration, _ := models.IntervalDuration(align)
+		if quota.MinCycle > 0 && duration < time.Duration(quota.MinCycle) {
+			return fmt.Errorf("cycle must be at least %s in this workspace", quota.MinCycle)
+		}
+		return nil
+	}
+	duration, err := time.ParseDuration(cycle)
+	if err != nil || duration < 10*time.Second {
+		return errors.New("cycle must be at least 10s")
+	}
+	if quota.MinCycle > 0 && duration < time.Duration(quota.MinCycle) {
+		return fmt.Errorf("cycle must be at least %s in this workspace", quota.MinCycle)
+	}
+	return nil
+}
+
+// validateBackends checks that every named AI backend is configured
+func validateBackends(cfg config.Config, names ...string) error {
+	for _, name := range names {
+		if _, ok := cfg.Backend(name); name != "" && !ok {
+			return fmt.Errorf("unknown ai backend: %s", name)
+		}
+	}
+	return nil
+}
+
+// start creates the analyzer of a new monitor, registers it under a fresh
+// ID and runs it. symbol is ignored for monitors of a watchlist. It returns errMonitorQuota when the workspace is full.
+func (reg *analyzerRegistry) start(ctx context.Context, m *monitor, symbol string, cfg config.Config, quota config.WorkspaceQuota) (string, error) {
+	if quota.MaxMonitors > 0 && reg.count(m.workspaceID) >= quota.MaxMonitors {
+		return "", fmt.Errorf("%w: maximum of %d monitors", errMonitorQuota, quota.MaxMonitors)
+	}
+	var ma *analyzer.MarketAnalyzer
+	if m.basket != nil {
+		ma = reg.newBasketAnalyzer(reg.ctx, m.basket, m.intervals, m.config(cfg), queue.Background)
+	} else {
+		ma = reg.newAnalyzer(reg.ctx, symbol, m.intervals, m.config(cfg), queue.Background)
+	}
+	if err := ma.ConnectWebSocket(ctx); err != nil {
+		return "", err
+	}
+
+	monitorID := uuid.New().String()
+	ma.SetOwner(m.ownerID)
+	ma.SetWorkspace(m.workspaceID, quota.AIBudget)
+	ma.TrackUsage(monitorID, reg.usage, m.budgetFor(cfg))
+	m.analyzer = ma
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	// Re-check under the lock so concurrent starts cannot exceed the quota
+	if quota.MaxMonitors > 0 && reg.countLocked(m.workspaceID) >= quota.MaxMonitors {
+		ma.Stop()
+		return "", fmt.Errorf("%w: maximum of %d monitors", errMonitorQuota, quota.MaxMonitors)
+	}
+	reg.monitors[monitorID] = m
+	reg.runLocked(m)
+	return monitorID, nil
+}
+
+// runLocked starts the monitor's loop, which wait keeps track of; callers
+// hold reg.mu
+func (reg *analyzerRegistry) runLocked(m *monitor) {
+	ctx, cancel := context.WithCancel(reg.ctx)
+	done := make(chan struct{})
+	m.status, m.cancel, m.done = statusRunning, cancel, done
+	metrics.ActiveMonitors.Inc()
+	reg.running.Add(1)
+	go func(cycle, align string) {
+		defer reg.running.Done()
+		defer close(done)
+		m.analyzer.RunMonitor(ctx, cycle, align)
+	}(m.cycle, m.align)
+}
+
+// pauseLocked cancels the monitor's loop and returns a channel closed once
+// its current cycle has ended; callers hold reg.mu
+func (reg *analyzerRegistry) pauseLocked(m *monitor) <-chan struct{} {
+	m.status = statusPaused
+	m.cancel()
+	metrics.ActiveMonitors.Dec()
+	return m.done
+}
+
+// stopLocked stops the monitor for good and removes it; callers hold reg.mu
+func (reg *analyzerRegistry) stopLocked(id string, m *monitor) {
+	if m.status == statusRunning {
+		metrics.ActiveMonitors.Dec()
+	}
+	m.cancel()
+	m.analyzer.Stop()
+	m.status = statusStopped
+	delete(reg.monitors, id)
+}
+
+// lookup returns a monitor the caller may access
+func (reg *analyzerRegistry) lookup(c *gin.Context, id string) (*monitor, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	m, ok := reg.monitors[id]
+	return m, ok && canAccessMonitor(c, m)
+}
+
+// startErrorStatus maps a start error to its HTTP status
+func startErrorStatus(err error) int {
+	if errors.Is(err, errMonitorQuota) {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+// setupMonitorRoutes registers the routes that pause, resume, update and
+// clone running monitors and read their event logs
+func setupMonitorRoutes(authed gin.IRoutes, store *config.Store, registry *analyzerRegistry, logger zerolog.Logger) {
+	manage := requireScope(auth.ScopeManageMonitors)
+
+	authed.POST("/api/monitors/:id/pause", manage, func(c *gin.Context) {
+		start := time.Now()
+		m, ok := registry.lookup(c, c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
+			return
+		}
+		m.lifecycle.Lock()
+		defer m.lifecycle.Unlock()
+		registry.mu.Lock()
+		if m.status != statusRunning {
+			registry.mu.Unlock()
+			c.JSON(http.StatusConflict, gin.H{"error": "monitor is not running"})
+			return
+		}
+		done := registry.pauseLocked(m)
+		m.recordLocked("paused", principalOf(c).UserID, "")
+		registry.mu.Unlock()
+		<-done
+		logger.Info().Str("monitor_id", c.Param("id")).Dur("duration_ms", time.Since(start)).Msg("Paused monitor")
+		c.JSON(http.StatusOK, gin.H{"monitor_id": c.Param("id"), "status": statusPaused})
+	})
+
+	authed.POST("/api/monitors/:id/resume", manage, func(c *gin.Context) {
+		m, ok := registry.lookup(c, c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
+			return
+		}
+		if registry.ctx.Err() != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+			return
+		}
+		m.lifecycle.Lock()
+		defer m.lifecycle.Unlock()
+		registry.mu.Lock()
+		defer registry.mu.Unlock()
+		if m.status != statusPaused {
+			c.JSON(http.StatusConflict, gin.H{"error": "monitor is not paused"})
+			return
+		}
+		registry.runLocked(m)
+		m.recordLocked("resumed", principalOf(c).UserID, "")
+		logger.Info().Str("monitor_id", c.Param("id")).Msg("Resumed monitor")
+		c.JSON(http.StatusOK, gin.H{"monitor_id": c.Param("id"), "status": statusRunning})
+	})
+
+	authed.PUT("/api/monitors/:id", manage, func(c *gin.Context) {
+		start := time.Now()
+		var req struct {
+			Intervals []string         `json:"intervals"`
+			Cycle     string           `json:"cycle"`
+			Align     *string          `json:"align"`
+			Backend   *string          `json:"backend"`
+			Ensemble  *[]string        `json:"ensemble"`
+			Budget    *config.AIBudget `json:"budget"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			logger.Error().Err(err).Msg("Invalid request body")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		m, ok := registry.lookup(c, c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
+			return
+		}
+		m.lifecycle.Lock()
+		defer m.lifecycle.Unlock()
+
+		registry.mu.RLock()
+		intervals, cycle, align, backend, ensemble := m.intervals, m.cycle, m.align, m.backend, m.ensemble
+		registry.mu.RUnlock()
+		var changes []string
+		if req.Intervals != nil {
+			intervals = req.Intervals
+			changes = append(changes, "intervals="+strings.Join(intervals, ","))
+		}
+		if req.Cycle != "" {
+			cycle = req.Cycle
+			changes = append(changes, "cycle="+cycle)
+		}
+		if req.Align != nil {
+			align = *req.Align
+			changes = append(changes, "align="+align)
+		}
+		if req.Backend != nil {
+			backend = *req.Backend
+			changes = append(changes, "backend="+backend)
+		}
+		if req.Ensemble != nil {
+			ensemble = *req.Ensemble
+			changes = append(changes, "ensemble="+strings.Join(ensemble, ","))
+		}
+		if req.Budget != nil {
+			changes = append(changes, "budget")
+		}
+		if len(changes) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "nothing to update"})
+			return
+		}
+		cfg := store.Get()
+		quota := registry.quota(m.workspaceID, cfg)
+		if err := validateSchedule(intervals, cycle, align, quota); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateBackends(cfg, append([]string{backend}, ensemble...)...); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// The loop reads intervals, cycle and align, so it is paused while they change
+		registry.mu.Lock()
+		restart := m.status == statusRunning && (req.Intervals != nil || req.Cycle != "" || req.Align != nil)
+		if restart {
+			done := registry.pauseLocked(m)
+			registry.mu.Unlock()
+			<-done
+			registry.mu.Lock()
+		}
+		if m.status == statusStopped {
+			registry.mu.Unlock()
+			c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
+			return
+		}
+		m.intervals, m.cycle, m.align, m.backend, m.ensemble = intervals, cycle, align, backend, ensemble
+		if req.Budget != nil {
+			m.budget = req.Budget
+		}
+		if req.Intervals != nil {
+			m.analyzer.SetIntervals(intervals)
+		}
+		m.analyzer.ReloadAI(m.config(cfg))
+		m.analyzer.SetBudget(m.budgetFor(cfg))
+		if restart && registry.ctx.Err() == nil {
+			registry.runLocked(m)
+		}
+		m.recordLocked("updated", principalOf(c).UserID, strings.Join(changes, " "))
+		status := m.status
+		registry.mu.Unlock()
+
+		logger.Info().
+			Str("monitor_id", c.Param("id")).
+			Strs("changes", changes).
+			Dur("duration_ms", time.Since(start)).
+			Msg("Updated monitor")
+		c.JSON(http.StatusOK, gin.H{
+			"monitor_id": c.Param("id"),
+			"status":     status,
+			"intervals":  intervals,
+			"cycle":      cycle,
+			"align":      align,
+			"backend":    backend,
+			"ensemble":   ensemble,
+		})
+	})
+
+	authed.POST("/api/monitors/:id/clone", manage, func(c *gin.Context) {
+		start := time.Now()
+		var req struct {
+			Symbol      string `json:"symbol"`
+			WatchlistID string `json:"watchlist_id"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			logger.Error().Err(err).Msg("Invalid request body")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if (req.Symbol == "") == (req.WatchlistID == "") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "exactly one of symbol and watchlist_id is required"})
+			return
+		}
+		var basket *watchlist.Watchlist
+		if req.WatchlistID != "" {
+			w, ok := lookupWatchlist(c, registry.watchlists, req.WatchlistID)
+			if !ok {
+				c.JSON(http.StatusNotFound, gin.H{"error": "watchlist not found"})
+				return
+			}
+			basket = &w
+		}
+		if registry.ctx.Err() != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+			return
+		}
+		source, ok := registry.lookup(c, c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
+			return
+		}
+		registry.mu.RLock()
+		m := &monitor{
+			basket:      basket,
+			ownerID:     principalOf(c).UserID,
+			workspaceID: source.workspaceID,
+			intervals:   source.intervals,
+			cycle:       source.cycle,
+			align:       source.align,
+			backend:     source.backend,
+			ensemble:    source.ensemble,
+			budget:      source.budget,
+		}
+		registry.mu.RUnlock()
+
+		cfg := store.Get()
+		quota := registry.quota(m.workspaceID, cfg)
+		if err := validateSchedule(m.intervals, m.cycle, m.align, quota); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		monitorID, err := registry.start(c.Request.Context(), m, req.Symbol, cfg, quota)
+		if err != nil {
+			logger.Error().Err(err).Str("source_id", c.Param("id")).Str("symbol", req.Symbol).Str("watchlist_id", req.WatchlistID).Msg("Clone monitor error")
+			c.JSON(startErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		registry.mu.Lock()
+		m.recordLocked("cloned", m.ownerID, "from "+c.Param("id"))
+		registry.mu.Unlock()
+
+		logger.Info().
+			Str("source_id", c.Param("id")).
+			Str("monitor_id", monitorID).
+			Str("symbol", req.Symbol).
+			Str("watchlist_id", req.WatchlistID).
+			Dur("duration_ms", time.Since(start)).
+			Msg("Cloned monitor")
+		c.JSON(http.StatusOK, gin.H{
+			"chart_data": m.analyzer.GenerateChartData(),
+			"monitor_id": monitorID,
+		})
+	})
+
+	authed.GET("/api/monitors/:id/events", manage, func(c *gin.Context) {
+		m, ok := registry.lookup(c, c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
+			return
+		}
+		registry.mu.RLock()
+		events := append([]monitorEvent(nil), m.events...)
+		registry.mu.RUnlock()
+		c.JSON(http.StatusOK, events)
+	})
+}
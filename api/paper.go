@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/auth"
+	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/exchange"
+	"github.com/songzhibin97/CryptoPulse/paper"
+	"github.com/songzhibin97/CryptoPulse/queue"
+)
+
+// paperBookDepth is the number of levels fetched per side when no monitor
+// of the symbol holds a recent order book
+const paperBookDepth = 1000
+
+// maxPaperFills bounds the fills GET /api/paper/fills returns
+const maxPaperFills = 1000
+
+// bookSource returns the order books paper fills use: a running monitor's
+// book of the symbol while it is recent, else a fresh one from Binance
+func (reg *analyzerRegistry) bookSource(store *config.Store) paper.BookSource {
+	return func(ctx context.Context, symbol string) (exchange.Book, error) {
+		cfg := store.Get()
+		var newest exchange.Book
+		var newestAt time.Time
+		reg.mu.RLock()
+		for _, m := range reg.monitors {
+			if m.basket != nil || m.analyzer.Symbol() != symbol {
+				continue
+			}
+			if book, at := m.analyzer.OrderBook(); at.After(newestAt) {
+				newest, newestAt = book, at
+			}
+		}
+		reg.mu.RUnlock()
+		if time.Since(newestAt) <= cfg.Paper.BookAge() && len(newest.Bids) > 0 && len(newest.Asks) > 0 {
+			return newest, nil
+		}
+
+		venue, err := exchange.New(exchange.Binance, cfg)
+		if err != nil {
+			return exchange.Book{}, err
+		}
+		var book exchange.Book
+		err = reg.fetchQueue.Do(ctx, queue.Interactive, func(ctx context.Context) error {
+			book, err = venue.Book(ctx, symbol, paperBookDepth)
+			return err
+		})
+		return book, err
+	}
+}
+
+// paperErrorStatus maps an order error to its HTTP status
+func paperErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, paper.ErrInvalidOrder):
+		return http.StatusBadRequest
+	case errors.Is(err, paper.ErrInsufficientFunds), errors.Is(err, paper.ErrInsufficientPosition), errors.Is(err, paper.ErrNoLiquidity):
+		return http.StatusUnprocessableEntity
+	}
+	return fetchErrorStatus(err)
+}
+
+// setupPaperRoutes registers the routes of the workspace's simulated
+// trading account
+func setupPaperRoutes(authed gin.IRoutes, engine *paper.Engine, logger zerolog.Logger) {
+	manage := requireScope(auth.ScopeManageMonitors)
+
+	authed.GET("/api/paper/account", func(c *gin.Context) {
+		start := time.Now()
+		ws := workspaceOf(c).ID
+		if c.Query("refresh") != "true" {
+			c.JSON(http.StatusOK, engine.Account(ws))
+			return
+		}
+		summary, err := engine.Refresh(c.Request.Context(), ws)
+		if err != nil {
+			// Positions that could not be marked keep their last mark
+			logger.Warn().Err(err).Str("workspace_id", ws).Msg("Failed to mark paper positions")
+		}
+		logger.Info().Str("workspace_id", ws).Dur("duration_ms", time.Since(start)).Msg("Processed /api/paper/account")
+		c.JSON(http.StatusOK, summary)
+	})
+
+	authed.PUT("/api/paper/account", manage, func(c *gin.Context) {
+		var settings paper.Settings
+		if err := c.BindJSON(&settings); err != nil {
+			logger.Error().Err(err).Msg("Invalid request body")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		summary, err := engine.Configure(workspaceOf(c).ID, settings)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info().
+			Str("workspace_id", summary.WorkspaceID).
+			Bool("auto_trade", settings.AutoTrade.Enabled).
+			Bool("allow_short", settings.AllowShort).
+			Msg("Updated paper account settings")
+		c.JSON(http.StatusOK, summary)
+	})
+
+	authed.POST("/api/paper/reset", manage, func(c *gin.Context) {
+		var req struct {
+			Balance float64 `json:"balance"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		summary, err := engine.Reset(workspaceOf(c).ID, req.Balance)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info().Str("workspace_id", summary.WorkspaceID).Float64("balance", summary.Cash).Str("user", principalOf(c).UserName).Msg("Reset paper account")
+		c.JSON(http.StatusOK, summary)
+	})
+
+	authed.POST("/api/paper/orders", manage, func(c *gin.Context) {
+		start := time.Now()
+		var order paper.Order
+		if err := c.BindJSON(&order); err != nil {
+			logger.Error().Err(err).Msg("Invalid request body")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		fill, err := engine.Place(c.Request.Context(), workspaceOf(c).ID, principalOf(c).UserID, order)
+		if err != nil {
+			logger.Warn().Err(err).Str("symbol", order.Symbol).Str("side", order.Side).Msg("Paper order rejected")
+			c.JSON(paperErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info().Str("fill_id", fill.ID).Dur("duration_ms", time.Since(start)).Msg("Processed /api/paper/orders")
+		c.JSON(http.StatusOK, gin.H{
+			"fill":    fill,
+			"account": engine.Account(workspaceOf(c).ID),
+		})
+	})
+
+	authed.GET("/api/paper/fills", func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if err != nil || limit < 1 || limit > maxPaperFills {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+			return
+		}
+		c.JSON(http.StatusOK, engine.Fills(workspaceOf(c).ID, limit))
+	})
+
+	authed.GET("/api/paper/equity", func(c *gin.Context) {
+		c.JSON(http.StatusOK, engine.EquityCurve(workspaceOf(c).ID))
+	})
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songzhibin97/CryptoPulse/auth"
+	"github.com/songzhibin97/CryptoPulse/queue"
+)
+
+// fetchErrorStatus maps an on-demand fetch error to its HTTP status; a busy
+// or closed queue is reported as temporarily unavailable
+func fetchErrorStatus(err error) int {
+	if errors.Is(err, queue.ErrFull) || errors.Is(err, queue.ErrClosed) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// setupQueueRoutes registers the job queue introspection endpoint
+func setupQueueRoutes(authed gin.IRoutes, registry *analyzerRegistry) {
+	authed.GET("/api/queue", requireScope(auth.ScopeManageMonitors), func(c *gin.Context) {
+		var skipped int64
+		registry.mu.RLock()
+		for _, m := range registry.monitors {
+			if canAccessMonitor(c, m) {
+				skipped += m.analyzer.SkippedCycles()
+			}
+		}
+		registry.mu.RUnlock()
+		c.JSON(http.StatusOK, gin.H{
+			"pools":          []queue.Stats{registry.fetchQueue.Stats(), registry.aiQueue.Stats()},
+			"skipped_cycles": skipped,
+		})
+	})
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/auth"
+	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/report"
+	"github.com/songzhibin97/CryptoPulse/retention"
+)
+
+// maxDeletionsLimit bounds the audit entries GET /api/retention/deletions
+// returns
+const maxDeletionsLimit = 1000
+
+// retentionFilter reads the document filter of a request: the caller's
+// workspace, or every workspace for admins asking for all=true
+func retentionFilter(c *gin.Context) (retention.Filter, error) {
+	f := retention.Filter{
+		WorkspaceID: workspaceOf(c).ID,
+		OwnerID:     principalOf(c).UserID,
+		Symbol:      strings.ToUpper(c.Query("symbol")),
+		MonitorID:   c.Query("monitor_id"),
+		Kind:        c.Query("kind"),
+	}
+	if principalOf(c).Has(auth.ScopeAdmin) && c.Query("all") == "true" {
+		f.All = true
+	}
+	switch f.Kind {
+	case "", retention.KindReport, retention.KindBasket, retention.KindFailed:
+	default:
+		return f, errors.New("kind must be report, basket or failed")
+	}
+	for name, dst := range map[string]*int64{"before": &f.Before, "after": &f.After} {
+		if v := c.Query(name); v != "" {
+			t, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || t <= 0 {
+				return f, errors.New(name + " must be a Unix millisecond time")
+			}
+			*dst = t
+		}
+	}
+	return f, nil
+}
+
+// setupRetentionRoutes registers the routes deleting reports and
+// reporting on retention
+func setupRetentionRoutes(authed gin.IRoutes, store *config.Store, reportMgr *report.ReportManager, mgr *retention.Manager, authSvc *auth.Service, logger zerolog.Logger) {
+	manage := requireScope(auth.ScopeManageMonitors)
+
+	authed.DELETE("/api/reports/:id", manage, func(c *gin.Context) {
+		start := time.Now()
+		reportID := c.Param("id")
+		data, err := reportMgr.Report(reportID)
+		if err != nil && !errors.Is(err, report.ErrNotFound) && !errors.Is(err, report.ErrInvalidID) {
+			logger.Error().Err(err).Str("report_id", reportID).Msg("Failed to read report")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read report"})
+			return
+		}
+		if err != nil || !canAccessFile(c, authSvc, data) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "report not found"})
+			return
+		}
+		doc, err := retention.Parse(report.CollectionReports, reportID, data)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		res, err := mgr.Delete(c.Request.Context(), []retention.Doc{doc}, principalOf(c).UserName, retention.ReasonAPI)
+		if err != nil {
+			logger.Error().Err(err).Str("report_id", reportID).Msg("Failed to delete report")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(res.Deleted) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "report not found"})
+			return
+		}
+		logger.Info().Str("report_id", reportID).Dur("duration_ms", time.Since(start)).Msg("Processed DELETE /api/reports/:id")
+		c.JSON(http.StatusOK, res)
+	})
+
+	// Bulk deletion takes the filters of the query string; at least one of
+	// symbol, monitor_id, kind, before and after is required so a bare
+	// request cannot empty a workspace
+	authed.DELETE("/api/reports", manage, func(c *gin.Context) {
+		start := time.Now()
+		f, err := retentionFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if f.Symbol == "" && f.MonitorID == "" && f.Kind == "" && f.Before == 0 && f.After == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "at least one of symbol, monitor_id, kind, before and after is required"})
+			return
+		}
+		docs, err := mgr.Find(c.Request.Context(), f)
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to read reports")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read reports"})
+			return
+		}
+		if c.Query("dry_run") == "true" {
+			res := retention.Result{StartedAt: start.UnixMilli(), DryRun: true, Deleted: make([]retention.Deletion, 0, len(docs))}
+			for _, d := range docs {
+				res.Deleted = append(res.Deleted, retention.Deletion{Doc: d, Actor: principalOf(c).UserName, Reason: retention.ReasonBulk})
+			}
+			c.JSON(http.StatusOK, res)
+			return
+		}
+		res, err := mgr.Delete(c.Request.Context(), docs, principalOf(c).UserName, retention.ReasonBulk)
+		if err != nil {
+			logger.Error().Err(err).Int("deleted", len(res.Deleted)).Msg("Failed to delete reports")
+			c.JSON(http.StatusInternalServerError, res)
+			return
+		}
+		logger.Info().Int("deleted", len(res.Deleted)).Str("user", principalOf(c).UserName).Dur("duration_ms", time.Since(start)).Msg("Processed DELETE /api/reports")
+		c.JSON(http.StatusOK, res)
+	})
+
+	authed.GET("/api/retention/deletions", requireScope(auth.ScopeReadReports), func(c *gin.Context) {
+		start := time.Now()
+		f, err := retentionFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if err != nil || limit < 1 || limit > maxDeletionsLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+			return
+		}
+		out, err := mgr.Deletions(f, limit)
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to read deletion audit log")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read audit log"})
+			return
+		}
+		logger.Info().Int("count", len(out)).Dur("duration_ms", time.Since(start)).Msg("Processed /api/retention/deletions")
+		c.JSON(http.StatusOK, out)
+	})
+
+	authed.GET("/api/retention", requireScope(auth.ScopeAdmin), func(c *gin.Context) {
+		cfg := store.Get().Retention
+		c.JSON(http.StatusOK, gin.H{
+			"enabled":    cfg.Enabled,
+			"interval":   cfg.Every().String(),
+			"archive":    cfg.Archive,
+			"default":    cfg.Default,
+			"rules":      cfg.Rules,
+			"last_sweep": mgr.Last(),
+		})
+	})
+
+	// A dry run lists what the policies would delete now
+	authed.POST("/api/retention/run", requireScope(auth.ScopeAdmin), func(c *gin.Context) {
+		start := time.Now()
+		res, err := mgr.Sweep(c.Request.Context(), c.Query("dry_run") == "true")
+		if err != nil {
+			logger.Error().Err(err).Msg("Retention sweep failed")
+			c.JSON(http.StatusInternalServerError, res)
+			return
+		}
+		logger.Info().Int("deleted", len(res.Deleted)).Bool("dry_run", res.DryRun).Str("user", principalOf(c).UserName).Dur("duration_ms", time.Since(start)).Msg("Processed /api/retention/run")
+		c.JSON(http.StatusOK, res)
+	})
+}
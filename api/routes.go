@@ -1,53 +1,268 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
-	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/google/uuid"
-
 	"github.com/gin-gonic/gin"
 	"github.com/go-resty/resty/v2"
 	"github.com/rs/zerolog"
 	"github.com/songzhibin97/CryptoPulse/analyzer"
+	"github.com/songzhibin97/CryptoPulse/auth"
 	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/metrics"
+	"github.com/songzhibin97/CryptoPulse/models"
+	"github.com/songzhibin97/CryptoPulse/paper"
+	"github.com/songzhibin97/CryptoPulse/queue"
 	"github.com/songzhibin97/CryptoPulse/report"
+	"github.com/songzhibin97/CryptoPulse/retention"
+	"github.com/songzhibin97/CryptoPulse/scanner"
+	"github.com/songzhibin97/CryptoPulse/scheduler"
+	"github.com/songzhibin97/CryptoPulse/signals"
+	"github.com/songzhibin97/CryptoPulse/spread"
+	"github.com/songzhibin97/CryptoPulse/tracing"
+	"github.com/songzhibin97/CryptoPulse/usage"
+	"github.com/songzhibin97/CryptoPulse/watchlist"
 )
 
+// monitor is a running analyzer together with the AI overrides it was
+// started with, so they can be re-applied when the config is reloaded.
+// Fields other than lifecycle are guarded by the registry's mutex.
+type monitor struct {
+	analyzer    *analyzer.MarketAnalyzer
+	basket      *watchlist.Watchlist // watchlist as of the monitor's start, if any
+	ownerID     string
+	workspaceID string
+	intervals   []string
+	cycle       string
+	align       string // interval whose candle closes trigger cycles, if any
+	backend     string
+	ensemble    []string
+	budget      *config.AIBudget
+	status      string
+	events      []monitorEvent
+	// cancel ends the current run of the loop and done is closed after it
+	cancel context.CancelFunc
+	done   chan struct{}
+	// lifecycle serializes pause, resume and update of the monitor
+	lifecycle sync.Mutex
+}
+
+// config returns cfg with the monitor's backend and ensemble overrides applied
+func (m *monitor) config(cfg config.Config) config.Config {
+	if m.backend != "" {
+		cfg.DefaultBackend = m.backend
+	}
+	if len(m.ensemble) > 0 {
+		cfg.AIEnsemble = m.ensemble
+	}
+	return cfg
+}
+
+// budgetFor returns the monitor's budget override or the configured default
+func (m *monitor) budgetFor(cfg config.Config) config.AIBudget {
+	if m.budget != nil {
+		return *m.budget
+	}
+	return cfg.AIBudget
+}
+
 type analyzerRegistry struct {
-	analyzers map[string]*analyzer.MarketAnalyzer
-	mu        sync.RWMutex
+	// ctx is the server lifetime; monitors are cancelled when it ends
+	ctx        context.Context
+	monitors   map[string]*monitor
+	workspaces *auth.Service
+	logger     zerolog.Logger
+	reportMgr  *report.ReportManager
+	usage      *usage.Tracker
+	watchlists *watchlist.Store
+	// fetchQueue and aiQueue are shared by all analyzers
+	fetchQueue *queue.Pool
+	aiQueue    *queue.Pool
+	running    sync.WaitGroup
+	mu         sync.RWMutex
+}
+
+// newAnalyzerRegistry creates the registry and starts its job queues, which
+// run until ctx ends
+func newAnalyzerRegistry(ctx context.Context, workspaces *auth.Service, logger zerolog.Logger, reportMgr *report.ReportManager, usageTracker *usage.Tracker, watchlists *watchlist.Store, queues config.QueueConfig) *analyzerRegistry {
+	reg := &analyzerRegistry{
+		ctx:        ctx,
+		monitors:   make(map[string]*monitor),
+		workspaces: workspaces,
+		logger:     logger,
+		reportMgr:  reportMgr,
+		usage:      usageTracker,
+		watchlists: watchlists,
+		fetchQueue: queue.New("fetch", queues.Fetch(), queues.Size()),
+		aiQueue:    queue.New("ai", queues.AI(), queues.Size()),
+	}
+	reg.fetchQueue.Start(ctx)
+	reg.aiQueue.Start(ctx)
+	return reg
+}
+
+// newAnalyzer creates an analyzer that runs its work on the registry's
+// queues with the given priority
+func (reg *analyzerRegistry) newAnalyzer(ctx context.Context, symbol string, intervals []string, cfg config.Config, prio queue.Priority) *analyzer.MarketAnalyzer {
+	ma := analyzer.NewMarketAnalyzer(ctx, symbol, intervals, cfg, reg.logger, reg.reportMgr)
+	ma.UseQueues(reg.fetchQueue, reg.aiQueue, prio)
+	return ma
+}
+
+// newBasketAnalyzer is newAnalyzer for a monitor of a watchlist
+func (reg *analyzerRegistry) newBasketAnalyzer(ctx context.Context, w *watchlist.Watchlist, intervals []string, cfg config.Config, prio queue.Priority) *analyzer.MarketAnalyzer {
+	ma := analyzer.NewBasketAnalyzer(ctx, w.Name, w.Symbols, intervals, cfg, reg.logger, reg.reportMgr)
+	ma.UseQueues(reg.fetchQueue, reg.aiQueue, prio)
+	return ma
+}
+
+// wait blocks until every monitor loop has returned, so reports of cycles
+// cut short by cancellation are saved, or until ctx ends
+func (reg *analyzerRegistry) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		reg.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("monitors still running: %w", ctx.Err())
+	}
+}
+
+// quota returns the effective quota of a workspace under cfg
+func (reg *analyzerRegistry) quota(workspaceID string, cfg config.Config) config.WorkspaceQuota {
+	if reg.workspaces != nil {
+		if ws, ok := reg.workspaces.Workspace(workspaceID); ok {
+			return ws.EffectiveQuota(cfg.WorkspaceQuota)
+		}
+	}
+	return cfg.WorkspaceQuota
+}
+
+// count returns the number of running monitors in a workspace
+func (reg *analyzerRegistry) count(workspaceID string) int {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return reg.countLocked(workspaceID)
+}
+
+// countLocked is count for callers holding reg.mu
+func (reg *analyzerRegistry) countLocked(workspaceID string) int {
+	n := 0
+	for _, m := range reg.monitors {
+		if m.workspaceID == workspaceID {
+			n++
+		}
+	}
+	return n
+}
+
+// applyWorkspaceBudget updates the shared AI budget of a workspace's monitors
+func (reg *analyzerRegistry) applyWorkspaceBudget(workspaceID string, budget config.AIBudget) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	for _, m := range reg.monitors {
+		if m.workspaceID == workspaceID {
+			m.analyzer.SetWorkspace(workspaceID, budget)
+		}
+	}
 }
 
-func newAnalyzerRegistry() *analyzerRegistry {
-	return &analyzerRegistry{
-		analyzers: make(map[string]*analyzer.MarketAnalyzer),
+// reload re-applies the AI settings and workspace budgets of cfg to every
+// running monitor
+func (reg *analyzerRegistry) reload(cfg config.Config, logger zerolog.Logger) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	for id, m := range reg.monitors {
+		monitorCfg := m.config(cfg)
+		for _, name := range append([]string{m.backend}, m.ensemble...) {
+			if _, ok := cfg.Backend(name); name != "" && !ok {
+				logger.Warn().Str("monitor_id", id).Str("backend", name).Msg("AI backend removed from config")
+			}
+		}
+		m.analyzer.ReloadAI(monitorCfg)
+		m.analyzer.SetBudget(m.budgetFor(cfg))
+		m.analyzer.SetWorkspace(m.workspaceID, reg.quota(m.workspaceID, cfg).AIBudget)
 	}
 }
 
-func SetupRoutes(r *gin.Engine, cfg config.Config, logger zerolog.Logger, reportMgr *report.ReportManager) {
-	registry := newAnalyzerRegistry()
+// canAccessMonitor reports whether the caller may see or control a monitor:
+// it must belong to the selected workspace unless the caller is an admin
+func canAccessMonitor(c *gin.Context, m *monitor) bool {
+	return principalOf(c).Has(auth.ScopeAdmin) || m.workspaceID == workspaceOf(c).ID
+}
+
+// canAccessFile reports whether the caller may read a stored report or
+// failed analysis: members of its workspace may, and documents from before
+// workspaces existed are visible to their owner only
+func canAccessFile(c *gin.Context, svc *auth.Service, data []byte) bool {
+	p := principalOf(c)
+	if p.Has(auth.ScopeAdmin) {
+		return true
+	}
+	meta, err := report.ParseMeta(data)
+	if err != nil {
+		return false
+	}
+	if meta.WorkspaceID == "" {
+		return p.CanAccess(meta.OwnerID)
+	}
+	ws, ok := svc.Workspace(meta.WorkspaceID)
+	return ok && p.CanUse(ws)
+}
 
-	r.GET("/api/pairs", func(c *gin.Context) {
+// SetupRoutes registers the API. Monitors and scheduled analyses run until
+// ctx is cancelled; the returned function waits for them to finish after that.
+func SetupRoutes(ctx context.Context, r *gin.Engine, store *config.Store, logger zerolog.Logger, reportMgr *report.ReportManager, usageTracker *usage.Tracker, authSvc *auth.Service, sched *scheduler.Scheduler, watchlists *watchlist.Store, paperEngine *paper.Engine, signalFeed *signals.Feed) func(context.Context) error {
+	registry := newAnalyzerRegistry(ctx, authSvc, logger, reportMgr, usageTracker, watchlists, store.Get().Queue)
+	identified := r.Group("", authenticate(authSvc, logger))
+	authed := identified.Group("", selectWorkspace(authSvc))
+	setupAuthRoutes(r, identified, authSvc, logger)
+	setupWorkspaceRoutes(identified, authed, authSvc, store, registry, logger)
+	setupMonitorRoutes(authed, store, registry, logger)
+	setupScheduleRoutes(authed, store, registry, sched, logger)
+	setupQueueRoutes(authed, registry)
+	setupWatchlistRoutes(authed, store, watchlists, registry, logger)
+	scan := scanner.New(store, registry.fetchQueue, logger)
+	setupScannerRoutes(authed, store, scan, registry, logger)
+	spreads := spread.NewManager(ctx, store, registry.fetchQueue, logger)
+	setupSpreadRoutes(authed, store, spreads, registry, logger)
+	paperEngine.Connect(ctx, registry.bookSource(store))
+	reportMgr.Subscribe(paperEngine.OnReport)
+	setupPaperRoutes(authed, paperEngine, logger)
+	setupSignalRoutes(ctx, authed, signalFeed, logger)
+	pruner := retention.New(store, reportMgr, logger)
+	setupRetentionRoutes(authed, store, reportMgr, pruner, authSvc, logger)
+	go sched.Run(ctx, registry.runScheduled(store))
+	go scan.Run(ctx)
+	go pruner.Run(ctx)
+	setupHealthRoutes(r, store, logger, reportMgr, usageTracker)
+	store.Subscribe(func(cfg config.Config) {
+		registry.reload(cfg, logger)
+	})
+
+	authed.GET("/api/pairs", func(c *gin.Context) {
 		start := time.Now()
+		cfg := store.Get()
 		query := c.Query("query")
-		client := resty.New()
-		if cfg.ProxyURL != "" {
-			proxy, err := url.Parse(cfg.ProxyURL)
-			if err == nil {
-				client.SetTransport(&http.Transport{Proxy: http.ProxyURL(proxy)})
-			} else {
-				logger.Error().Err(err).Str("proxy_url", cfg.ProxyURL).Msg("Invalid proxy URL")
-			}
+		client := metrics.InstrumentUpstream(resty.New(), "binance")
+		base := &http.Transport{}
+		if cfg.ProxyURL.IsSet() {
+			base.Proxy = http.ProxyURL(cfg.ProxyURL.URL)
 		}
+		client.SetTransport(tracing.Transport(base))
 		client.SetTimeout(10 * time.Second).SetRetryCount(3).SetRetryWaitTime(2 * time.Second)
-		resp, err := client.R().Get("https://api1.binance.com/api/v3/exchangeInfo")
+		resp, err := client.R().SetContext(c.Request.Context()).Get("https://api1.binance.com/api/v3/exchangeInfo")
 		if err != nil {
 			logger.Error().Err(err).Msg("Fetch exchange info error")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -67,12 +282,19 @@ func SetupRoutes(r *gin.Engine, cfg config.Config, logger zerolog.Logger, report
 		c.JSON(http.StatusOK, pairs)
 	})
 
-	r.POST("/api/monitor", func(c *gin.Context) {
+	authed.POST("/api/monitor", requireScope(auth.ScopeManageMonitors), func(c *gin.Context) {
 		start := time.Now()
 		var req struct {
-			Symbol    string   `json:"symbol"`
-			Intervals []string `json:"intervals"`
-			Cycle     string   `json:"cycle"`
+			Symbol    string           `json:"symbol"`
+			Intervals []string         `json:"intervals"`
+			Cycle     string           `json:"cycle"`
+			Align     string           `json:"align"`
+			Backend   string           `json:"backend"`
+			Ensemble  []string         `json:"ensemble"`
+			Budget    *config.AIBudget `json:"budget"`
+
+			// WatchlistID starts a basket monitor of a watchlist instead of a symbol
+			WatchlistID string `json:"watchlist_id"`
 		}
 		if err := c.BindJSON(&req); err != nil {
 			logger.Error().Err(err).Msg("Invalid request body")
@@ -80,54 +302,75 @@ func SetupRoutes(r *gin.Engine, cfg config.Config, logger zerolog.Logger, report
 			return
 		}
 		logger.Debug().Interface("request_body", req).Msg("Received /api/monitor request")
-		if req.Symbol == "" {
-			logger.Warn().Msg("Symbol is required")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "symbol is required"})
-			return
-		}
-		if len(req.Intervals) == 0 {
-			logger.Warn().Msg("Intervals are required")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "intervals are required"})
+		if (req.Symbol == "") == (req.WatchlistID == "") {
+			logger.Warn().Msg("Symbol or watchlist is required")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "exactly one of symbol and watchlist_id is required"})
 			return
 		}
-		validIntervals := map[string]bool{"1m": true, "5m": true, "15m": true, "1h": true, "4h": true, "1d": true}
-		for _, interval := range req.Intervals {
-			if !validIntervals[interval] {
-				logger.Warn().Str("interval", interval).Msg("Invalid interval")
-				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid interval: %s", interval)})
+		var basket *watchlist.Watchlist
+		if req.WatchlistID != "" {
+			w, ok := lookupWatchlist(c, watchlists, req.WatchlistID)
+			if !ok {
+				c.JSON(http.StatusNotFound, gin.H{"error": "watchlist not found"})
 				return
 			}
+			basket = &w
 		}
 		if req.Cycle == "" {
 			req.Cycle = "30s"
 		}
-		duration, err := time.ParseDuration(req.Cycle)
-		if err != nil || duration < 10*time.Second {
-			logger.Warn().Str("cycle", req.Cycle).Msg("Invalid or too short cycle")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "cycle must be at least 10s"})
+		if registry.ctx.Err() != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
 			return
 		}
-
-		ma := analyzer.NewMarketAnalyzer(req.Symbol, req.Intervals, cfg.AIEndpoint, cfg.ExtEndpoint, cfg.ProxyURL, cfg.WSProxyURL, logger, reportMgr)
-		if err := ma.ConnectWebSocket(); err != nil {
-			logger.Error().Err(err).Msg("WebSocket connection error")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		cfg := store.Get()
+		owner := principalOf(c)
+		ws := workspaceOf(c)
+		quota := registry.quota(ws.ID, cfg)
+		if err := validateSchedule(req.Intervals, req.Cycle, req.Align, quota); err != nil {
+			logger.Warn().Err(err).Strs("intervals", req.Intervals).Str("cycle", req.Cycle).Str("align", req.Align).Str("workspace_id", ws.ID).Msg("Invalid monitor schedule")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateBackends(cfg, append([]string{req.Backend}, req.Ensemble...)...); err != nil {
+			logger.Warn().Err(err).Msg("Unknown AI backend")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		m := &monitor{
+			basket:      basket,
+			ownerID:     owner.UserID,
+			workspaceID: ws.ID,
+			intervals:   req.Intervals,
+			cycle:       req.Cycle,
+			align:       req.Align,
+			backend:     req.Backend,
+			ensemble:    req.Ensemble,
+			budget:      req.Budget,
+		}
+		monitorID, err := registry.start(c.Request.Context(), m, req.Symbol, cfg, quota)
+		if err != nil {
+			logger.Error().Err(err).Str("workspace_id", ws.ID).Msg("Start monitor error")
+			c.JSON(startErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
-
-		monitorID := uuid.New().String()
 		registry.mu.Lock()
-		registry.analyzers[monitorID] = ma
+		m.recordLocked("started", owner.UserID, "")
 		registry.mu.Unlock()
 
-		go ma.RunMonitor(req.Cycle)
-
-		chartData := ma.GenerateChartData()
+		monitorCfg := m.config(cfg)
+		chartData := m.analyzer.GenerateChartData()
 		logger.Info().
 			Str("symbol", req.Symbol).
+			Str("watchlist_id", req.WatchlistID).
 			Strs("intervals", req.Intervals).
 			Str("cycle", req.Cycle).
+			Str("align", req.Align).
+			Str("backend", monitorCfg.DefaultBackend).
+			Strs("ensemble", monitorCfg.AIEnsemble).
 			Str("monitor_id", monitorID).
+			Str("user", owner.UserName).
+			Str("workspace_id", ws.ID).
 			Dur("duration_ms", time.Since(start)).
 			Msg("Processed /api/monitor")
 
@@ -137,7 +380,7 @@ func SetupRoutes(r *gin.Engine, cfg config.Config, logger zerolog.Logger, report
 		})
 	})
 
-	r.POST("/api/monitor/stop", func(c *gin.Context) {
+	authed.POST("/api/monitor/stop", requireScope(auth.ScopeManageMonitors), func(c *gin.Context) {
 		start := time.Now()
 		var req struct {
 			MonitorID string `json:"monitor_id"`
@@ -148,10 +391,10 @@ func SetupRoutes(r *gin.Engine, cfg config.Config, logger zerolog.Logger, report
 			return
 		}
 		registry.mu.Lock()
-		ma, ok := registry.analyzers[req.MonitorID]
+		m, ok := registry.monitors[req.MonitorID]
+		ok = ok && canAccessMonitor(c, m)
 		if ok {
-			ma.Stop()
-			delete(registry.analyzers, req.MonitorID)
+			registry.stopLocked(req.MonitorID, m)
 		}
 		registry.mu.Unlock()
 		if !ok {
@@ -163,7 +406,89 @@ func SetupRoutes(r *gin.Engine, cfg config.Config, logger zerolog.Logger, report
 		c.JSON(http.StatusOK, gin.H{"message": "Monitoring stopped"})
 	})
 
-	r.GET("/api/chart", func(c *gin.Context) {
+	authed.GET("/api/monitors", requireScope(auth.ScopeManageMonitors), func(c *gin.Context) {
+		type monitorInfo struct {
+			MonitorID   string   `json:"monitor_id"`
+			Symbol      string   `json:"symbol"`
+			WatchlistID string   `json:"watchlist_id,omitempty"`
+			Symbols     []string `json:"symbols,omitempty"`
+			Intervals   []string `json:"intervals"`
+			Cycle       string   `json:"cycle"`
+			Align       string   `json:"align,omitempty"`
+			OwnerID     string   `json:"owner_id,omitempty"`
+			WorkspaceID string   `json:"workspace_id"`
+			Status      string   `json:"status"`
+			// SkippedCycles counts cycles skipped because the previous one overran
+			SkippedCycles int64 `json:"skipped_cycles"`
+		}
+		monitors := make([]monitorInfo, 0)
+		registry.mu.RLock()
+		for id, m := range registry.monitors {
+			if !canAccessMonitor(c, m) {
+				continue
+			}
+			info := monitorInfo{
+				MonitorID:     id,
+				Symbol:        m.analyzer.Symbol(),
+				Intervals:     m.intervals,
+				Cycle:         m.cycle,
+				Align:         m.align,
+				OwnerID:       m.ownerID,
+				WorkspaceID:   m.workspaceID,
+				Status:        m.status,
+				SkippedCycles: m.analyzer.SkippedCycles(),
+			}
+			if m.basket != nil {
+				info.WatchlistID, info.Symbols = m.basket.ID, m.basket.Symbols
+			}
+			monitors = append(monitors, info)
+		}
+		registry.mu.RUnlock()
+		c.JSON(http.StatusOK, monitors)
+	})
+
+	authed.GET("/api/usage", requireScope(auth.ScopeManageMonitors), func(c *gin.Context) {
+		start := time.Now()
+		p := principalOf(c)
+		ws := workspaceOf(c)
+		filter := usage.Filter{
+			MonitorID:   c.Query("monitor_id"),
+			WorkspaceID: ws.ID,
+			From:        c.Query("from"),
+			To:          c.Query("to"),
+		}
+		all := p.Has(auth.ScopeAdmin) && c.Query("all") == "true"
+		if all {
+			filter.WorkspaceID = ""
+		}
+		summary := usageTracker.Summarize(filter)
+
+		type monitorStatus struct {
+			MonitorID string `json:"monitor_id"`
+			Symbol    string `json:"symbol"`
+			Paused    bool   `json:"paused"`
+			Reason    string `json:"reason,omitempty"`
+		}
+		monitors := make([]monitorStatus, 0)
+		registry.mu.RLock()
+		for id, m := range registry.monitors {
+			if (filter.MonitorID != "" && id != filter.MonitorID) || (!all && m.workspaceID != ws.ID) {
+				continue
+			}
+			paused, reason := m.analyzer.AIPaused()
+			monitors = append(monitors, monitorStatus{MonitorID: id, Symbol: m.analyzer.Symbol(), Paused: paused, Reason: reason})
+		}
+		registry.mu.RUnlock()
+
+		logger.Info().Dur("duration_ms", time.Since(start)).Msg("Processed /api/usage")
+		c.JSON(http.StatusOK, gin.H{
+			"usage":    summary,
+			"monitors": monitors,
+			"quota":    registry.quota(ws.ID, store.Get()),
+		})
+	})
+
+	authed.GET("/api/chart", func(c *gin.Context) {
 		start := time.Now()
 		symbol := c.Query("symbol")
 		if symbol == "" {
@@ -172,10 +497,10 @@ func SetupRoutes(r *gin.Engine, cfg config.Config, logger zerolog.Logger, report
 			return
 		}
 
-		ma := analyzer.NewMarketAnalyzer(symbol, []string{"15m"}, cfg.AIEndpoint, cfg.ExtEndpoint, cfg.ProxyURL, cfg.WSProxyURL, logger, reportMgr)
-		if err := ma.FetchRealtimeData(); err != nil {
+		ma := registry.newAnalyzer(c.Request.Context(), symbol, []string{"15m"}, store.Get(), queue.Interactive)
+		if err := ma.FetchRealtimeData(c.Request.Context()); err != nil {
 			logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to fetch real-time data")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch market data"})
+			c.JSON(fetchErrorStatus(err), gin.H{"error": "failed to fetch market data"})
 			return
 		}
 
@@ -187,7 +512,7 @@ func SetupRoutes(r *gin.Engine, cfg config.Config, logger zerolog.Logger, report
 		c.JSON(http.StatusOK, gin.H{"chart_data": chartData})
 	})
 
-	r.GET("/api/prompt", func(c *gin.Context) {
+	authed.GET("/api/prompt", func(c *gin.Context) {
 		start := time.Now()
 		symbol := c.Query("symbol")
 		if symbol == "" {
@@ -196,25 +521,27 @@ func SetupRoutes(r *gin.Engine, cfg config.Config, logger zerolog.Logger, report
 			return
 		}
 
-		ma := analyzer.NewMarketAnalyzer(symbol, []string{"15m"}, cfg.AIEndpoint, cfg.ExtEndpoint, cfg.ProxyURL, cfg.WSProxyURL, logger, reportMgr)
-		if err := ma.FetchRealtimeData(); err != nil {
+		ma := registry.newAnalyzer(c.Request.Context(), symbol, []string{"15m"}, store.Get(), queue.Interactive)
+		if err := ma.FetchRealtimeData(c.Request.Context()); err != nil {
 			logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to fetch real-time data")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch market data"})
+			c.JSON(fetchErrorStatus(err), gin.H{"error": "failed to fetch market data"})
 			return
 		}
 
-		prompt := ma.GeneratePrompt()
+		prompt, tokens := ma.GeneratePrompt()
 		logger.Info().
 			Str("symbol", symbol).
+			Int("estimated_tokens", tokens).
 			Dur("duration_ms", time.Since(start)).
 			Msg("Processed /api/prompt")
 		c.JSON(http.StatusOK, gin.H{
-			"symbol": symbol,
-			"prompt": prompt,
+			"symbol":           symbol,
+			"prompt":           prompt,
+			"estimated_tokens": tokens,
 		})
 	})
 
-	r.POST("/api/submit_response", func(c *gin.Context) {
+	authed.POST("/api/submit_response", requireScope(auth.ScopeSubmitResponses), func(c *gin.Context) {
 		start := time.Now()
 		var req struct {
 			AnalysisID   string `json:"analysis_id"`
@@ -225,8 +552,19 @@ func SetupRoutes(r *gin.Engine, cfg config.Config, logger zerolog.Logger, report
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		ma := analyzer.NewMarketAnalyzer("", []string{}, cfg.AIEndpoint, cfg.ExtEndpoint, cfg.ProxyURL, cfg.WSProxyURL, logger, reportMgr)
-		resp, err := ma.SubmitManualResponse(req.AnalysisID, req.ResponseJSON)
+		resp, err := analyzer.SubmitManualResponse(c.Request.Context(), store.Get(), logger, reportMgr,
+			workspaceOf(c).ID, principalOf(c).UserID, req.AnalysisID, req.ResponseJSON)
+		if errors.Is(err, analyzer.ErrUnknownAnalysis) {
+			logger.Warn().Str("analysis_id", req.AnalysisID).Msg("Manual response for unknown analysis")
+			c.JSON(http.StatusNotFound, gin.H{"error": "no pending prompt for analysis_id"})
+			return
+		}
+		var verr *report.ValidationError
+		if errors.As(err, &verr) {
+			logger.Warn().Str("analysis_id", req.AnalysisID).Strs("problems", verr.Problems).Msg("Manual response failed validation")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "response does not match the report schema", "problems": verr.Problems})
+			return
+		}
 		if err != nil {
 			logger.Error().Err(err).Msg("Submit manual response error")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -236,16 +574,118 @@ func SetupRoutes(r *gin.Engine, cfg config.Config, logger zerolog.Logger, report
 		c.JSON(http.StatusOK, gin.H{"report_id": resp.ReportID})
 	})
 
-	r.GET("/api/report", func(c *gin.Context) {
+	authed.GET("/api/report", requireScope(auth.ScopeReadReports), func(c *gin.Context) {
 		start := time.Now()
 		reportID := c.Query("report_id")
-		filePath, ok := reportMgr.GetReportPath(reportID)
-		if !ok {
+		data, err := reportMgr.Report(reportID)
+		if err != nil && !errors.Is(err, report.ErrNotFound) && !errors.Is(err, report.ErrInvalidID) {
+			logger.Error().Err(err).Str("report_id", reportID).Msg("Failed to read report")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read report"})
+			return
+		}
+		if err != nil || !canAccessFile(c, authSvc, data) {
 			logger.Warn().Str("report_id", reportID).Msg("Report not found")
 			c.JSON(http.StatusNotFound, gin.H{"error": "report not found"})
 			return
 		}
-		logger.Info().Dur("duration_ms", time.Since(start)).Msg("Processed /api/report")
-		c.FileAttachment(filePath, filepath.Base(filePath))
+		format := c.DefaultQuery("format", "json")
+		if format == "json" {
+			logger.Info().Dur("duration_ms", time.Since(start)).Msg("Processed /api/report")
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, reportID))
+			c.Data(http.StatusOK, "application/json", data)
+			return
+		}
+		rep, err := report.Decode(data)
+		if errors.Is(err, report.ErrNotRenderable) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error().Err(err).Str("report_id", reportID).Msg("Failed to read report")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read report"})
+			return
+		}
+		body, contentType, ext, err := renderReport(rep, format)
+		if err != nil {
+			c.JSON(renderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info().Str("format", format).Dur("duration_ms", time.Since(start)).Msg("Processed /api/report")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s%s"`, reportID, ext))
+		c.Data(http.StatusOK, contentType, body)
+	})
+
+	authed.GET("/api/reports/:id/diff", requireScope(auth.ScopeReadReports), func(c *gin.Context) {
+		start := time.Now()
+		load := func(reportID string) (models.Report, bool) {
+			data, err := reportMgr.Report(reportID)
+			if err != nil || !canAccessFile(c, authSvc, data) {
+				return models.Report{}, false
+			}
+			rep, err := report.Decode(data)
+			return rep, err == nil
+		}
+		rep, ok := load(c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "report not found"})
+			return
+		}
+		var against models.Report
+		switch {
+		case c.Query("against") != "":
+			if against, ok = load(c.Query("against")); !ok {
+				c.JSON(http.StatusNotFound, gin.H{"error": "report to compare against not found"})
+				return
+			}
+		case rep.Changes != nil:
+			if against, ok = load(rep.Changes.From); !ok {
+				c.JSON(http.StatusNotFound, gin.H{"error": "previous report not found"})
+				return
+			}
+		default:
+			prev, found, err := reportMgr.Previous(rep)
+			if err != nil {
+				logger.Error().Err(err).Msg("Failed to read reports")
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read reports"})
+				return
+			}
+			if !found {
+				c.JSON(http.StatusNotFound, gin.H{"error": "no previous report of the symbol"})
+				return
+			}
+			against = prev
+		}
+		if against.Symbol != rep.Symbol {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reports are of different symbols"})
+			return
+		}
+		from, to := against, rep
+		if from.Timestamp > to.Timestamp {
+			from, to = to, from
+		}
+		logger.Info().Str("report_id", rep.ReportID).Str("against", against.ReportID).Dur("duration_ms", time.Since(start)).Msg("Processed /api/reports/:id/diff")
+		c.JSON(http.StatusOK, report.Diff(from, to))
+	})
+
+	authed.GET("/api/failed_analysis", requireScope(auth.ScopeReadReports), func(c *gin.Context) {
+		start := time.Now()
+		analysisID := c.Query("analysis_id")
+		data, err := reportMgr.Failed(analysisID)
+		if err != nil && !errors.Is(err, report.ErrNotFound) && !errors.Is(err, report.ErrInvalidID) {
+			logger.Error().Err(err).Str("analysis_id", analysisID).Msg("Failed to read failed analysis")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read failed analysis"})
+			return
+		}
+		if err != nil || !canAccessFile(c, authSvc, data) {
+			logger.Warn().Str("analysis_id", analysisID).Msg("Failed analysis not found")
+			c.JSON(http.StatusNotFound, gin.H{"error": "failed analysis not found"})
+			return
+		}
+		logger.Info().Dur("duration_ms", time.Since(start)).Msg("Processed /api/failed_analysis")
+		c.Data(http.StatusOK, "application/json", data)
 	})
+
+	return func(ctx context.Context) error {
+		return errors.Join(registry.wait(ctx), sched.Wait(ctx), spreads.Wait(ctx))
+	}
 }
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/auth"
+	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/scanner"
+)
+
+// maxScannerHits bounds the hits GET /api/scanner returns
+const maxScannerHits = 500
+
+// setupScannerRoutes registers the routes that read and trigger market scans
+func setupScannerRoutes(authed gin.IRoutes, store *config.Store, scan *scanner.Scanner, registry *analyzerRegistry, logger zerolog.Logger) {
+	authed.GET("/api/scanner", func(c *gin.Context) {
+		start := time.Now()
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit < 1 || limit > maxScannerHits {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+			return
+		}
+		var minScore float64
+		if s := c.Query("min_score"); s != "" {
+			if minScore, err = strconv.ParseFloat(s, 64); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid min_score"})
+				return
+			}
+		}
+		signal := c.Query("signal")
+
+		last, running := scan.Last()
+		var result *scanner.Result
+		if last != nil {
+			// Hits are ranked, so filtering keeps the order
+			filtered := *last
+			filtered.Hits = make([]scanner.Hit, 0, limit)
+			for _, hit := range last.Hits {
+				if len(filtered.Hits) == limit {
+					break
+				}
+				if hit.Score < minScore || (signal != "" && !hasSignal(hit, signal)) {
+					continue
+				}
+				filtered.Hits = append(filtered.Hits, hit)
+			}
+			result = &filtered
+		}
+		logger.Info().Str("signal", signal).Dur("duration_ms", time.Since(start)).Msg("Processed /api/scanner")
+		c.JSON(http.StatusOK, gin.H{
+			"enabled": store.Get().Scanner.Enabled,
+			"running": running,
+			"result":  result,
+		})
+	})
+
+	authed.POST("/api/scanner/run", requireScope(auth.ScopeManageMonitors), func(c *gin.Context) {
+		if registry.ctx.Err() != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+			return
+		}
+		if _, running := scan.Last(); running {
+			c.JSON(http.StatusConflict, gin.H{"error": scanner.ErrRunning.Error()})
+			return
+		}
+		go func(ctx context.Context) {
+			if _, err := scan.Scan(ctx); err != nil && ctx.Err() == nil {
+				logger.Error().Err(err).Msg("Market scan failed")
+			}
+		}(registry.ctx)
+		logger.Info().Str("user", principalOf(c).UserName).Msg("Started market scan")
+		c.JSON(http.StatusAccepted, gin.H{"message": "Scan started"})
+	})
+}
+
+// hasSignal reports whether a hit met the criterion of the signal type
+func hasSignal(hit scanner.Hit, signal string) bool {
+	for _, s := range hit.Signals {
+		if s.Type == signal {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/auth"
+	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/queue"
+	"github.com/songzhibin97/CryptoPulse/scheduler"
+)
+
+// runScheduled returns the function the scheduler uses to perform a
+// one-shot analysis with the current config
+func (reg *analyzerRegistry) runScheduled(store *config.Store) scheduler.RunFunc {
+	return func(ctx context.Context, job scheduler.Job) (scheduler.Result, error) {
+		cfg := store.Get()
+		m := &monitor{backend: job.Backend, ensemble: job.Ensemble}
+		ma := reg.newAnalyzer(ctx, job.Symbol, job.Intervals, m.config(cfg), queue.Background)
+		defer ma.Stop()
+		ma.SetOwner(job.OwnerID)
+		ma.SetWorkspace(job.WorkspaceID, reg.quota(job.WorkspaceID, cfg).AIBudget)
+		// Usage is attributed to the schedule like a monitor's
+		ma.TrackUsage(job.ID, reg.usage, cfg.AIBudget)
+		if err := ma.FetchRealtimeData(ctx); err != nil {
+			return scheduler.Result{}, err
+		}
+		resp, err := ma.CallAIAnalysis(ctx)
+		return scheduler.Result{AnalysisID: resp.AnalysisID, ReportID: resp.ReportID}, err
+	}
+}
+
+// minGap returns the shortest time between the next few runs of a schedule
+func minGap(sched scheduler.Schedule, from time.Time) time.Duration {
+	gap := time.Duration(0)
+	prev := sched.Next(from)
+	for i := 0; i < 24 && !prev.IsZero(); i++ {
+		next := sched.Next(prev)
+		if next.IsZero() {
+			break
+		}
+		if d := next.Sub(prev); gap == 0 || d < gap {
+			gap = d
+		}
+		prev = next
+	}
+	return gap
+}
+
+// canAccessJob reports whether the caller may see or control a schedule
+func canAccessJob(c *gin.Context, job scheduler.Job) bool {
+	return principalOf(c).Has(auth.ScopeAdmin) || job.WorkspaceID == workspaceOf(c).ID
+}
+
+// setupScheduleRoutes registers the routes that manage scheduled analyses
+func setupScheduleRoutes(authed gin.IRoutes, store *config.Store, registry *analyzerRegistry, sched *scheduler.Scheduler, logger zerolog.Logger) {
+	manage := requireScope(auth.ScopeManageMonitors)
+
+	authed.GET("/api/schedules", manage, func(c *gin.Context) {
+		if principalOf(c).Has(auth.ScopeAdmin) && c.Query("all") == "true" {
+			c.JSON(http.StatusOK, sched.AllJobs())
+			return
+		}
+		c.JSON(http.StatusOK, sched.Jobs(workspaceOf(c).ID))
+	})
+
+	authed.POST("/api/schedules", manage, func(c *gin.Context) {
+		start := time.Now()
+		var req struct {
+			Name         string   `json:"name"`
+			Symbol       string   `json:"symbol"`
+			Intervals    []string `json:"intervals"`
+			Schedule     string   `json:"schedule"`
+			Backend      string   `json:"backend"`
+			Ensemble     []string `json:"ensemble"`
+			MissedPolicy string   `json:"missed_policy"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			logger.Error().Err(err).Msg("Invalid request body")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Symbol == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "symbol is required"})
+			return
+		}
+		if len(req.Intervals) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "intervals are required"})
+			return
+		}
+		for _, interval := range req.Intervals {
+			if !validIntervals[interval] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid interval: %s", interval)})
+				return
+			}
+		}
+		cfg := store.Get()
+		if err := validateBackends(cfg, append([]string{req.Backend}, req.Ensemble...)...); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		parsed, err := scheduler.Parse(req.Schedule)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ws := workspaceOf(c)
+		quota := registry.quota(ws.ID, cfg)
+		if gap := minGap(parsed, time.Now()); quota.MinCycle > 0 && gap > 0 && gap < time.Duration(quota.MinCycle) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("schedule runs every %s, at least %s is required in this workspace", gap, quota.MinCycle)})
+			return
+		}
+
+		job, err := sched.Add(scheduler.Job{
+			Name:         req.Name,
+			Symbol:       req.Symbol,
+			Intervals:    req.Intervals,
+			Schedule:     req.Schedule,
+			Backend:      req.Backend,
+			Ensemble:     req.Ensemble,
+			MissedPolicy: req.MissedPolicy,
+			OwnerID:      principalOf(c).UserID,
+			WorkspaceID:  ws.ID,
+		})
+		if err != nil {
+			logger.Warn().Err(err).Msg("Create schedule error")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info().
+			Str("schedule_id", job.ID).
+			Str("symbol", job.Symbol).
+			Str("schedule", job.Schedule).
+			Time("next_run", time.Unix(job.NextRun, 0)).
+			Dur("duration_ms", time.Since(start)).
+			Msg("Processed /api/schedules")
+		c.JSON(http.StatusOK, job)
+	})
+
+	authed.DELETE("/api/schedules/:id", manage, func(c *gin.Context) {
+		job, ok := sched.Job(c.Param("id"))
+		if !ok || !canAccessJob(c, job) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+			return
+		}
+		if err := sched.Remove(job.ID); err != nil {
+			logger.Error().Err(err).Str("schedule_id", job.ID).Msg("Delete schedule error")
+			c.JSON(scheduleErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info().Str("schedule_id", job.ID).Msg("Deleted schedule")
+		c.JSON(http.StatusOK, gin.H{"message": "Schedule deleted"})
+	})
+
+	authed.POST("/api/schedules/:id/run", manage, func(c *gin.Context) {
+		job, ok := sched.Job(c.Param("id"))
+		if !ok || !canAccessJob(c, job) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+			return
+		}
+		if err := sched.Trigger(job.ID); err != nil {
+			c.JSON(scheduleErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info().Str("schedule_id", job.ID).Msg("Triggered schedule")
+		c.JSON(http.StatusAccepted, gin.H{"message": "Scheduled analysis started"})
+	})
+}
+
+func scheduleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, scheduler.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, scheduler.ErrRunning):
+		return http.StatusConflict
+	case errors.Is(err, scheduler.ErrNotStarted):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
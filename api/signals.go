@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/auth"
+	"github.com/songzhibin97/CryptoPulse/signals"
+)
+
+// maxSignalsLimit bounds the signals GET /api/signals returns
+const maxSignalsLimit = 1000
+
+// signalKeepAlive is how often an idle signal stream sends a ping event
+const signalKeepAlive = 30 * time.Second
+
+// signalQuery reads the filter of a signal request: the caller's workspace,
+// or every workspace for admins asking for all=true
+func signalQuery(c *gin.Context) (signals.Query, error) {
+	q := signals.Query{
+		WorkspaceID: workspaceOf(c).ID,
+		OwnerID:     principalOf(c).UserID,
+		Symbol:      strings.ToUpper(c.Query("symbol")),
+		Direction:   strings.ToLower(c.Query("direction")),
+	}
+	if principalOf(c).Has(auth.ScopeAdmin) && c.Query("all") == "true" {
+		q.All = true
+	}
+	switch q.Direction {
+	case "", signals.Long, signals.Short, signals.Flat:
+	default:
+		return q, errors.New("direction must be long, short or flat")
+	}
+	if v := c.Query("min_confidence"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > 1 {
+			return q, errors.New("min_confidence must be between 0 and 1")
+		}
+		q.MinConfidence = f
+	}
+	return q, nil
+}
+
+// setupSignalRoutes registers the routes serving the normalized signals of
+// reports
+func setupSignalRoutes(ctx context.Context, authed gin.IRoutes, feed *signals.Feed, logger zerolog.Logger) {
+	read := requireScope(auth.ScopeReadReports)
+
+	authed.GET("/api/signals", read, func(c *gin.Context) {
+		start := time.Now()
+		q, err := signalQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if q.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "100")); err != nil || q.Limit < 1 || q.Limit > maxSignalsLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+			return
+		}
+		if v := c.Query("since"); v != "" {
+			if q.Since, err = strconv.ParseInt(v, 10, 64); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a Unix millisecond time"})
+				return
+			}
+		}
+		out := feed.Query(q)
+		logger.Info().Int("count", len(out)).Dur("duration_ms", time.Since(start)).Msg("Processed /api/signals")
+		c.JSON(http.StatusOK, out)
+	})
+
+	// The stream sends a "signal" event per new matching signal as
+	// server-sent events, and ends when the server shuts down
+	authed.GET("/api/signals/stream", read, func(c *gin.Context) {
+		q, err := signalQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ch, unsubscribe := feed.Subscribe(q)
+		defer unsubscribe()
+		logger.Info().Str("workspace_id", q.WorkspaceID).Str("symbol", q.Symbol).Msg("Signal stream opened")
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		ping := time.NewTicker(signalKeepAlive)
+		defer ping.Stop()
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case s := <-ch:
+				c.SSEvent("signal", s)
+			case <-ping.C:
+				c.SSEvent("ping", time.Now().UnixMilli())
+			case <-c.Request.Context().Done():
+				return false
+			case <-ctx.Done():
+				return false
+			}
+			return true
+		})
+		logger.Info().Str("workspace_id", q.WorkspaceID).Msg("Signal stream closed")
+	})
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/auth"
+	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/queue"
+	"github.com/songzhibin97/CryptoPulse/spread"
+)
+
+// canAccessSpread reports whether the caller may see or stop a spread monitor
+func canAccessSpread(c *gin.Context, s spread.Status) bool {
+	return principalOf(c).Has(auth.ScopeAdmin) || s.WorkspaceID == workspaceOf(c).ID
+}
+
+// setupSpreadRoutes registers the routes that compare order books across
+// venues and manage cross-exchange spread monitors
+func setupSpreadRoutes(authed gin.IRoutes, store *config.Store, spreads *spread.Manager, registry *analyzerRegistry, logger zerolog.Logger) {
+	manage := requireScope(auth.ScopeManageMonitors)
+
+	authed.GET("/api/spread", func(c *gin.Context) {
+		start := time.Now()
+		size, err := strconv.ParseFloat(c.DefaultQuery("size", "1"), 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid size"})
+			return
+		}
+		spec := spread.Spec{Symbol: strings.ToUpper(c.Query("symbol")), Size: size}
+		if v := c.Query("venues"); v != "" {
+			spec.Venues = strings.Split(v, ",")
+		}
+		spec = spreads.Defaults(spec)
+		if err := spec.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		snap, err := spreads.Snapshot(c.Request.Context(), spec, queue.Interactive)
+		if err != nil {
+			logger.Error().Err(err).Str("symbol", spec.Symbol).Msg("Failed to fetch order books")
+			c.JSON(fetchErrorStatus(err), gin.H{"error": "failed to fetch order books"})
+			return
+		}
+		logger.Info().
+			Str("symbol", spec.Symbol).
+			Float64("divergence_bps", snap.DivergenceBps).
+			Dur("duration_ms", time.Since(start)).
+			Msg("Processed /api/spread")
+		c.JSON(http.StatusOK, snap)
+	})
+
+	authed.GET("/api/spreads", manage, func(c *gin.Context) {
+		workspaceID := workspaceOf(c).ID
+		if principalOf(c).Has(auth.ScopeAdmin) && c.Query("all") == "true" {
+			workspaceID = ""
+		}
+		c.JSON(http.StatusOK, spreads.List(workspaceID))
+	})
+
+	authed.POST("/api/spreads", manage, func(c *gin.Context) {
+		start := time.Now()
+		var spec spread.Spec
+		if err := c.BindJSON(&spec); err != nil {
+			logger.Error().Err(err).Msg("Invalid request body")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		spec.Symbol = strings.ToUpper(spec.Symbol)
+		spec = spreads.Defaults(spec)
+		if err := spec.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if registry.ctx.Err() != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+			return
+		}
+		ws := workspaceOf(c)
+		quota := registry.quota(ws.ID, store.Get())
+		if quota.MaxMonitors > 0 && spreads.Count(ws.ID) >= quota.MaxMonitors {
+			err := fmt.Errorf("%w: maximum of %d spread monitors", errMonitorQuota, quota.MaxMonitors)
+			c.JSON(startErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		status, err := spreads.Start(spec, principalOf(c).UserID, ws.ID)
+		if err != nil {
+			logger.Error().Err(err).Msg("Start spread monitor error")
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info().
+			Str("spread_id", status.ID).
+			Str("workspace_id", ws.ID).
+			Dur("duration_ms", time.Since(start)).
+			Msg("Processed /api/spreads")
+		c.JSON(http.StatusOK, status)
+	})
+
+	authed.GET("/api/spreads/:id", manage, func(c *gin.Context) {
+		s, ok := spreads.Get(c.Param("id"))
+		if !ok || !canAccessSpread(c, s) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "spread monitor not found"})
+			return
+		}
+		c.JSON(http.StatusOK, s)
+	})
+
+	authed.DELETE("/api/spreads/:id", manage, func(c *gin.Context) {
+		s, ok := spreads.Get(c.Param("id"))
+		if !ok || !canAccessSpread(c, s) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "spread monitor not found"})
+			return
+		}
+		if err := spreads.Stop(s.ID); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, spread.ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Spread monitor stopped"})
+	})
+}
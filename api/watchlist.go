@@ -0,0 +1,196 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/analyzer"
+	"github.com/songzhibin97/CryptoPulse/auth"
+	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/queue"
+	"github.com/songzhibin97/CryptoPulse/watchlist"
+)
+
+// canAccessWatchlist reports whether the caller may see, change or monitor
+// a watchlist
+func canAccessWatchlist(c *gin.Context, w watchlist.Watchlist) bool {
+	return principalOf(c).Has(auth.ScopeAdmin) || w.WorkspaceID == workspaceOf(c).ID
+}
+
+// watchlistErrorStatus maps a watchlist store error to its HTTP status
+func watchlistErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, watchlist.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, watchlist.ErrDuplicate):
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// lookupWatchlist returns a watchlist the caller may access
+func lookupWatchlist(c *gin.Context, watchlists *watchlist.Store, id string) (watchlist.Watchlist, bool) {
+	w, ok := watchlists.Get(id)
+	return w, ok && canAccessWatchlist(c, w)
+}
+
+// setupWatchlistRoutes registers the routes that manage watchlists. Running
+// basket monitors keep the symbols they were started with.
+func setupWatchlistRoutes(authed gin.IRoutes, store *config.Store, watchlists *watchlist.Store, registry *analyzerRegistry, logger zerolog.Logger) {
+	manage := requireScope(auth.ScopeManageMonitors)
+
+	authed.GET("/api/watchlists", func(c *gin.Context) {
+		workspaceID := workspaceOf(c).ID
+		if principalOf(c).Has(auth.ScopeAdmin) && c.Query("all") == "true" {
+			workspaceID = ""
+		}
+		c.JSON(http.StatusOK, watchlists.List(workspaceID))
+	})
+
+	authed.POST("/api/watchlists", manage, func(c *gin.Context) {
+		start := time.Now()
+		var req struct {
+			Name    string   `json:"name"`
+			Symbols []string `json:"symbols"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			logger.Error().Err(err).Msg("Invalid request body")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		w, err := watchlists.Create(watchlist.Watchlist{
+			Name:        req.Name,
+			Symbols:     req.Symbols,
+			OwnerID:     principalOf(c).UserID,
+			WorkspaceID: workspaceOf(c).ID,
+		})
+		if err != nil {
+			logger.Warn().Err(err).Str("name", req.Name).Msg("Create watchlist error")
+			c.JSON(watchlistErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info().Str("watchlist_id", w.ID).Int("symbols", len(w.Symbols)).Dur("duration_ms", time.Since(start)).Msg("Created watchlist")
+		c.JSON(http.StatusOK, w)
+	})
+
+	authed.PUT("/api/watchlists/:id", manage, func(c *gin.Context) {
+		start := time.Now()
+		var req struct {
+			Name    string   `json:"name"`
+			Symbols []string `json:"symbols"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			logger.Error().Err(err).Msg("Invalid request body")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, ok := lookupWatchlist(c, watchlists, c.Param("id")); !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "watchlist not found"})
+			return
+		}
+		w, err := watchlists.Update(c.Param("id"), req.Name, req.Symbols)
+		if err != nil {
+			logger.Warn().Err(err).Str("watchlist_id", c.Param("id")).Msg("Update watchlist error")
+			c.JSON(watchlistErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info().Str("watchlist_id", w.ID).Int("symbols", len(w.Symbols)).Dur("duration_ms", time.Since(start)).Msg("Updated watchlist")
+		c.JSON(http.StatusOK, w)
+	})
+
+	authed.DELETE("/api/watchlists/:id", manage, func(c *gin.Context) {
+		if _, ok := lookupWatchlist(c, watchlists, c.Param("id")); !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "watchlist not found"})
+			return
+		}
+		if err := watchlists.Delete(c.Param("id")); err != nil {
+			logger.Error().Err(err).Str("watchlist_id", c.Param("id")).Msg("Delete watchlist error")
+			c.JSON(watchlistErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info().Str("watchlist_id", c.Param("id")).Msg("Deleted watchlist")
+		c.JSON(http.StatusOK, gin.H{"message": "Watchlist deleted"})
+	})
+
+	authed.GET("/api/watchlists/:id/correlation", func(c *gin.Context) {
+		start := time.Now()
+		w, ok := lookupWatchlist(c, watchlists, c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "watchlist not found"})
+			return
+		}
+		interval := c.DefaultQuery("interval", "1h")
+		if !validIntervals[interval] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid interval: " + interval})
+			return
+		}
+		window, err := strconv.Atoi(c.DefaultQuery("window", "0"))
+		if err != nil || window < 0 || window > config.MaxCorrelationWindow {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("window must be between 0 and %d", config.MaxCorrelationWindow)})
+			return
+		}
+
+		// Prefer the klines a running basket monitor of the watchlist holds
+		source, monitorID := "fetched", ""
+		var ma *analyzer.MarketAnalyzer
+		registry.mu.RLock()
+		for id, m := range registry.monitors {
+			if m.basket != nil && m.basket.ID == w.ID && slices.Contains(m.intervals, interval) && canAccessMonitor(c, m) {
+				ma, source, monitorID = m.analyzer, "monitor", id
+				break
+			}
+		}
+		registry.mu.RUnlock()
+		if ma == nil {
+			cfg := store.Get()
+			// Fetch enough candles for the requested window
+			cfg.Correlation.Window = max(window, cfg.Correlation.Returns())
+			ma = registry.newBasketAnalyzer(c.Request.Context(), &w, []string{interval}, cfg, queue.Interactive)
+			defer ma.Stop()
+			if err := ma.FetchRealtimeData(c.Request.Context()); err != nil {
+				logger.Error().Err(err).Str("watchlist_id", w.ID).Msg("Failed to fetch watchlist data")
+				c.JSON(fetchErrorStatus(err), gin.H{"error": "failed to fetch market data"})
+				return
+			}
+		}
+		matrix, err := ma.Correlation(interval, window)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info().
+			Str("watchlist_id", w.ID).
+			Str("interval", interval).
+			Str("source", source).
+			Dur("duration_ms", time.Since(start)).
+			Msg("Processed /api/watchlists/:id/correlation")
+		c.JSON(http.StatusOK, gin.H{
+			"watchlist":   w.Name,
+			"source":      source,
+			"monitor_id":  monitorID,
+			"correlation": matrix,
+		})
+	})
+
+	authed.GET("/api/monitors/:id/snapshots", manage, func(c *gin.Context) {
+		m, ok := registry.lookup(c, c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
+			return
+		}
+		if m.basket == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "monitor does not cover a watchlist"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"watchlist": m.basket.Name,
+			"snapshots": m.analyzer.Snapshots(),
+		})
+	})
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/auth"
+	"github.com/songzhibin97/CryptoPulse/config"
+)
+
+const (
+	workspaceHeader = "X-Workspace"
+	workspaceKey    = "workspace"
+)
+
+// selectWorkspace resolves the workspace a request works in from the
+// X-Workspace header or workspace query parameter, defaulting to the
+// caller's first workspace, or any workspace for admins. Callers must be
+// members unless they are admins; requests that resolve no workspace are
+// refused so handlers never see an empty workspace ID.
+func selectWorkspace(svc *auth.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if svc == nil {
+			c.Set(workspaceKey, auth.Workspace{ID: auth.DefaultWorkspace, Name: auth.DefaultWorkspace})
+			c.Next()
+			return
+		}
+		p := principalOf(c)
+		id := c.GetHeader(workspaceHeader)
+		if id == "" {
+			id = c.Query("workspace")
+		}
+		if id == "" {
+			ws := svc.Workspaces(p.UserID)
+			if len(ws) == 0 && p.Has(auth.ScopeAdmin) {
+				ws = svc.AllWorkspaces()
+			}
+			if len(ws) == 0 {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not a member of any workspace"})
+				return
+			}
+			c.Set(workspaceKey, ws[0])
+			c.Next()
+			return
+		}
+		ws, ok := svc.Workspace(id)
+		if !ok || !p.CanUse(ws) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "workspace not found or not a member"})
+			return
+		}
+		c.Set(workspaceKey, ws)
+		c.Next()
+	}
+}
+
+// workspaceOf returns the workspace set by selectWorkspace
+func workspaceOf(c *gin.Context) auth.Workspace {
+	if v, ok := c.Get(workspaceKey); ok {
+		return v.(auth.Workspace)
+	}
+	return auth.Workspace{}
+}
+
+// setupWorkspaceRoutes registers workspace listing, creation, quota and
+// membership routes. authed routes run without a selected workspace, so
+// members of none can still list and manage workspaces; scoped routes run
+// selectWorkspace.
+func setupWorkspaceRoutes(authed, scoped gin.IRoutes, svc *auth.Service, store *config.Store, registry *analyzerRegistry, logger zerolog.Logger) {
+	authed.GET("/api/workspaces", func(c *gin.Context) {
+		if svc == nil {
+			c.JSON(http.StatusOK, []auth.Workspace{{ID: auth.DefaultWorkspace, Name: auth.DefaultWorkspace}})
+			return
+		}
+		p := principalOf(c)
+		if p.Has(auth.ScopeAdmin) && c.Query("all") == "true" {
+			c.JSON(http.StatusOK, svc.AllWorkspaces())
+			return
+		}
+		c.JSON(http.StatusOK, svc.Workspaces(p.UserID))
+	})
+
+	scoped.GET("/api/workspace", func(c *gin.Context) {
+		ws := workspaceOf(c)
+		quota := ws.EffectiveQuota(store.Get().WorkspaceQuota)
+		c.JSON(http.StatusOK, gin.H{
+			"workspace": ws,
+			"quota":     quota,
+			"monitors":  registry.count(ws.ID),
+		})
+	})
+	if svc == nil {
+		return
+	}
+
+	authed.POST("/api/workspaces", requireScope(auth.ScopeAdmin), func(c *gin.Context) {
+		start := time.Now()
+		var req struct {
+			Name    string                 `json:"name"`
+			OwnerID string                 `json:"owner_id"`
+			Quota   *config.WorkspaceQuota `json:"quota"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			logger.Error().Err(err).Msg("Invalid request body")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.OwnerID == "" {
+			req.OwnerID = principalOf(c).UserID
+		}
+		ws, err := svc.CreateWorkspace(req.Name, req.OwnerID, req.Quota)
+		if err != nil {
+			c.JSON(workspaceErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info().Str("workspace_id", ws.ID).Str("name", ws.Name).Dur("duration_ms", time.Since(start)).Msg("Processed /api/workspaces")
+		c.JSON(http.StatusOK, ws)
+	})
+
+	authed.PUT("/api/workspaces/:id/quota", requireScope(auth.ScopeAdmin), func(c *gin.Context) {
+		var quota *config.WorkspaceQuota
+		if err := c.BindJSON(&quota); err != nil {
+			logger.Error().Err(err).Msg("Invalid request body")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ws, err := svc.SetQuota(c.Param("id"), quota)
+		if err != nil {
+			c.JSON(workspaceErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		registry.applyWorkspaceBudget(ws.ID, ws.EffectiveQuota(store.Get().WorkspaceQuota).AIBudget)
+		logger.Info().Str("workspace_id", ws.ID).Interface("quota", quota).Msg("Updated workspace quota")
+		c.JSON(http.StatusOK, ws)
+	})
+
+	authed.PUT("/api/workspaces/:id/members", func(c *gin.Context) {
+		var req struct {
+			UserID string `json:"user_id"`
+			Role   string `json:"role"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			logger.Error().Err(err).Msg("Invalid request body")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Role == "" {
+			req.Role = auth.RoleMember
+		}
+		current, ok := svc.Workspace(c.Param("id"))
+		if !ok || !principalOf(c).CanManage(current) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "workspace not found or not an owner"})
+			return
+		}
+		ws, err := svc.SetMember(current.ID, req.UserID, req.Role)
+		if err != nil {
+			c.JSON(workspaceErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info().Str("workspace_id", ws.ID).Str("user_id", req.UserID).Str("role", req.Role).Msg("Updated workspace member")
+		c.JSON(http.StatusOK, ws)
+	})
+
+	authed.DELETE("/api/workspaces/:id/members/:user_id", func(c *gin.Context) {
+		current, ok := svc.Workspace(c.Param("id"))
+		if !ok || !principalOf(c).CanManage(current) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "workspace not found or not an owner"})
+			return
+		}
+		ws, err := svc.RemoveMember(current.ID, c.Param("user_id"))
+		if err != nil {
+			c.JSON(workspaceErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info().Str("workspace_id", ws.ID).Str("user_id", c.Param("user_id")).Msg("Removed workspace member")
+		c.JSON(http.StatusOK, ws)
+	})
+}
+
+func workspaceErrorStatus(err error) int {
+	if errors.Is(err, auth.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
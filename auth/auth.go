@@ -0,0 +1,471 @@
+package auth
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scopes granted to users, sessions and API keys
+const (
+	ScopeReadReports     = "reports:read"
+	ScopeManageMonitors  = "monitors:manage"
+	ScopeSubmitResponses = "responses:submit"
+	// ScopeAdmin grants every scope, access to all users' monitors and
+	// reports, and user management
+	ScopeAdmin = "admin"
+)
+
+// Scopes lists every known scope
+var Scopes = []string{ScopeReadReports, ScopeManageMonitors, ScopeSubmitResponses, ScopeAdmin}
+
+// keyPrefix marks CryptoPulse API keys
+const keyPrefix = "cp_"
+
+const (
+	passwordIterations = 210000
+	passwordKeyLength  = 32
+	minPasswordLength  = 8
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrUserExists         = errors.New("user already exists")
+	ErrNotFound           = errors.New("not found")
+)
+
+// User is an account that can log in to the UI and own API keys
+type User struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	PasswordHash string   `json:"password_hash,omitempty"`
+	Scopes       []string `json:"scopes"`
+	CreatedAt    int64    `json:"created_at"`
+}
+
+// APIKey is a user's key for programmatic access. Only the SHA-256 hash of
+// the key is stored; the key itself is shown once when it is created.
+type APIKey struct {
+	ID         string   `json:"id"`
+	UserID     string   `json:"user_id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Hash       string   `json:"hash,omitempty"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  int64    `json:"created_at"`
+	LastUsedAt int64    `json:"last_used_at,omitempty"`
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID   string   `json:"user_id"`
+	UserName string   `json:"user_name"`
+	KeyID    string   `json:"key_id,omitempty"`
+	Scopes   []string `json:"scopes"`
+}
+
+// Has reports whether the principal was granted scope
+func (p Principal) Has(scope string) bool {
+	return slices.Contains(p.Scopes, ScopeAdmin) || slices.Contains(p.Scopes, scope)
+}
+
+// CanAccess reports whether the principal may see a resource owned by ownerID
+func (p Principal) CanAccess(ownerID string) bool {
+	return p.Has(ScopeAdmin) || (ownerID != "" && ownerID == p.UserID)
+}
+
+type session struct {
+	userID    string
+	expiresAt time.Time
+}
+
+type state struct {
+	Users      []User      `json:"users"`
+	Keys       []APIKey    `json:"keys"`
+	Workspaces []Workspace `json:"workspaces"`
+}
+
+// Service stores users, API keys and workspaces in a JSON file and keeps login sessions
+// in memory, so sessions end when the server restarts
+type Service struct {
+	path       string
+	state      state
+	sessions   map[string]session
+	sessionTTL time.Duration
+	// lastUsed holds the last use of API keys by key ID, in Unix
+	// milliseconds, so authenticating needs no write lock. It is merged
+	// into the state on the next save.
+	lastUsed sync.Map
+	mu       sync.RWMutex
+}
+
+// NewService creates a Service persisting to path, loading existing users,
+// keys and workspaces
+func NewService(path string, sessionTTL time.Duration) (*Service, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	s := &Service{path: path, sessions: make(map[string]session), sessionTTL: sessionTTL}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.state); err != nil {
+		return nil, fmt.Errorf("parse auth file %s: %w", path, err)
+	}
+	if s.ensurePersonalWorkspaces() {
+		if err := s.save(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Bootstrap creates an admin user when no users exist yet. If password is
+// empty a random one is generated and returned so it can be shown once.
+func (s *Service) Bootstrap(name, password string) (string, bool, error) {
+	s.mu.RLock()
+	empty := len(s.state.Users) == 0
+	s.mu.RUnlock()
+	if !empty {
+		return "", false, nil
+	}
+	if password == "" {
+		password = randomToken(12)
+	}
+	if _, err := s.CreateUser(name, password, []string{ScopeAdmin}); err != nil {
+		return "", false, err
+	}
+	return password, true, nil
+}
+
+// CreateUser adds a user with the given scopes and a personal workspace
+func (s *Service) CreateUser(name, password string, scopes []string) (User, error) {
+	if name == "" {
+		return User{}, errors.New("user name is required")
+	}
+	if len(password) < minPasswordLength {
+		return User{}, fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	if err := checkScopes(scopes); err != nil {
+		return User{}, err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.userByName(name); ok {
+		return User{}, ErrUserExists
+	}
+	user := User{
+		ID:           uuid.New().String(),
+		Name:         name,
+		PasswordHash: hash,
+		Scopes:       scopes,
+		CreatedAt:    time.Now().UnixMilli(),
+	}
+	s.state.Users = append(s.state.Users, user)
+	s.addWorkspace(name, user.ID, nil)
+	return user, s.save()
+}
+
+// Users returns every user without password hashes
+func (s *Service) Users() []User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	users := make([]User, 0, len(s.state.Users))
+	for _, u := range s.state.Users {
+		u.PasswordHash = ""
+		users = append(users, u)
+	}
+	return users
+}
+
+// ChangePassword replaces a user's password after checking the current one
+func (s *Service) ChangePassword(userID, current, next string) error {
+	if len(next) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	hash, err := hashPassword(next)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, u := range s.state.Users {
+		if u.ID != userID {
+			continue
+		}
+		if !checkPassword(u.PasswordHash, current) {
+			return ErrInvalidCredentials
+		}
+		s.state.Users[i].PasswordHash = hash
+		return s.save()
+	}
+	return ErrNotFound
+}
+
+// Login checks a user's password and starts a session, returning its token
+func (s *Service) Login(name, password string) (string, Principal, error) {
+	s.mu.RLock()
+	user, ok := s.userByName(name)
+	s.mu.RUnlock()
+	// Hash outside the lock; PBKDF2 is deliberately slow
+	if !ok || !checkPassword(user.PasswordHash, password) {
+		return "", Principal{}, ErrInvalidCredentials
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token := randomToken(32)
+	now := time.Now()
+	for k, sess := range s.sessions {
+		if now.After(sess.expiresAt) {
+			delete(s.sessions, k)
+		}
+	}
+	s.sessions[hashToken(token)] = session{userID: user.ID, expiresAt: now.Add(s.sessionTTL)}
+	return token, principalFor(user), nil
+}
+
+// Logout ends the session identified by token
+func (s *Service) Logout(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, hashToken(token))
+}
+
+// Session returns the principal of a valid session token
+func (s *Service) Session(token string) (Principal, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sess, ok := s.sessions[hashToken(token)]
+	if !ok || time.Now().After(sess.expiresAt) {
+		return Principal{}, false
+	}
+	user, ok := s.userByID(sess.userID)
+	if !ok {
+		return Principal{}, false
+	}
+	return principalFor(user), true
+}
+
+// CreateKey issues an API key for a user. The key's scopes must be a subset
+// of the user's scopes. The returned plaintext key is not stored.
+func (s *Service) CreateKey(userID, name string, scopes []string) (APIKey, string, error) {
+	if len(scopes) == 0 {
+		return APIKey{}, "", errors.New("at least one scope is required")
+	}
+	if err := checkScopes(scopes); err != nil {
+		return APIKey{}, "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.userByID(userID)
+	if !ok {
+		return APIKey{}, "", ErrNotFound
+	}
+	owner := principalFor(user)
+	for _, scope := range scopes {
+		if !owner.Has(scope) {
+			return APIKey{}, "", fmt.Errorf("scope %q exceeds the user's scopes", scope)
+		}
+	}
+	secret := keyPrefix + randomToken(32)
+	key := APIKey{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    secret[:len(keyPrefix)+8],
+		Hash:      hashToken(secret),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UnixMilli(),
+	}
+	s.state.Keys = append(s.state.Keys, key)
+	if err := s.save(); err != nil {
+		return APIKey{}, "", err
+	}
+	key.Hash = ""
+	return key, secret, nil
+}
+
+// Keys returns a user's API keys without their hashes
+func (s *Service) Keys(userID string) []APIKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]APIKey, 0)
+	for _, k := range s.state.Keys {
+		if k.UserID == userID {
+			k.Hash = ""
+			if t, ok := s.lastUsed.Load(k.ID); ok {
+				k.LastUsedAt = t.(int64)
+			}
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// RevokeKey deletes an API key owned by userID
+func (s *Service) RevokeKey(userID, keyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, k := range s.state.Keys {
+		if k.ID == keyID && k.UserID == userID {
+			s.state.Keys = append(s.state.Keys[:i], s.state.Keys[i+1:]...)
+			s.lastUsed.Delete(keyID)
+			return s.save()
+		}
+	}
+	return ErrNotFound
+}
+
+// Authenticate returns the principal of an API key. The key's scopes are
+// limited to those its user still has.
+func (s *Service) Authenticate(secret string) (Principal, bool) {
+	if !strings.HasPrefix(secret, keyPrefix) {
+		return Principal{}, false
+	}
+	hash := hashToken(secret)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.state.Keys {
+		if subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hash)) != 1 {
+			continue
+		}
+		user, ok := s.userByID(k.UserID)
+		if !ok {
+			return Principal{}, false
+		}
+		// Last use is kept in memory and persisted with the next change
+		s.lastUsed.Store(k.ID, time.Now().UnixMilli())
+		owner := principalFor(user)
+		p := Principal{UserID: user.ID, UserName: user.Name, KeyID: k.ID}
+		for _, scope := range k.Scopes {
+			if owner.Has(scope) {
+				p.Scopes = append(p.Scopes, scope)
+			}
+		}
+		return p, true
+	}
+	return Principal{}, false
+}
+
+func (s *Service) userByName(name string) (User, bool) {
+	for _, u := range s.state.Users {
+		if u.Name == name {
+			return u, true
+		}
+	}
+	return User{}, false
+}
+
+func (s *Service) userByID(id string) (User, bool) {
+	for _, u := range s.state.Users {
+		if u.ID == id {
+			return u, true
+		}
+	}
+	return User{}, false
+}
+
+// save writes the users and keys to disk; callers hold s.mu
+func (s *Service) save() error {
+	for i, k := range s.state.Keys {
+		if t, ok := s.lastUsed.Load(k.ID); ok {
+			s.state.Keys[i].LastUsedAt = t.(int64)
+		}
+	}
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func principalFor(u User) Principal {
+	return Principal{UserID: u.ID, UserName: u.Name, Scopes: u.Scopes}
+}
+
+func checkScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return nil
+}
+
+// hashPassword derives a salted PBKDF2-SHA256 hash encoded as
+// pbkdf2-sha256$<iterations>$<salt>$<hash>
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, passwordKeyLength)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+func checkPassword(encoded, password string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	enc := base64.RawStdEncoding
+	salt, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := enc.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/songzhibin97/CryptoPulse/config"
+)
+
+// Workspace roles
+const (
+	RoleOwner  = "owner"
+	RoleMember = "member"
+)
+
+// DefaultWorkspace is the single implicit workspace used when auth is disabled
+const DefaultWorkspace = "default"
+
+// Member is a user's membership in a workspace
+type Member struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// Workspace groups monitors, reports and usage shared by its members. A nil
+// Quota falls back to the configured workspace_quota.
+type Workspace struct {
+	ID        string                 `json:"id"`
+	Name      string                 `json:"name"`
+	Members   []Member               `json:"members"`
+	Quota     *config.WorkspaceQuota `json:"quota,omitempty"`
+	CreatedAt int64                  `json:"created_at"`
+}
+
+// Role returns the user's role in the workspace, or "" if not a member
+func (w Workspace) Role(userID string) string {
+	for _, m := range w.Members {
+		if m.UserID == userID {
+			return m.Role
+		}
+	}
+	return ""
+}
+
+// EffectiveQuota returns the workspace's own quota or the default
+func (w Workspace) EffectiveQuota(def config.WorkspaceQuota) config.WorkspaceQuota {
+	if w.Quota != nil {
+		return *w.Quota
+	}
+	return def
+}
+
+// CanUse reports whether the principal may work in the workspace
+func (p Principal) CanUse(w Workspace) bool {
+	return p.Has(ScopeAdmin) || w.Role(p.UserID) != ""
+}
+
+// CanManage reports whether the principal may change the workspace's members
+func (p Principal) CanManage(w Workspace) bool {
+	return p.Has(ScopeAdmin) || w.Role(p.UserID) == RoleOwner
+}
+
+// Workspace looks up a workspace by ID
+func (s *Service) Workspace(id string) (Workspace, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i := s.workspaceIndex(id)
+	if i < 0 {
+		return Workspace{}, false
+	}
+	return s.state.Workspaces[i], true
+}
+
+// Workspaces returns the workspaces the user is a member of, personal
+// workspace first
+func (s *Service) Workspaces(userID string) []Workspace {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Workspace, 0)
+	for _, w := range s.state.Workspaces {
+		if w.Role(userID) != "" {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// AllWorkspaces returns every workspace
+func (s *Service) AllWorkspaces() []Workspace {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.state.Workspaces)
+}
+
+// CreateWorkspace adds a workspace owned by ownerID
+func (s *Service) CreateWorkspace(name, ownerID string, quota *config.WorkspaceQuota) (Workspace, error) {
+	if name == "" {
+		return Workspace{}, errors.New("workspace name is required")
+	}
+	if quota != nil {
+		if err := quota.Validate(); err != nil {
+			return Workspace{}, err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.userByID(ownerID); !ok {
+		return Workspace{}, ErrNotFound
+	}
+	w := s.addWorkspace(name, ownerID, quota)
+	return w, s.save()
+}
+
+// SetQuota replaces a workspace's quota; nil restores the default
+func (s *Service) SetQuota(id string, quota *config.WorkspaceQuota) (Workspace, error) {
+	if quota != nil {
+		if err := quota.Validate(); err != nil {
+			return Workspace{}, err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.workspaceIndex(id)
+	if i < 0 {
+		return Workspace{}, ErrNotFound
+	}
+	s.state.Workspaces[i].Quota = quota
+	return s.state.Workspaces[i], s.save()
+}
+
+// SetMember adds a user to a workspace or changes their role
+func (s *Service) SetMember(id, userID, role string) (Workspace, error) {
+	if role != RoleOwner && role != RoleMember {
+		return Workspace{}, errors.New("role must be owner or member")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.workspaceIndex(id)
+	if i < 0 {
+		return Workspace{}, ErrNotFound
+	}
+	if _, ok := s.userByID(userID); !ok {
+		return Workspace{}, ErrNotFound
+	}
+	w := &s.state.Workspaces[i]
+	for j, m := range w.Members {
+		if m.UserID == userID {
+			w.Members[j].Role = role
+			return *w, s.save()
+		}
+	}
+	w.Members = append(w.Members, Member{UserID: userID, Role: role})
+	return *w, s.save()
+}
+
+// RemoveMember removes a user from a workspace. The last owner cannot be removed.
+func (s *Service) RemoveMember(id, userID string) (Workspace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.workspaceIndex(id)
+	if i < 0 {
+		return Workspace{}, ErrNotFound
+	}
+	w := &s.state.Workspaces[i]
+	owners := 0
+	for _, m := range w.Members {
+		if m.Role == RoleOwner {
+			owners++
+		}
+	}
+	for j, m := range w.Members {
+		if m.UserID != userID {
+			continue
+		}
+		if m.Role == RoleOwner && owners == 1 {
+			return Workspace{}, errors.New("cannot remove the last owner")
+		}
+		w.Members = append(w.Members[:j], w.Members[j+1:]...)
+		return *w, s.save()
+	}
+	return Workspace{}, ErrNotFound
+}
+
+// addWorkspace appends a workspace; callers hold s.mu and save
+func (s *Service) addWorkspace(name, ownerID string, quota *config.WorkspaceQuota) Workspace {
+	w := Workspace{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Members:   []Member{{UserID: ownerID, Role: RoleOwner}},
+		Quota:     quota,
+		CreatedAt: time.Now().UnixMilli(),
+	}
+	s.state.Workspaces = append(s.state.Workspaces, w)
+	return w
+}
+
+// ensurePersonalWorkspaces gives every user without a workspace a personal
+// one, e.g. users created before workspaces existed; callers hold s.mu
+func (s *Service) ensurePersonalWorkspaces() bool {
+	changed := false
+	for _, u := range s.state.Users {
+		if !slices.ContainsFunc(s.state.Workspaces, func(w Workspace) bool { return w.Role(u.ID) != "" }) {
+			s.addWorkspace(u.Name, u.ID, nil)
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (s *Service) workspaceIndex(id string) int {
+	return slices.IndexFunc(s.state.Workspaces, func(w Workspace) bool { return w.ID == id })
+}
@@ -1,29 +1,939 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+// DefaultPath is the configuration file used when none is given
+const DefaultPath = "config/config.yaml"
+
+// EnvPrefix prefixes the environment variables that override config values,
+// e.g. CRYPTOPULSE_PORT or CRYPTOPULSE_AI_BUDGET_DAILY_USD
+const EnvPrefix = "CRYPTOPULSE_"
+
+// DefaultTokenBudget is the prompt token budget used when no budget is configured
+const DefaultTokenBudget = 6000
+
+// DefaultAIMaxRepairs is the number of re-prompts after an invalid AI response
+const DefaultAIMaxRepairs = 2
+
+// DefaultEnsembleTimeout bounds how long an ensemble waits for its members
+const DefaultEnsembleTimeout = 120 * time.Second
+
+// Default directories of reports and of the web UI
+const (
+	DefaultReportDir = "reports"
+	DefaultStaticDir = "static"
+)
+
+// DefaultAuthFile is where users and API keys are stored when not configured
+const DefaultAuthFile = "data/auth.json"
+
+// DefaultSessionTTL is how long a UI login stays valid
+const DefaultSessionTTL = 24 * time.Hour
+
+// DefaultShutdownTimeout bounds how long shutdown waits for requests and
+// monitors to finish
+const DefaultShutdownTimeout = 15 * time.Second
+
+// DefaultScheduleFile is where scheduled analyses are stored when not configured
+const DefaultScheduleFile = "data/schedules.json"
+
+// DefaultCloseDelay is how long a scheduled analysis waits after its
+// scheduled time so the candle that closed then is final on the exchange
+const DefaultCloseDelay = 5 * time.Second
+
+// Default sizes of the analysis job queue
+const (
+	DefaultFetchWorkers  = 4
+	DefaultAIWorkers     = 2
+	DefaultQueueCapacity = 100
+)
+
+// DefaultUsageFile is where AI usage records are stored when not configured
+const DefaultUsageFile = "data/usage.jsonl"
+
+// DefaultWatchlistFile is where watchlists are stored when not configured
+const DefaultWatchlistFile = "data/watchlists.json"
+
+// Defaults of the market scanner
+const (
+	DefaultScanInterval = 15 * time.Minute
+	DefaultQuoteAsset   = "USDT"
+	// DefaultScanMaxWeight leaves most of Binance's 6000 weight per minute
+	// to monitors and on-demand requests
+	DefaultScanMaxWeight = 1200
+	DefaultRSIPeriod     = 14
+)
+
+// Defaults of cross-asset correlation
+const (
+	DefaultBenchmark         = "BTCUSDT"
+	DefaultCorrelationWindow = 30
+	// MaxCorrelationWindow is the most returns the klines kept per symbol allow
+	MaxCorrelationWindow = 200
+)
+
+// Defaults of cross-exchange spread monitors
+const (
+	DefaultSpreadDepth    = 50
+	DefaultSpreadInterval = 10 * time.Second
+	// MinSpreadInterval keeps monitors well inside the venues' rate limits
+	MinSpreadInterval = 2 * time.Second
+	// MaxSpreadDepth is the most levels every venue serves in one request
+	MaxSpreadDepth = 200
+)
+
+// DefaultVenues are the exchanges spread monitors compare when neither the
+// monitor nor the config names any
+var DefaultVenues = []string{"binance", "okx", "bybit"}
+
+// Report storage backends
+const (
+	StorageFilesystem = "filesystem"
+	StorageSQLite     = "sqlite"
+	StorageS3         = "s3"
+)
+
+// DefaultSQLiteFile is the database of the sqlite report storage backend
+const DefaultSQLiteFile = "data/reports.db"
+
+// Defaults of report retention
+const (
+	DefaultRetentionInterval = time.Hour
+	DefaultArchiveDir        = "data/archive"
+	DefaultAuditFile         = "data/report_audit.jsonl"
+)
+
+// Defaults of paper trading accounts
+const (
+	DefaultPaperFile       = "data/paper.json"
+	DefaultStartingBalance = 10000
+	DefaultMaxBookAge      = time.Minute
+)
+
+// Duration is a time.Duration read from strings like "30s" or "5m"
+type Duration time.Duration
+
+// UnmarshalYAML parses a duration string
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	return d.Set(value.Value)
+}
+
+// Set parses a duration string; the empty string is zero
+func (d *Duration) Set(s string) error {
+	if s == "" {
+		*d = 0
+		return nil
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// String formats the duration like time.Duration
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalJSON encodes the duration as a string like "30s"
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	return d.Set(s)
+}
+
+// URL is an absolute URL read from a string; the zero value means unset
+type URL struct {
+	*url.URL
+}
+
+// ParseURL parses an absolute URL with a scheme and host
+func ParseURL(s string) (URL, error) {
+	if s == "" {
+		return URL{}, nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return URL{}, fmt.Errorf("invalid url %q: %w", s, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return URL{}, fmt.Errorf("invalid url %q: scheme and host are required", s)
+	}
+	return URL{URL: u}, nil
+}
+
+// UnmarshalYAML parses a URL string
+func (u *URL) UnmarshalYAML(value *yaml.Node) error {
+	return u.Set(value.Value)
+}
+
+// Set parses a URL string; the empty string unsets it
+func (u *URL) Set(s string) error {
+	parsed, err := ParseURL(s)
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// IsSet reports whether a URL was configured
+func (u URL) IsSet() bool {
+	return u.URL != nil
+}
+
+// String returns the URL, or the empty string when unset
+func (u URL) String() string {
+	if u.URL == nil {
+		return ""
+	}
+	return u.URL.String()
+}
+
+// ManualEndpoint is the ai_endpoint of manual mode, in which prompts are
+// answered by hand through the API
+const ManualEndpoint = "manual"
+
+// Endpoint is the ai_endpoint: the http(s) base URL of an OpenAI-compatible
+// API, or unset in manual mode
+type Endpoint struct {
+	URL
+}
+
+// UnmarshalYAML parses an endpoint string
+func (e *Endpoint) UnmarshalYAML(value *yaml.Node) error {
+	return e.Set(value.Value)
+}
+
+// Set parses an endpoint string; ManualEndpoint and the empty string select
+// manual mode
+func (e *Endpoint) Set(s string) error {
+	if s == ManualEndpoint {
+		*e = Endpoint{}
+		return nil
+	}
+	u, err := ParseURL(s)
+	if err != nil {
+		return fmt.Errorf("ai_endpoint must be %q or an http(s) URL: %w", ManualEndpoint, err)
+	}
+	if u.IsSet() && u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("ai_endpoint must be %q or an http(s) URL, got scheme %q", ManualEndpoint, u.Scheme)
+	}
+	e.URL = u
+	return nil
+}
+
+// Manual reports whether the endpoint selects manual mode
+func (e Endpoint) Manual() bool {
+	return !e.IsSet()
+}
+
+// String returns the URL, or ManualEndpoint in manual mode
+func (e Endpoint) String() string {
+	if e.Manual() {
+		return ManualEndpoint
+	}
+	return e.URL.String()
+}
+
+// AIBackend is a named AI backend that monitors can select or fan out to
+type AIBackend struct {
+	Name string `yaml:"name"`
+	// Provider is openai (default, any OpenAI-compatible API), anthropic or ollama
+	Provider string `yaml:"provider"`
+	Endpoint URL    `yaml:"endpoint"`
+	Model    string `yaml:"model"`
+	APIKey   string `yaml:"api_key"`
+	// Timeout bounds a completion; zero uses the provider default
+	Timeout   Duration `yaml:"timeout"`
+	Stream    bool     `yaml:"stream"`
+	MaxTokens int      `yaml:"max_tokens"`
+}
+
+// ModelPricing is a model's price in USD per million tokens
+type ModelPricing struct {
+	Prompt     float64 `yaml:"prompt"`
+	Completion float64 `yaml:"completion"`
+}
+
+// AIBudget limits the AI spend of a monitor; zero fields are unlimited.
+// When a limit is reached the monitor keeps collecting data but pauses AI
+// calls until the budget frees up (daily limits reset at UTC midnight).
+type AIBudget struct {
+	DailyUSD    float64 `yaml:"daily_usd" json:"daily_usd"`
+	DailyTokens int     `yaml:"daily_tokens" json:"daily_tokens"`
+	TotalUSD    float64 `yaml:"total_usd" json:"total_usd"`
+}
+
+// WorkspaceQuota limits what a workspace may run; zero fields are unlimited
+type WorkspaceQuota struct {
+	MaxMonitors int      `yaml:"max_monitors" json:"max_monitors"`
+	MinCycle    Duration `yaml:"min_cycle" json:"min_cycle"`
+	// AIBudget caps the AI spend of all the workspace's monitors together
+	AIBudget AIBudget `yaml:"ai_budget" json:"ai_budget"`
+}
+
+// TracingConfig configures OpenTelemetry tracing
+type TracingConfig struct {
+	// Exporter is "" (tracing off), "otlp" (OTLP over HTTP) or "stdout"
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the OTLP traces URL, e.g. http://localhost:4318/v1/traces;
+	// when empty the OTEL_EXPORTER_OTLP_* environment variables apply
+	Endpoint    URL               `yaml:"endpoint"`
+	Headers     map[string]string `yaml:"headers"`
+	ServiceName string            `yaml:"service_name"`
+	// SampleRatio is the fraction of traces recorded; zero records all
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// Service returns the service name reported with spans
+func (t TracingConfig) Service() string {
+	if t.ServiceName == "" {
+		return "cryptopulse"
+	}
+	return t.ServiceName
+}
+
+// SchedulerConfig configures scheduled one-shot analyses
+type SchedulerConfig struct {
+	File string `yaml:"file"`
+	// CloseDelay is how long runs, and cycles of monitors aligned to candle
+	// closes, wait after a close so the candle is final on the exchange
+	CloseDelay Duration `yaml:"close_delay"`
+}
+
+// Path returns the file scheduled analyses are stored in
+func (s SchedulerConfig) Path() string {
+	if s.File == "" {
+		return DefaultScheduleFile
+	}
+	return s.File
+}
+
+// Delay returns how long runs wait after their scheduled time
+func (s SchedulerConfig) Delay() time.Duration {
+	if s.CloseDelay <= 0 {
+		return DefaultCloseDelay
+	}
+	return time.Duration(s.CloseDelay)
+}
+
+// QueueConfig sizes the worker pools that run market data fetches and AI
+// calls. Capacity bounds the jobs waiting in each pool; more are rejected.
+type QueueConfig struct {
+	FetchWorkers int `yaml:"fetch_workers"`
+	AIWorkers    int `yaml:"ai_workers"`
+	Capacity     int `yaml:"capacity"`
+}
+
+// Fetch returns the number of concurrent market data fetches
+func (q QueueConfig) Fetch() int {
+	if q.FetchWorkers <= 0 {
+		return DefaultFetchWorkers
+	}
+	return q.FetchWorkers
+}
+
+// AI returns the number of concurrent AI analyses
+func (q QueueConfig) AI() int {
+	if q.AIWorkers <= 0 {
+		return DefaultAIWorkers
+	}
+	return q.AIWorkers
+}
+
+// Size returns how many jobs may wait in each pool
+func (q QueueConfig) Size() int {
+	if q.Capacity <= 0 {
+		return DefaultQueueCapacity
+	}
+	return q.Capacity
+}
+
+// ScannerConfig configures the market scanner, which periodically checks
+// every trading pair of the quote asset against the criteria
+type ScannerConfig struct {
+	Enabled    bool     `yaml:"enabled"`
+	Interval   Duration `yaml:"interval"`
+	QuoteAsset string   `yaml:"quote_asset"`
+	// MaxWeight is the Binance request weight used in the current minute,
+	// as reported by the exchange for all of the server's requests, above
+	// which the scanner waits for the next minute
+	MaxWeight int          `yaml:"max_weight"`
+	Criteria  ScanCriteria `yaml:"criteria"`
+}
+
+// ScanCriteria select the pairs a scan reports; zero fields are not checked.
+// Pairs below the volume floor are not evaluated at all.
+type ScanCriteria struct {
+	MinQuoteVolume float64 `yaml:"min_quote_volume" json:"min_quote_volume"`
+	// MinChangePct flags pairs whose 24h price change is at least this far
+	// from zero in either direction
+	MinChangePct  float64 `yaml:"min_change_pct" json:"min_change_pct"`
+	RSIPeriod     int     `yaml:"rsi_period" json:"rsi_period"`
+	RSIOverbought float64 `yaml:"rsi_overbought" json:"rsi_overbought"`
+	RSIOversold   float64 `yaml:"rsi_oversold" json:"rsi_oversold"`
+	// VolumeSpike flags pairs whose 24h volume is at least this multiple
+	// of their average daily volume
+	VolumeSpike float64 `yaml:"volume_spike" json:"volume_spike"`
+	// BreakoutDays flags pairs trading above the high or below the low of
+	// that many closed daily candles
+	BreakoutDays int `yaml:"breakout_days" json:"breakout_days"`
+}
+
+// Every returns the time between scans
+func (s ScannerConfig) Every() time.Duration {
+	if s.Interval <= 0 {
+		return DefaultScanInterval
+	}
+	return time.Duration(s.Interval)
+}
+
+// Quote returns the quote asset of the scanned pairs
+func (s ScannerConfig) Quote() string {
+	if s.QuoteAsset == "" {
+		return DefaultQuoteAsset
+	}
+	return s.QuoteAsset
+}
+
+// Weight returns the request weight per minute the scanner stays below
+func (s ScannerConfig) Weight() int {
+	if s.MaxWeight <= 0 {
+		return DefaultScanMaxWeight
+	}
+	return s.MaxWeight
+}
+
+// Period returns the RSI period
+func (c ScanCriteria) Period() int {
+	if c.RSIPeriod <= 0 {
+		return DefaultRSIPeriod
+	}
+	return c.RSIPeriod
+}
+
+// CorrelationConfig configures the correlation, beta and relative strength
+// of watchlist symbols against each other and a benchmark
+type CorrelationConfig struct {
+	Benchmark string `yaml:"benchmark"`
+	// Window is the number of candle returns the statistics cover
+	Window int `yaml:"window"`
+	// Prompt adds the statistics to basket prompts
+	Prompt bool `yaml:"prompt"`
+}
+
+// Bench returns the symbol betas and relative strength are measured against
+func (c CorrelationConfig) Bench() string {
+	if c.Benchmark == "" {
+		return DefaultBenchmark
+	}
+	return c.Benchmark
+}
+
+// Returns returns the number of candle returns the statistics cover
+func (c CorrelationConfig) Returns() int {
+	if c.Window <= 0 {
+		return DefaultCorrelationWindow
+	}
+	return c.Window
+}
+
+// SpreadConfig configures cross-exchange spread monitors, which compare
+// the order books of the same asset across venues
+type SpreadConfig struct {
+	// Venues are compared by monitors that name none (binance, okx, bybit)
+	Venues []string `yaml:"venues"`
+	// Depth is the number of order book levels fetched per side
+	Depth    int      `yaml:"depth"`
+	Interval Duration `yaml:"interval"`
+}
+
+// VenueNames returns the venues monitors compare by default
+func (s SpreadConfig) VenueNames() []string {
+	if len(s.Venues) == 0 {
+		return DefaultVenues
+	}
+	return s.Venues
+}
+
+// Levels returns the number of order book levels fetched per side
+func (s SpreadConfig) Levels() int {
+	if s.Depth <= 0 {
+		return DefaultSpreadDepth
+	}
+	return s.Depth
+}
+
+// Every returns the default time between order book snapshots
+func (s SpreadConfig) Every() time.Duration {
+	if s.Interval <= 0 {
+		return DefaultSpreadInterval
+	}
+	return time.Duration(s.Interval)
+}
+
+// PaperConfig configures the simulated trading account of each workspace
+type PaperConfig struct {
+	File string `yaml:"file"`
+	// StartingBalance is the quote asset cash a new or reset account holds
+	StartingBalance float64 `yaml:"starting_balance"`
+	// FeeBps is charged on the notional of every fill
+	FeeBps float64 `yaml:"fee_bps"`
+	// SlippageBps moves every fill against the order on top of the book
+	// levels it walks
+	SlippageBps float64 `yaml:"slippage_bps"`
+	// MaxBookAge is how old a monitor's order book may be before a fill
+	// fetches a fresh one
+	MaxBookAge Duration `yaml:"max_book_age"`
+}
+
+// Path returns the file paper accounts are stored in
+func (p PaperConfig) Path() string {
+	if p.File == "" {
+		return DefaultPaperFile
+	}
+	return p.File
+}
+
+// Balance returns the cash of a new account
+func (p PaperConfig) Balance() float64 {
+	if p.StartingBalance <= 0 {
+		return DefaultStartingBalance
+	}
+	return p.StartingBalance
+}
+
+// BookAge returns how old an order book fills may use
+func (p PaperConfig) BookAge() time.Duration {
+	if p.MaxBookAge <= 0 {
+		return DefaultMaxBookAge
+	}
+	return time.Duration(p.MaxBookAge)
+}
+
+// StorageConfig selects where reports and failed analyses are stored.
+// Changing it takes a restart; `migrate-reports` copies the report
+// directory into the configured backend.
+type StorageConfig struct {
+	// Backend is filesystem (report_dir, the default), sqlite or s3
+	Backend    string   `yaml:"backend"`
+	SQLiteFile string   `yaml:"sqlite_file"`
+	S3         S3Config `yaml:"s3"`
+}
+
+// S3Config configures an S3-compatible bucket, such as AWS S3 or MinIO
+type S3Config struct {
+	Endpoint URL    `yaml:"endpoint"`
+	Region   string `yaml:"region"`
+	Bucket   string `yaml:"bucket"`
+	// Prefix is prepended to every object key
+	Prefix    string `yaml:"prefix"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	// PathStyle addresses the bucket in the path instead of the host name,
+	// as MinIO and most self-hosted stores expect
+	PathStyle bool `yaml:"path_style"`
+}
+
+// Kind returns the configured backend
+func (s StorageConfig) Kind() string {
+	if s.Backend == "" {
+		return StorageFilesystem
+	}
+	return s.Backend
+}
+
+// SQLitePath returns the database file of the sqlite backend
+func (s StorageConfig) SQLitePath() string {
+	if s.SQLiteFile == "" {
+		return DefaultSQLiteFile
+	}
+	return s.SQLiteFile
+}
+
+// Validate checks that the selected backend is fully configured
+func (s StorageConfig) Validate() error {
+	switch s.Kind() {
+	case StorageFilesystem, StorageSQLite:
+	case StorageS3:
+		if !s.S3.Endpoint.IsSet() || s.S3.Bucket == "" {
+			return fmt.Errorf("s3.endpoint and s3.bucket are required")
+		}
+		if (s.S3.AccessKey == "") != (s.S3.SecretKey == "") {
+			return fmt.Errorf("s3.access_key and s3.secret_key must be set together")
+		}
+	default:
+		return fmt.Errorf("unknown backend %q (use filesystem, sqlite or s3)", s.Backend)
+	}
+	return nil
+}
+
+// SigningRegion returns the region requests are signed for, us-east-1
+// unless set
+func (s S3Config) SigningRegion() string {
+	if s.Region == "" {
+		return "us-east-1"
+	}
+	return s.Region
+}
+
+// RetentionConfig configures the pruning of stored reports and failed
+// analyses, and what happens to deleted ones
+type RetentionConfig struct {
+	// Enabled applies the policies every Interval; deletions through the
+	// API work either way
+	Enabled  bool     `yaml:"enabled"`
+	Interval Duration `yaml:"interval"`
+	// Archive copies deleted documents into a gzip-compressed tar bundle
+	// per UTC day in ArchiveDir before removing them
+	Archive    bool   `yaml:"archive"`
+	ArchiveDir string `yaml:"archive_dir"`
+	// AuditFile records every deletion as a JSON line
+	AuditFile string `yaml:"audit_file"`
+	// Default applies to documents no rule matches
+	Default RetentionPolicy `yaml:"default"`
+	// Rules are tried in order; the first matching a document's workspace
+	// and symbol decides its policy
+	Rules []RetentionRule `yaml:"rules"`
+}
+
+// RetentionPolicy bounds how many reports are kept; zero fields keep
+// everything
+type RetentionPolicy struct {
+	// KeepDays deletes reports and failed analyses older than this many days
+	KeepDays int `yaml:"keep_days" json:"keep_days"`
+	// KeepLast keeps only the newest reports of each monitor
+	KeepLast int `yaml:"keep_last" json:"keep_last"`
+}
+
+// RetentionRule is the policy of a workspace, a symbol or a symbol in a
+// workspace; empty fields match anything
+type RetentionRule struct {
+	Workspace       string `yaml:"workspace" json:"workspace,omitempty"`
+	Symbol          string `yaml:"symbol" json:"symbol,omitempty"`
+	RetentionPolicy `yaml:",inline"`
+}
+
+// Every returns the time between retention sweeps
+func (r RetentionConfig) Every() time.Duration {
+	if r.Interval <= 0 {
+		return DefaultRetentionInterval
+	}
+	return time.Duration(r.Interval)
+}
+
+// ArchivePath returns the directory of archive bundles
+func (r RetentionConfig) ArchivePath() string {
+	if r.ArchiveDir == "" {
+		return DefaultArchiveDir
+	}
+	return r.ArchiveDir
+}
+
+// AuditPath returns the deletion audit log
+func (r RetentionConfig) AuditPath() string {
+	if r.AuditFile == "" {
+		return DefaultAuditFile
+	}
+	return r.AuditFile
+}
+
+// Policy returns the policy of a document's workspace and symbol
+func (r RetentionConfig) Policy(workspaceID, symbol string) RetentionPolicy {
+	for _, rule := range r.Rules {
+		if (rule.Workspace == "" || rule.Workspace == workspaceID) && (rule.Symbol == "" || strings.EqualFold(rule.Symbol, symbol)) {
+			return rule.RetentionPolicy
+		}
+	}
+	return r.Default
+}
+
+// AuthConfig configures authentication of the HTTP API
+type AuthConfig struct {
+	// Enabled requires a session or API key on every API route
+	Enabled    bool     `yaml:"enabled"`
+	File       string   `yaml:"file"`
+	SessionTTL Duration `yaml:"session_ttl"`
+	// AdminUser and AdminPassword create the first admin account when no
+	// users exist; a random password is generated and logged if empty
+	AdminUser     string `yaml:"admin_user"`
+	AdminPassword string `yaml:"admin_password"`
+}
+
+// Path returns the file users and API keys are stored in
+func (a AuthConfig) Path() string {
+	if a.File == "" {
+		return DefaultAuthFile
+	}
+	return a.File
+}
+
+// SessionDuration returns how long a UI login stays valid
+func (a AuthConfig) SessionDuration() time.Duration {
+	if a.SessionTTL <= 0 {
+		return DefaultSessionTTL
+	}
+	return time.Duration(a.SessionTTL)
+}
+
+// Admin returns the name of the bootstrap admin account
+func (a AuthConfig) Admin() string {
+	if a.AdminUser == "" {
+		return "admin"
+	}
+	return a.AdminUser
+}
+
 // Config holds application configuration
 type Config struct {
-	AIEndpoint  string `yaml:"ai_endpoint"`
-	ExtEndpoint string `yaml:"ext_endpoint"`
-	Port        string `yaml:"port"`
-	ProxyURL    string `yaml:"proxy_url"`
-	WSProxyURL  string `yaml:"ws_proxy_url"` // New field for WebSocket proxy
+	AIEndpoint   Endpoint `yaml:"ai_endpoint"`
+	AIModel      string   `yaml:"ai_model"`
+	AIAPIKey     string   `yaml:"ai_api_key"`
+	AIMaxRepairs *int     `yaml:"ai_max_repairs"`
+	// AIBackends are named backends. DefaultBackend selects the backend used
+	// for single-model analysis instead of ai_endpoint, and AIEnsemble lists
+	// the backends each monitor fans out to unless it picks its own
+	AIBackends        []AIBackend    `yaml:"ai_backends"`
+	DefaultBackend    string         `yaml:"ai_backend"`
+	AIEnsemble        []string       `yaml:"ai_ensemble"`
+	AIEnsembleTimeout Duration       `yaml:"ai_ensemble_timeout"`
+	ExtEndpoint       URL            `yaml:"ext_endpoint"`
+	Port              string         `yaml:"port"`
+	ProxyURL          URL            `yaml:"proxy_url"`
+	WSProxyURL        URL            `yaml:"ws_proxy_url"` // New field for WebSocket proxy
+	TokenBudgets      map[string]int `yaml:"token_budgets"`
+	// AIPricing maps model names to their price for cost estimation
+	AIPricing map[string]ModelPricing `yaml:"ai_pricing"`
+	// AIBudget is the default per-monitor budget
+	AIBudget  AIBudget   `yaml:"ai_budget"`
+	UsageFile string     `yaml:"usage_file"`
+	ReportDir string     `yaml:"report_dir"`
+	StaticDir string     `yaml:"static_dir"`
+	Auth      AuthConfig `yaml:"auth"`
+	// WorkspaceQuota is the quota of workspaces that have none of their own
+	WorkspaceQuota WorkspaceQuota  `yaml:"workspace_quota"`
+	Tracing        TracingConfig   `yaml:"tracing"`
+	Scheduler      SchedulerConfig `yaml:"scheduler"`
+	Queue          QueueConfig     `yaml:"queue"`
+	WatchlistFile  string          `yaml:"watchlist_file"`
+	Scanner        ScannerConfig   `yaml:"scanner"`
+	// ShutdownTimeout bounds graceful shutdown after SIGINT or SIGTERM
+	ShutdownTimeout Duration `yaml:"shutdown_timeout"`
+	// Correlation configures the statistics of watchlist symbols
+	Correlation CorrelationConfig `yaml:"correlation"`
+	// Spreads configures cross-exchange spread monitors
+	Spreads SpreadConfig `yaml:"spreads"`
+	// Paper configures simulated trading; fees and slippage apply from the
+	// next fill after a reload
+	Paper PaperConfig `yaml:"paper"`
+	// Storage selects the report storage backend
+	Storage StorageConfig `yaml:"storage"`
+	// Retention prunes old reports; it is reloaded before every sweep
+	Retention RetentionConfig `yaml:"retention"`
+}
+
+// TokenBudget returns the prompt token budget for the configured AI model,
+// falling back to the "default" entry and then DefaultTokenBudget
+func (c Config) TokenBudget() int {
+	if b, ok := c.PrimaryBackend(); ok {
+		return c.TokenBudgetFor(b.Model)
+	}
+	return c.TokenBudgetFor(c.AIModel)
+}
+
+// TokenBudgetFor returns the prompt token budget for the given model
+func (c Config) TokenBudgetFor(model string) int {
+	if budget, ok := c.TokenBudgets[model]; ok && budget > 0 {
+		return budget
+	}
+	if budget, ok := c.TokenBudgets["default"]; ok && budget > 0 {
+		return budget
+	}
+	return DefaultTokenBudget
+}
+
+// MaxRepairs returns how many times an invalid AI response is re-prompted
+func (c Config) MaxRepairs() int {
+	if c.AIMaxRepairs == nil || *c.AIMaxRepairs < 0 {
+		return DefaultAIMaxRepairs
+	}
+	return *c.AIMaxRepairs
+}
+
+// Backend looks up a named AI backend
+func (c Config) Backend(name string) (AIBackend, bool) {
+	for _, b := range c.AIBackends {
+		if b.Name == name {
+			return b, true
+		}
+	}
+	return AIBackend{}, false
 }
 
-// LoadConfig reads configuration from config.yaml
-func LoadConfig() (Config, error) {
+// PrimaryBackend returns the backend used for single-model analysis: the
+// named ai_backend if set, otherwise an OpenAI-compatible backend built from
+// ai_endpoint. It reports false in manual mode.
+func (c Config) PrimaryBackend() (AIBackend, bool) {
+	if c.DefaultBackend != "" {
+		return c.Backend(c.DefaultBackend)
+	}
+	if c.AIEndpoint.Manual() {
+		return AIBackend{}, false
+	}
+	return AIBackend{
+		Name:     "default",
+		Provider: "openai",
+		Endpoint: c.AIEndpoint.URL,
+		Model:    c.AIModel,
+		APIKey:   c.AIAPIKey,
+	}, true
+}
+
+// EnsembleTimeout returns how long an ensemble waits for its members
+func (c Config) EnsembleTimeout() time.Duration {
+	if c.AIEnsembleTimeout <= 0 {
+		return DefaultEnsembleTimeout
+	}
+	return time.Duration(c.AIEnsembleTimeout)
+}
+
+// ShutdownDeadline returns how long shutdown may take before the process exits
+func (c Config) ShutdownDeadline() time.Duration {
+	if c.ShutdownTimeout <= 0 {
+		return DefaultShutdownTimeout
+	}
+	return time.Duration(c.ShutdownTimeout)
+}
+
+// UsagePath returns the file AI usage records are stored in
+func (c Config) UsagePath() string {
+	if c.UsageFile == "" {
+		return DefaultUsageFile
+	}
+	return c.UsageFile
+}
+
+// WatchlistPath returns the file watchlists are stored in
+func (c Config) WatchlistPath() string {
+	if c.WatchlistFile == "" {
+		return DefaultWatchlistFile
+	}
+	return c.WatchlistFile
+}
+
+// ReportPath returns the directory reports are stored in
+func (c Config) ReportPath() string {
+	if c.ReportDir == "" {
+		return DefaultReportDir
+	}
+	return c.ReportDir
+}
+
+// StaticPath returns the directory the web UI is served from
+func (c Config) StaticPath() string {
+	if c.StaticDir == "" {
+		return DefaultStaticDir
+	}
+	return c.StaticDir
+}
+
+// ResolvePath picks the configuration file: the explicit path if given,
+// then $CRYPTOPULSE_CONFIG, then DefaultPath relative to the working
+// directory, and finally DefaultPath next to the executable
+func ResolvePath(path string) string {
+	if path != "" {
+		return path
+	}
+	if env := os.Getenv(EnvPrefix + "CONFIG"); env != "" {
+		return env
+	}
+	if _, err := os.Stat(DefaultPath); err == nil {
+		return DefaultPath
+	}
+	if exe, err := os.Executable(); err == nil {
+		candidate := filepath.Join(filepath.Dir(exe), DefaultPath)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return DefaultPath
+}
+
+// BaseDir returns the directory relative paths of the configuration file at
+// path are resolved against: the application directory for a file at
+// DefaultPath within it, otherwise the directory of the file
+func BaseDir(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	dir := filepath.Dir(abs)
+	if filepath.Base(abs) == filepath.Base(DefaultPath) && filepath.Base(dir) == filepath.Dir(DefaultPath) {
+		return filepath.Dir(dir)
+	}
+	return dir
+}
+
+// resolvePaths fills in the default files and directories and makes
+// relative ones absolute against base, so they do not depend on the
+// working directory
+func (c *Config) resolvePaths(base string) {
+	resolve := func(p string) string {
+		if filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(base, p)
+	}
+	c.UsageFile = resolve(c.UsagePath())
+	c.ReportDir = resolve(c.ReportPath())
+	c.StaticDir = resolve(c.StaticPath())
+	c.WatchlistFile = resolve(c.WatchlistPath())
+	c.Auth.File = resolve(c.Auth.Path())
+	c.Scheduler.File = resolve(c.Scheduler.Path())
+	c.Paper.File = resolve(c.Paper.Path())
+	c.Storage.SQLiteFile = resolve(c.Storage.SQLitePath())
+	c.Retention.ArchiveDir = resolve(c.Retention.ArchivePath())
+	c.Retention.AuditFile = resolve(c.Retention.AuditPath())
+}
+
+// Load reads the configuration file at path, applies environment variable
+// overrides, resolves relative paths against BaseDir(path) and validates
+// the result
+func Load(path string) (Config, error) {
 	var cfg Config
-	data, err := os.ReadFile("config/config.yaml")
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return cfg, err
+		return cfg, fmt.Errorf("read config %s: %w", path, err)
 	}
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	if err := applyEnv(&cfg, os.LookupEnv); err != nil {
 		return cfg, err
 	}
+	cfg.resolvePaths(BaseDir(path))
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+	}
 	return cfg, nil
 }
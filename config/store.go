@@ -0,0 +1,134 @@
+package config
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Store holds the live configuration and hot-reloads it when the file
+// changes. Only AI settings (endpoint, backends, ensemble, repairs, token
+// budgets, pricing and spend budgets) and workspace quotas are applied at
+// runtime; changes to other settings are logged and take effect on the next
+// restart.
+type Store struct {
+	path        string
+	cfg         Config
+	subscribers []func(Config)
+	logger      zerolog.Logger
+	mu          sync.RWMutex
+}
+
+// NewStore creates a Store for an already loaded configuration
+func NewStore(path string, cfg Config, logger zerolog.Logger) *Store {
+	return &Store{path: path, cfg: cfg, logger: logger}
+}
+
+// Get returns the current configuration
+func (s *Store) Get() Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+// Subscribe registers fn to be called with the new configuration after
+// every successful reload
+func (s *Store) Subscribe(fn func(Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// Watch polls the configuration file every interval and reloads it when its
+// modification time or size changes, until ctx is cancelled
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	modTime, size := fileStamp(s.path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m, sz := fileStamp(s.path)
+			if m.Equal(modTime) && sz == size {
+				continue
+			}
+			modTime, size = m, sz
+			s.Reload()
+		}
+	}
+}
+
+// Reload re-reads the configuration file and applies its hot-reloadable
+// settings. An invalid file is logged and the current configuration is kept.
+func (s *Store) Reload() {
+	next, err := Load(s.path)
+	if err != nil {
+		s.logger.Error().Err(err).Str("path", s.path).Msg("Config reload failed, keeping current config")
+		return
+	}
+
+	s.mu.Lock()
+	current := s.cfg
+	for _, key := range restartRequired(current, next) {
+		s.logger.Warn().Str("key", key).Msg("Config change requires a restart to take effect")
+	}
+	s.cfg = current.withReloadable(next)
+	cfg, subscribers := s.cfg, append([]func(Config){}, s.subscribers...)
+	s.mu.Unlock()
+
+	s.logger.Info().Str("path", s.path).Msg("Config reloaded")
+	for _, fn := range subscribers {
+		fn(cfg)
+	}
+}
+
+// withReloadable returns c with the hot-reloadable settings taken from next
+func (c Config) withReloadable(next Config) Config {
+	c.AIEndpoint = next.AIEndpoint
+	c.AIModel = next.AIModel
+	c.AIAPIKey = next.AIAPIKey
+	c.AIMaxRepairs = next.AIMaxRepairs
+	c.AIBackends = next.AIBackends
+	c.DefaultBackend = next.DefaultBackend
+	c.AIEnsemble = next.AIEnsemble
+	c.AIEnsembleTimeout = next.AIEnsembleTimeout
+	c.TokenBudgets = next.TokenBudgets
+	c.AIPricing = next.AIPricing
+	c.AIBudget = next.AIBudget
+	c.WorkspaceQuota = next.WorkspaceQuota
+	c.Scanner = next.Scanner
+	c.Spreads = next.Spreads
+	c.Paper.StartingBalance = next.Paper.StartingBalance
+	c.Paper.FeeBps = next.Paper.FeeBps
+	c.Paper.SlippageBps = next.Paper.SlippageBps
+	c.Paper.MaxBookAge = next.Paper.MaxBookAge
+	c.Retention = next.Retention
+	return c
+}
+
+// restartRequired lists the yaml keys that changed but cannot be hot-reloaded
+func restartRequired(current, next Config) []string {
+	reloaded := current.withReloadable(next)
+	var keys []string
+	v, n := reflect.ValueOf(reloaded), reflect.ValueOf(next)
+	for i := 0; i < v.NumField(); i++ {
+		if !reflect.DeepEqual(v.Field(i).Interface(), n.Field(i).Interface()) {
+			keys = append(keys, v.Type().Field(i).Tag.Get("yaml"))
+		}
+	}
+	return keys
+}
+
+func fileStamp(path string) (time.Time, int64) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, -1
+	}
+	return info.ModTime(), info.Size()
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Validate checks the configuration and returns every problem found
+func (c Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		add("port: %q is not a valid TCP port", c.Port)
+	}
+	proxies := []struct {
+		name string
+		url  URL
+	}{{"proxy_url", c.ProxyURL}, {"ws_proxy_url", c.WSProxyURL}}
+	for _, p := range proxies {
+		if !p.url.IsSet() {
+			continue
+		}
+		switch p.url.Scheme {
+		case "http", "https", "socks5":
+		default:
+			add("%s: unsupported proxy scheme %q (use http, https or socks5)", p.name, p.url.Scheme)
+		}
+	}
+
+	if c.AIMaxRepairs != nil && *c.AIMaxRepairs < 0 {
+		add("ai_max_repairs: must not be negative")
+	}
+
+	names := make(map[string]bool)
+	for i, b := range c.AIBackends {
+		switch {
+		case b.Name == "":
+			add("ai_backends[%d]: name is required", i)
+		case names[b.Name]:
+			add("ai_backends[%d]: duplicate name %q", i, b.Name)
+		}
+		names[b.Name] = true
+		switch b.Provider {
+		case "", "openai", "anthropic", "ollama":
+		default:
+			add("ai_backends[%d] (%s): unknown provider %q (use openai, anthropic or ollama)", i, b.Name, b.Provider)
+		}
+		if (b.Provider == "" || b.Provider == "openai") && !b.Endpoint.IsSet() {
+			add("ai_backends[%d] (%s): endpoint is required for openai-compatible backends", i, b.Name)
+		}
+		if b.Model == "" {
+			add("ai_backends[%d] (%s): model is required", i, b.Name)
+		}
+		if b.Timeout < 0 || b.MaxTokens < 0 {
+			add("ai_backends[%d] (%s): timeout and max_tokens must not be negative", i, b.Name)
+		}
+	}
+	if c.DefaultBackend != "" && !names[c.DefaultBackend] {
+		add("ai_backend: unknown backend %q", c.DefaultBackend)
+	}
+	for _, name := range c.AIEnsemble {
+		if !names[name] {
+			add("ai_ensemble: unknown backend %q", name)
+		}
+	}
+	if c.AIEnsembleTimeout < 0 {
+		add("ai_ensemble_timeout: must not be negative")
+	}
+
+	for model, budget := range c.TokenBudgets {
+		if budget <= 0 {
+			add("token_budgets.%s: must be positive", model)
+		}
+	}
+	for model, p := range c.AIPricing {
+		if p.Prompt < 0 || p.Completion < 0 {
+			add("ai_pricing.%s: prices must not be negative", model)
+		}
+	}
+	if c.AIBudget.DailyUSD < 0 || c.AIBudget.DailyTokens < 0 || c.AIBudget.TotalUSD < 0 {
+		add("ai_budget: limits must not be negative")
+	}
+	if err := c.WorkspaceQuota.Validate(); err != nil {
+		add("workspace_quota: %v", err)
+	}
+	switch c.Tracing.Exporter {
+	case "", "otlp", "stdout":
+	default:
+		add("tracing.exporter: unknown exporter %q (use otlp or stdout)", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		add("tracing.sample_ratio: must be between 0 and 1")
+	}
+	if c.Scheduler.CloseDelay < 0 {
+		add("scheduler.close_delay: must not be negative")
+	}
+	if c.Queue.FetchWorkers < 0 || c.Queue.AIWorkers < 0 || c.Queue.Capacity < 0 {
+		add("queue: sizes must not be negative")
+	}
+	if c.Correlation.Window < 0 || c.Correlation.Window > MaxCorrelationWindow {
+		add("correlation.window: must be between 0 and %d", MaxCorrelationWindow)
+	}
+	if err := c.Spreads.Validate(); err != nil {
+		add("spreads: %v", err)
+	}
+	if c.Paper.StartingBalance < 0 || c.Paper.FeeBps < 0 || c.Paper.SlippageBps < 0 || c.Paper.MaxBookAge < 0 {
+		add("paper: starting_balance, fee_bps, slippage_bps and max_book_age must not be negative")
+	}
+	if err := c.Storage.Validate(); err != nil {
+		add("storage: %v", err)
+	}
+	if err := c.Scanner.Validate(); err != nil {
+		add("scanner: %v", err)
+	}
+	if err := c.Retention.Validate(); err != nil {
+		add("retention: %v", err)
+	}
+	if c.ShutdownTimeout < 0 {
+		add("shutdown_timeout: must not be negative")
+	}
+	if c.Auth.SessionTTL < 0 {
+		add("auth.session_ttl: must not be negative")
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks the scanner's interval, weight and criteria
+func (s ScannerConfig) Validate() error {
+	c := s.Criteria
+	switch {
+	case s.Interval < 0 || s.MaxWeight < 0:
+		return errors.New("interval and max_weight must not be negative")
+	case c.MinQuoteVolume < 0 || c.MinChangePct < 0 || c.RSIPeriod < 0 || c.VolumeSpike < 0 || c.BreakoutDays < 0:
+		return errors.New("criteria must not be negative")
+	case c.RSIOverbought < 0 || c.RSIOverbought > 100 || c.RSIOversold < 0 || c.RSIOversold > 100:
+		return errors.New("rsi thresholds must be between 0 and 100")
+	case c.RSIOverbought > 0 && c.RSIOversold >= c.RSIOverbought:
+		return errors.New("rsi_oversold must be below rsi_overbought")
+	}
+	return nil
+}
+
+// Validate checks that no retention interval or limit is negative
+func (r RetentionConfig) Validate() error {
+	if r.Interval < 0 {
+		return errors.New("interval must not be negative")
+	}
+	if r.Default.KeepDays < 0 || r.Default.KeepLast < 0 {
+		return errors.New("default: keep_days and keep_last must not be negative")
+	}
+	for i, rule := range r.Rules {
+		if rule.KeepDays < 0 || rule.KeepLast < 0 {
+			return fmt.Errorf("rules[%d]: keep_days and keep_last must not be negative", i)
+		}
+	}
+	return nil
+}
+
+// Validate checks the venues, depth and interval of spread monitors
+func (s SpreadConfig) Validate() error {
+	for _, v := range s.Venues {
+		if !slices.Contains(DefaultVenues, v) {
+			return fmt.Errorf("unknown venue %q (use binance, okx or bybit)", v)
+		}
+	}
+	switch {
+	case s.Depth < 0 || s.Depth > MaxSpreadDepth:
+		return fmt.Errorf("depth must be between 0 and %d", MaxSpreadDepth)
+	case s.Interval < 0:
+		return errors.New("interval must not be negative")
+	case s.Interval > 0 && time.Duration(s.Interval) < MinSpreadInterval:
+		return fmt.Errorf("interval must be at least %s", MinSpreadInterval)
+	}
+	return nil
+}
+
+// Validate checks that no quota limit is negative
+func (q WorkspaceQuota) Validate() error {
+	if q.MaxMonitors < 0 || q.MinCycle < 0 || q.AIBudget.DailyUSD < 0 || q.AIBudget.DailyTokens < 0 || q.AIBudget.TotalUSD < 0 {
+		return errors.New("limits must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/metrics"
+	"github.com/songzhibin97/CryptoPulse/tracing"
+)
+
+// Supported venues
+const (
+	Binance = "binance"
+	OKX     = "okx"
+	Bybit   = "bybit"
+)
+
+// quoteAssets are the quote assets symbols are split on for venues that
+// separate base and quote, longest first so FDUSD is not read as USD
+var quoteAssets = []string{"FDUSD", "USDT", "USDC", "BUSD", "TUSD", "EUR", "TRY", "BTC", "ETH", "BNB", "USD"}
+
+// ErrUnknownVenue is returned for a venue that has no adapter
+var ErrUnknownVenue = errors.New("unknown venue")
+
+// Level is a price level of an order book
+type Level struct {
+	Price float64 `json:"price"`
+	Qty   float64 `json:"qty"`
+}
+
+// Book is an order book snapshot, best levels first
+type Book struct {
+	Venue  string  `json:"venue"`
+	Symbol string  `json:"symbol"`
+	Bids   []Level `json:"bids"`
+	Asks   []Level `json:"asks"`
+	// Time is when the venue produced the snapshot, or when it was received
+	// for venues that do not say, in Unix milliseconds
+	Time int64 `json:"time"`
+}
+
+// Venue fetches order books from one exchange. Symbols are given in
+// Binance's form, e.g. BTCUSDT, and translated by the adapter.
+type Venue interface {
+	Name() string
+	Book(ctx context.Context, symbol string, depth int) (Book, error)
+}
+
+// New creates the adapter of a venue
+func New(name string, cfg config.Config) (Venue, error) {
+	client := newClient(cfg, name)
+	switch name {
+	case Binance:
+		return &binance{client: client}, nil
+	case OKX:
+		return &okx{client: client}, nil
+	case Bybit:
+		return &bybit{client: client}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownVenue, name)
+}
+
+// BestBid returns the highest bid, or zero for an empty side
+func (b Book) BestBid() float64 {
+	if len(b.Bids) == 0 {
+		return 0
+	}
+	return b.Bids[0].Price
+}
+
+// BestAsk returns the lowest ask, or zero for an empty side
+func (b Book) BestAsk() float64 {
+	if len(b.Asks) == 0 {
+		return 0
+	}
+	return b.Asks[0].Price
+}
+
+// Mid returns the midpoint of the best bid and ask, or zero when a side is empty
+func (b Book) Mid() float64 {
+	if len(b.Bids) == 0 || len(b.Asks) == 0 {
+		return 0
+	}
+	return (b.BestBid() + b.BestAsk()) / 2
+}
+
+// Fill walks one side of the book for qty of the base asset and returns the
+// average price and the quantity the fetched levels could fill
+func Fill(levels []Level, qty float64) (avg, filled float64) {
+	var cost float64
+	for _, l := range levels {
+		if filled >= qty {
+			break
+		}
+		take := min(l.Qty, qty-filled)
+		cost += take * l.Price
+		filled += take
+	}
+	if filled == 0 {
+		return 0, 0
+	}
+	return cost / filled, filled
+}
+
+// splitSymbol splits a symbol like BTCUSDT into its base and quote asset
+func splitSymbol(symbol string) (string, string, error) {
+	for _, quote := range quoteAssets {
+		if base, ok := strings.CutSuffix(symbol, quote); ok && base != "" {
+			return base, quote, nil
+		}
+	}
+	return "", "", fmt.Errorf("cannot tell the quote asset of %s", symbol)
+}
+
+// get requests a venue endpoint and returns the body of a 200 response
+func get(ctx context.Context, client *resty.Client, url string) ([]byte, error) {
+	resp, err := client.R().SetContext(ctx).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode(), resp.String())
+	}
+	return resp.Body(), nil
+}
+
+// levels decodes [price, qty, ...] string arrays, which all venues use
+func levels(raw [][]string) []Level {
+	out := make([]Level, 0, len(raw))
+	for _, l := range raw {
+		if len(l) < 2 {
+			continue
+		}
+		price, _ := strconv.ParseFloat(l[0], 64)
+		qty, _ := strconv.ParseFloat(l[1], 64)
+		out = append(out, Level{Price: price, Qty: qty})
+	}
+	return out
+}
+
+// newClient creates the client of a venue, through the configured proxy
+func newClient(cfg config.Config, venue string) *resty.Client {
+	base := &http.Transport{}
+	if cfg.ProxyURL.IsSet() {
+		base.Proxy = http.ProxyURL(cfg.ProxyURL.URL)
+	}
+	return metrics.InstrumentUpstream(resty.New().
+		SetTransport(tracing.Transport(base)).
+		SetTimeout(10*time.Second).
+		SetRetryCount(2).
+		SetRetryWaitTime(time.Second), venue)
+}
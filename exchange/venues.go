@@ -0,0 +1,101 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// binance reads the spot order book of Binance
+type binance struct {
+	client *resty.Client
+}
+
+func (v *binance) Name() string { return Binance }
+
+func (v *binance) Book(ctx context.Context, symbol string, depth int) (Book, error) {
+	url := fmt.Sprintf("https://api1.binance.com/api/v3/depth?symbol=%s&limit=%d", symbol, depth)
+	body, err := get(ctx, v.client, url)
+	if err != nil {
+		return Book{}, err
+	}
+	var raw struct {
+		Bids [][]string `json:"bids"`
+		Asks [][]string `json:"asks"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return Book{}, fmt.Errorf("decode binance depth: %w", err)
+	}
+	return Book{Venue: Binance, Symbol: symbol, Bids: levels(raw.Bids), Asks: levels(raw.Asks), Time: time.Now().UnixMilli()}, nil
+}
+
+// okx reads the spot order book of OKX, whose instruments are named BTC-USDT
+type okx struct {
+	client *resty.Client
+}
+
+func (v *okx) Name() string { return OKX }
+
+func (v *okx) Book(ctx context.Context, symbol string, depth int) (Book, error) {
+	base, quote, err := splitSymbol(symbol)
+	if err != nil {
+		return Book{}, err
+	}
+	url := fmt.Sprintf("https://www.okx.com/api/v5/market/books?instId=%s-%s&sz=%d", base, quote, depth)
+	body, err := get(ctx, v.client, url)
+	if err != nil {
+		return Book{}, err
+	}
+	var raw struct {
+		Code string `json:"code"`
+		Msg  string `json:"msg"`
+		Data []struct {
+			Bids [][]string `json:"bids"`
+			Asks [][]string `json:"asks"`
+			Ts   string     `json:"ts"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return Book{}, fmt.Errorf("decode okx books: %w", err)
+	}
+	if raw.Code != "0" || len(raw.Data) == 0 {
+		return Book{}, fmt.Errorf("okx books: code %s: %s", raw.Code, raw.Msg)
+	}
+	ts, _ := strconv.ParseInt(raw.Data[0].Ts, 10, 64)
+	return Book{Venue: OKX, Symbol: symbol, Bids: levels(raw.Data[0].Bids), Asks: levels(raw.Data[0].Asks), Time: ts}, nil
+}
+
+// bybit reads the spot order book of Bybit
+type bybit struct {
+	client *resty.Client
+}
+
+func (v *bybit) Name() string { return Bybit }
+
+func (v *bybit) Book(ctx context.Context, symbol string, depth int) (Book, error) {
+	url := fmt.Sprintf("https://api.bybit.com/v5/market/orderbook?category=spot&symbol=%s&limit=%d", symbol, depth)
+	body, err := get(ctx, v.client, url)
+	if err != nil {
+		return Book{}, err
+	}
+	var raw struct {
+		RetCode int    `json:"retCode"`
+		RetMsg  string `json:"retMsg"`
+		Result  struct {
+			Bids [][]string `json:"b"`
+			Asks [][]string `json:"a"`
+			Ts   int64      `json:"ts"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return Book{}, fmt.Errorf("decode bybit orderbook: %w", err)
+	}
+	if raw.RetCode != 0 {
+		return Book{}, fmt.Errorf("bybit orderbook: code %d: %s", raw.RetCode, raw.RetMsg)
+	}
+	return Book{Venue: Bybit, Symbol: symbol, Bids: levels(raw.Result.Bids), Asks: levels(raw.Result.Asks), Time: raw.Result.Ts}, nil
+}
@@ -1,35 +1,162 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/songzhibin97/CryptoPulse/api"
+	"github.com/songzhibin97/CryptoPulse/auth"
 	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/metrics"
+	"github.com/songzhibin97/CryptoPulse/paper"
 	"github.com/songzhibin97/CryptoPulse/report"
+	"github.com/songzhibin97/CryptoPulse/scheduler"
+	"github.com/songzhibin97/CryptoPulse/signals"
+	"github.com/songzhibin97/CryptoPulse/tracing"
+	"github.com/songzhibin97/CryptoPulse/usage"
+	"github.com/songzhibin97/CryptoPulse/watchlist"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func main() {
+	configPath := flag.String("config", "", "path to the config file (default $CRYPTOPULSE_CONFIG or "+config.DefaultPath+")")
+	port := flag.String("port", "", "HTTP port, overrides the config file")
+	flag.Parse()
+
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 
-	cfg, err := config.LoadConfig()
+	// ctx ends on SIGINT or SIGTERM and cancels the monitors and config watcher
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *port != "" {
+		// Route the flag through the environment so reloads keep honouring it
+		os.Setenv(config.EnvPrefix+"PORT", *port)
+	}
+	path := config.ResolvePath(*configPath)
+	cfg, err := config.Load(path)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to load config")
 	}
+	log.Info().
+		Str("config", path).
+		Str("proxy_url", cfg.ProxyURL.String()).
+		Str("ws_proxy_url", cfg.WSProxyURL.String()).
+		Msg("Loaded config")
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up tracing")
+	}
+	if cfg.Tracing.Exporter != "" {
+		log.Info().Str("exporter", cfg.Tracing.Exporter).Str("service", cfg.Tracing.Service()).Msg("Tracing enabled")
+	}
+	store := config.NewStore(path, cfg, log.Logger)
+	go store.Watch(ctx, 2*time.Second)
+
+	backend, err := report.Open(cfg, cfg.ReportPath())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to open report storage")
+	}
+	defer backend.Close()
+	if flag.Arg(0) == "migrate-reports" {
+		if err := migrateReports(ctx, backend, cfg.ReportPath(), flag.Args()[1:]); err != nil {
+			log.Fatal().Err(err).Msg("Failed to migrate reports")
+		}
+		return
+	}
+	log.Info().Str("backend", backend.Name()).Msg("Opened report storage")
+	reportMgr := report.NewReportManager(backend)
+	usageTracker, err := usage.NewTracker(cfg.UsagePath())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load usage records")
+	}
+	sched, err := scheduler.New(cfg.Scheduler.Path(), cfg.Scheduler.Delay(), log.Logger)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load schedules")
+	}
+	watchlists, err := watchlist.Open(cfg.WatchlistPath())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load watchlists")
+	}
+	reports, err := reportMgr.Reports()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load reports")
+	}
+	signalFeed := signals.NewFeed(reports)
+	reportMgr.Subscribe(signalFeed.Add)
+	reportMgr.OnDelete(signalFeed.Remove)
+	paperEngine, err := paper.Open(cfg.Paper.Path(), store, log.Logger)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load paper accounts")
+	}
+	var authSvc *auth.Service
+	if cfg.Auth.Enabled {
+		authSvc, err = auth.NewService(cfg.Auth.Path(), cfg.Auth.SessionDuration())
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load users")
+		}
+		password, created, err := authSvc.Bootstrap(cfg.Auth.Admin(), cfg.Auth.AdminPassword)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create admin user")
+		}
+		if created && cfg.Auth.AdminPassword == "" {
+			log.Warn().Str("user", cfg.Auth.Admin()).Str("password", password).Msg("Created admin user with a generated password, change it after logging in")
+		} else if created {
+			log.Info().Str("user", cfg.Auth.Admin()).Msg("Created admin user")
+		}
+	} else {
+		log.Warn().Msg("Authentication is disabled, the API is open to anyone who can reach it")
+	}
 
-	reportMgr := report.NewReportManager("reports")
 	r := gin.Default()
-	r.Static("/static", "./static")
+	r.Use(otelgin.Middleware(cfg.Tracing.Service()), metrics.Middleware())
+	r.Static("/static", cfg.StaticPath())
 	r.GET("/", func(c *gin.Context) {
-		c.File("./static/index.html")
+		c.File(filepath.Join(cfg.StaticPath(), "index.html"))
+	})
+	r.GET("/login", func(c *gin.Context) {
+		c.File(filepath.Join(cfg.StaticPath(), "login.html"))
 	})
 
-	api.SetupRoutes(r, cfg, log.Logger, reportMgr)
+	waitWorkers := api.SetupRoutes(ctx, r, store, log.Logger, reportMgr, usageTracker, authSvc, sched, watchlists, paperEngine, signalFeed)
 
-	if err := r.Run(":" + cfg.Port); err != nil {
+	srv := &http.Server{Addr: ":" + cfg.Port, Handler: r}
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Info().Str("addr", srv.Addr).Msg("Listening")
+		serveErr <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-serveErr:
 		log.Fatal().Err(err).Msg("Failed to start server")
+	case <-ctx.Done():
+	}
+	stop()
+
+	timeout := store.Get().ShutdownDeadline()
+	log.Info().Dur("timeout", timeout).Msg("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// Stop accepting requests and let in-flight ones finish; monitors were
+	// already cancelled with ctx and save what their last cycle produced
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("HTTP server did not shut down cleanly")
+	}
+	if err := waitWorkers(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("Monitors and scheduled analyses did not stop in time")
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("Failed to flush traces")
 	}
+	log.Info().Msg("Shutdown complete")
 }
@@ -0,0 +1,272 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-resty/resty/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "cryptopulse"
+
+var (
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	upstreamRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Latency of requests to upstream market data APIs.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"upstream", "endpoint"})
+
+	upstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_errors_total",
+		Help:      "Failed requests to upstream market data APIs by reason (transport or HTTP status).",
+	}, []string{"upstream", "endpoint", "reason"})
+
+	upstreamWeight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_weight",
+		Help:      "Request weight used in the current window as reported by the upstream.",
+	}, []string{"upstream"})
+
+	// ActiveMonitors is the number of running monitors
+	ActiveMonitors = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_monitors",
+		Help:      "Number of running monitors.",
+	})
+
+	cycleDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "monitor_cycle_duration_seconds",
+		Help:      "Duration of monitor cycles by outcome.",
+		Buckets:   []float64{.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"outcome"})
+
+	skippedCycles = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "monitor_cycles_skipped_total",
+		Help:      "Monitor cycles skipped because the previous cycle was still running.",
+	})
+
+	queueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "Jobs waiting for a worker by pool and priority.",
+	}, []string{"pool", "priority"})
+
+	queueWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "queue_wait_seconds",
+		Help:      "Time jobs waited for a worker by pool.",
+		Buckets:   []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60},
+	}, []string{"pool"})
+
+	queueRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queue_rejected_total",
+		Help:      "Jobs rejected because their pool's queue was full.",
+	}, []string{"pool", "priority"})
+
+	aiCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ai_calls_total",
+		Help:      "AI analyses by backend and outcome (ok, invalid, error).",
+	}, []string{"backend", "outcome"})
+
+	aiDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ai_call_duration_seconds",
+		Help:      "Duration of AI analyses including repair attempts.",
+		Buckets:   []float64{1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"backend"})
+
+	scanDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scan_duration_seconds",
+		Help:      "Duration of market scans by outcome.",
+		Buckets:   []float64{5, 10, 30, 60, 120, 300, 600},
+	}, []string{"outcome"})
+
+	scanThrottled = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scan_throttled_total",
+		Help:      "Times a market scan waited for the upstream request weight to free up.",
+	})
+
+	spreadDivergence = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "spread_divergence_bps",
+		Help:      "Gap between the highest and lowest mid price across venues in basis points.",
+	}, []string{"symbol"})
+
+	spreadAlerts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "spread_alerts_total",
+		Help:      "Cross-exchange divergences that crossed a spread monitor's threshold.",
+	}, []string{"symbol"})
+
+	reports = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reports_total",
+		Help:      "Stored reports by kind (report, consensus, basket, failed).",
+	}, []string{"kind"})
+
+	deletedReports = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reports_deleted_total",
+		Help:      "Deleted reports and failed analyses by reason (api, bulk, keep_days, keep_last).",
+	}, []string{"reason"})
+)
+
+// weightReading is the request weight an upstream reported for a minute
+type weightReading struct {
+	used   float64
+	minute int64
+}
+
+// usedWeights holds the latest weightReading of each upstream
+var usedWeights sync.Map
+
+// UsedWeight returns the request weight the upstream last reported as used
+// in the current minute, or zero when nothing was reported since it began.
+// Binance counts the weight per IP, so it covers all of the server's requests.
+func UsedWeight(upstream string) float64 {
+	v, ok := usedWeights.Load(upstream)
+	if !ok {
+		return 0
+	}
+	r := v.(weightReading)
+	if r.minute != time.Now().Unix()/60 {
+		return 0
+	}
+	return r.used
+}
+
+// Handler serves the Prometheus metrics
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// Middleware records the latency of every request by route template, so
+// path parameters do not create new series
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// InstrumentUpstream records latency, errors and request weight of a resty
+// client's requests to the named upstream
+func InstrumentUpstream(client *resty.Client, upstream string) *resty.Client {
+	return client.
+		OnAfterResponse(func(_ *resty.Client, resp *resty.Response) error {
+			endpoint := resp.Request.RawRequest.URL.Path
+			upstreamRequestDuration.WithLabelValues(upstream, endpoint).Observe(resp.Time().Seconds())
+			if resp.StatusCode() >= http.StatusBadRequest {
+				upstreamErrors.WithLabelValues(upstream, endpoint, strconv.Itoa(resp.StatusCode())).Inc()
+			}
+			// Binance reports the weight used in the current minute
+			if w, err := strconv.ParseFloat(resp.Header().Get("X-Mbx-Used-Weight-1m"), 64); err == nil {
+				upstreamWeight.WithLabelValues(upstream).Set(w)
+				usedWeights.Store(upstream, weightReading{used: w, minute: time.Now().Unix() / 60})
+			}
+			return nil
+		}).
+		OnError(func(req *resty.Request, err error) {
+			endpoint := "unknown"
+			if req.RawRequest != nil {
+				endpoint = req.RawRequest.URL.Path
+			}
+			var respErr *resty.ResponseError
+			if errors.As(err, &respErr) && respErr.Response.RawResponse != nil {
+				// A response arrived, so OnAfterResponse already counted it
+				return
+			}
+			upstreamErrors.WithLabelValues(upstream, endpoint, "transport").Inc()
+		})
+}
+
+// ObserveCycle records the duration of one monitor cycle
+func ObserveCycle(outcome string, d time.Duration) {
+	cycleDuration.WithLabelValues(outcome).Observe(d.Seconds())
+}
+
+// CountSkippedCycle records a monitor cycle skipped because the previous
+// one was still running
+func CountSkippedCycle() {
+	skippedCycles.Inc()
+}
+
+// SetQueueDepth records the number of jobs waiting in a pool
+func SetQueueDepth(pool, priority string, n int) {
+	queueDepth.WithLabelValues(pool, priority).Set(float64(n))
+}
+
+// ObserveQueueWait records how long a job waited for a worker
+func ObserveQueueWait(pool string, d time.Duration) {
+	queueWait.WithLabelValues(pool).Observe(d.Seconds())
+}
+
+// CountQueueRejected records a job rejected by a full pool
+func CountQueueRejected(pool, priority string) {
+	queueRejected.WithLabelValues(pool, priority).Inc()
+}
+
+// ObserveScan records the duration of one market scan
+func ObserveScan(outcome string, d time.Duration) {
+	scanDuration.WithLabelValues(outcome).Observe(d.Seconds())
+}
+
+// CountScanThrottled records a market scan waiting for request weight
+func CountScanThrottled() {
+	scanThrottled.Inc()
+}
+
+// SetSpreadDivergence records the latest cross-venue mid divergence of a symbol
+func SetSpreadDivergence(symbol string, bps float64) {
+	spreadDivergence.WithLabelValues(symbol).Set(bps)
+}
+
+// CountSpreadAlert records a divergence that crossed a monitor's threshold
+func CountSpreadAlert(symbol string) {
+	spreadAlerts.WithLabelValues(symbol).Inc()
+}
+
+// ObserveAICall records the outcome and duration of one AI analysis
+func ObserveAICall(backend, outcome string, d time.Duration) {
+	aiCalls.WithLabelValues(backend, outcome).Inc()
+	aiDuration.WithLabelValues(backend).Observe(d.Seconds())
+}
+
+// CountReport records a stored report of the given kind
+func CountReport(kind string) {
+	reports.WithLabelValues(kind).Inc()
+}
+
+// CountDeletedReports records n documents deleted for reason
+func CountDeletedReports(reason string, n int) {
+	deletedReports.WithLabelValues(reason).Add(float64(n))
+}
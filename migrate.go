@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/songzhibin97/CryptoPulse/report"
+)
+
+// migrateReports copies the reports and failed analyses of a report
+// directory into the configured storage backend. Documents the backend
+// already holds are kept unless -overwrite is given.
+func migrateReports(ctx context.Context, dst report.Backend, defaultDir string, args []string) error {
+	fs := flag.NewFlagSet("migrate-reports", flag.ContinueOnError)
+	from := fs.String("from", defaultDir, "report directory to import")
+	overwrite := fs.Bool("overwrite", false, "replace documents the backend already holds")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	src, err := report.NewFileBackend(*from)
+	if err != nil {
+		return err
+	}
+	if dst.Name() == src.Name() {
+		return errors.New("the configured storage backend is the filesystem, there is nothing to migrate")
+	}
+	for _, collection := range []string{report.CollectionReports, report.CollectionFailed} {
+		ids, err := src.List(ctx, collection)
+		if err != nil {
+			return fmt.Errorf("list %s: %w", collection, err)
+		}
+		copied, skipped := 0, 0
+		for _, id := range ids {
+			if !*overwrite {
+				if _, err := dst.Get(ctx, collection, id); err == nil {
+					skipped++
+					continue
+				} else if !errors.Is(err, report.ErrNotFound) {
+					return fmt.Errorf("read %s/%s: %w", collection, id, err)
+				}
+			}
+			data, err := src.Get(ctx, collection, id)
+			if err != nil {
+				return fmt.Errorf("read %s/%s: %w", collection, id, err)
+			}
+			if err := dst.Put(ctx, collection, id, data); err != nil {
+				return fmt.Errorf("write %s/%s: %w", collection, id, err)
+			}
+			copied++
+		}
+		log.Info().Str("collection", collection).Int("copied", copied).Int("skipped", skipped).Str("backend", dst.Name()).Msg("Migrated documents")
+	}
+	return nil
+}
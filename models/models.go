@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // Kline represents a candlestick data point
 type Kline struct {
 	OpenTime  int64  `json:"open_time"`
@@ -9,6 +11,42 @@ type Kline struct {
 	Close     string `json:"close"`
 	Volume    string `json:"volume"`
 	CloseTime int64  `json:"close_time"`
+	// Closed is false for the live candle that is still forming, like the
+	// stream's "x" flag
+	Closed bool `json:"closed"`
+}
+
+// intervals maps kline intervals to their length
+var intervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"3m":  3 * time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"2h":  2 * time.Hour,
+	"4h":  4 * time.Hour,
+	"6h":  6 * time.Hour,
+	"8h":  8 * time.Hour,
+	"12h": 12 * time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+// IntervalDuration returns the length of a kline interval such as "4h"
+func IntervalDuration(interval string) (time.Duration, bool) {
+	d, ok := intervals[interval]
+	return d, ok
+}
+
+// NextClose returns when the candle of the interval that is open at t
+// closes. Candles are aligned to midnight UTC like the exchange's.
+func NextClose(interval string, t time.Time) (time.Time, bool) {
+	d, ok := intervals[interval]
+	if !ok {
+		return time.Time{}, false
+	}
+	// Truncate aligns to the zero time, which is midnight UTC
+	return t.UTC().Truncate(d).Add(d), true
 }
 
 // OrderBook represents the order book
@@ -17,3 +55,58 @@ type OrderBook struct {
 	Bids         map[string]float64 `json:"bids"`
 	Asks         map[string]float64 `json:"asks"`
 }
+
+// SymbolSnapshot is the state of one symbol of a basket at a monitor cycle
+type SymbolSnapshot struct {
+	Symbol         string  `json:"symbol"`
+	LastPrice      float64 `json:"last_price"`
+	ChangePct24h   float64 `json:"change_pct_24h"`
+	High24h        float64 `json:"high_24h"`
+	Low24h         float64 `json:"low_24h"`
+	QuoteVolume24h float64 `json:"quote_volume_24h"`
+	// Changes is the percent change over the fetched closed candles of
+	// each interval
+	Changes   map[string]float64 `json:"changes,omitempty"`
+	Error     string             `json:"error,omitempty"`
+	Timestamp int64              `json:"timestamp"`
+}
+
+// CorrelationMatrix holds rolling return statistics of a watchlist's
+// symbols for one kline interval
+type CorrelationMatrix struct {
+	Interval  string `json:"interval"`
+	Benchmark string `json:"benchmark"`
+	// Window is the number of returns the statistics cover; it is smaller
+	// than requested when fewer candles overlap
+	Window int `json:"window"`
+	// Symbols orders the rows and columns of Matrix and includes the
+	// benchmark; symbols without candles are listed in Missing
+	Symbols []string    `json:"symbols"`
+	Matrix  [][]float64 `json:"matrix"`
+	Missing []string    `json:"missing,omitempty"`
+	// Heatmap lists every cell of Matrix for charting
+	Heatmap []HeatmapCell `json:"heatmap"`
+	// Ranking orders the watchlist's symbols by relative strength
+	Ranking   []RelativeStrength `json:"ranking"`
+	Timestamp int64              `json:"timestamp"`
+}
+
+// HeatmapCell is one cell of a correlation heatmap
+type HeatmapCell struct {
+	X     string  `json:"x"`
+	Y     string  `json:"y"`
+	Value float64 `json:"value"`
+}
+
+// RelativeStrength is a symbol's performance over a correlation window
+type RelativeStrength struct {
+	Symbol    string  `json:"symbol"`
+	Rank      int     `json:"rank"`
+	ReturnPct float64 `json:"return_pct"`
+	// ExcessPct is the return over the benchmark's
+	ExcessPct   float64 `json:"excess_pct"`
+	Correlation float64 `json:"correlation"`
+	Beta        float64 `json:"beta"`
+	// AvgCorrelation is the mean correlation with the other symbols
+	AvgCorrelation float64 `json:"avg_correlation"`
+}
@@ -0,0 +1,225 @@
+package models
+
+// Report is the structured analysis report an AI backend must return
+type Report struct {
+	ReportID          string            `json:"report_id"`
+	Symbol            string            `json:"symbol"`
+	AnalysisType      string            `json:"analysis_type"`
+	Timeframe         []string          `json:"timeframe"`
+	Timestamp         int64             `json:"timestamp"`
+	CapitalFlow       CapitalFlow       `json:"capital_flow"`
+	TechnicalAnalysis TechnicalAnalysis `json:"technical_analysis"`
+	OrderBook         OrderBookAnalysis `json:"order_book"`
+	Sentiment         Sentiment         `json:"sentiment"`
+	RiskAlerts        []RiskAlert       `json:"risk_alerts"`
+	Ensemble          *EnsembleInfo     `json:"ensemble,omitempty"`
+	Usage             *Usage            `json:"usage,omitempty"`
+	// OwnerID is the user whose monitor or submission produced the report
+	OwnerID     string `json:"owner_id,omitempty"`
+	WorkspaceID string `json:"workspace_id,omitempty"`
+	// MonitorID is the monitor that produced the report, empty for manual
+	// submissions and reports from before monitors were recorded
+	MonitorID string `json:"monitor_id,omitempty"`
+	// Price is the last traded price when the report was stored
+	Price float64 `json:"price,omitempty"`
+	// Klines holds the most recent candles of each interval when the report
+	// was stored
+	Klines map[string][]Kline `json:"klines,omitempty"`
+	// Changes compares the report with the previous one of the symbol in
+	// its workspace, if any
+	Changes *ReportDiff `json:"changes,omitempty"`
+	// EnsembleMemberOf is the analysis ID of the consensus a member's
+	// report was made for. Member reports are stored for comparison only:
+	// they are not published, indexed or diffed.
+	EnsembleMemberOf string `json:"ensemble_member_of,omitempty"`
+	// Manual is set on reports submitted by hand rather than returned by
+	// an AI backend
+	Manual bool `json:"manual,omitempty"`
+}
+
+// ReportDiff lists what changed from one report of a symbol to a later one
+type ReportDiff struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	FromTime int64  `json:"from_time"`
+	ToTime   int64  `json:"to_time"`
+	// PriceChangePct is 0 when either report did not record its price
+	PriceChangePct float64     `json:"price_change_pct"`
+	AddedLevels    []LevelRef  `json:"added_levels"`
+	RemovedLevels  []LevelRef  `json:"removed_levels"`
+	TrendFlip      *Change     `json:"trend_flip,omitempty"`
+	RSIRegime      *Change     `json:"rsi_regime,omitempty"`
+	NewAlerts      []RiskAlert `json:"new_alerts"`
+	ClearedAlerts  []RiskAlert `json:"cleared_alerts"`
+	// Summary describes every change in a sentence, most important first
+	Summary []string `json:"summary"`
+}
+
+// LevelRef is a support or resistance level of a report
+type LevelRef struct {
+	// Source is "technical" or "order_book"
+	Source string `json:"source"`
+	Type   string `json:"type"`
+	Price  string `json:"price"`
+}
+
+// Change is a value that moved from one state to another
+type Change struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Usage records the AI token usage, latency and estimated cost behind a report
+type Usage struct {
+	Backend          string  `json:"backend"`
+	Provider         string  `json:"provider,omitempty"`
+	Model            string  `json:"model,omitempty"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Attempts         int     `json:"attempts"`
+	LatencyMs        int64   `json:"latency_ms"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// Add accumulates another usage record into u
+func (u *Usage) Add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.Attempts += other.Attempts
+	u.LatencyMs += other.LatencyMs
+	u.CostUSD += other.CostUSD
+}
+
+// EnsembleInfo records how a consensus report was built from several models
+type EnsembleInfo struct {
+	Members    []EnsembleMember `json:"members"`
+	TrendVotes map[string]int   `json:"trend_votes"`
+	// TrendAgreement is the share of successful members that voted for the
+	// consensus trend direction
+	TrendAgreement float64 `json:"trend_agreement"`
+}
+
+// EnsembleMember is one backend's contribution to a consensus report
+type EnsembleMember struct {
+	Backend        string `json:"backend"`
+	Model          string `json:"model,omitempty"`
+	ReportID       string `json:"report_id,omitempty"`
+	TrendDirection string `json:"trend_direction,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// CapitalFlow describes aggressive buying/selling and large trades
+type CapitalFlow struct {
+	BuySellRatio float64      `json:"buy_sell_ratio"`
+	LargeTrades  []LargeTrade `json:"large_trades"`
+	NetFlow      float64      `json:"net_flow"`
+}
+
+// LargeTrade is a notable trade and its market impact
+type LargeTrade struct {
+	Price  string `json:"price"`
+	Volume string `json:"volume"`
+	Impact string `json:"impact"`
+}
+
+// TechnicalAnalysis holds indicator readings and key levels
+type TechnicalAnalysis struct {
+	MA                map[string]*float64 `json:"ma"`
+	RSI               float64             `json:"rsi"`
+	MACD              MACD                `json:"macd"`
+	Bollinger         Bollinger           `json:"bollinger"`
+	SupportResistance []Level             `json:"support_resistance"`
+	TrendSignals      []string            `json:"trend_signals"`
+	TrendDirection    string              `json:"trend_direction"`
+}
+
+// MACD holds MACD indicator values
+type MACD struct {
+	MACD      float64 `json:"macd"`
+	Signal    float64 `json:"signal"`
+	Histogram float64 `json:"histogram"`
+}
+
+// Bollinger holds Bollinger band values
+type Bollinger struct {
+	Upper  float64 `json:"upper"`
+	Middle float64 `json:"middle"`
+	Lower  float64 `json:"lower"`
+}
+
+// Level is a technical support or resistance level
+type Level struct {
+	Price    string  `json:"price"`
+	Type     string  `json:"type"`
+	Strength float64 `json:"strength"`
+}
+
+// OrderBookAnalysis holds order book derived levels
+type OrderBookAnalysis struct {
+	BuySellDepthRatio float64      `json:"buy_sell_depth_ratio"`
+	SupportResistance []DepthLevel `json:"support_resistance"`
+	FakeWalls         []DepthLevel `json:"fake_walls"`
+}
+
+// DepthLevel is an order book level with its resting depth
+type DepthLevel struct {
+	Price string  `json:"price"`
+	Type  string  `json:"type"`
+	Depth float64 `json:"depth"`
+}
+
+// Sentiment holds volume and volatility readings
+type Sentiment struct {
+	VolumeDistribution []VolumePoint `json:"volume_distribution"`
+	Volatility         Volatility    `json:"volatility"`
+	FearGreedIndex     float64       `json:"fear_greed_index"`
+}
+
+// VolumePoint is the traded volume at a point in time
+type VolumePoint struct {
+	Timestamp int64   `json:"timestamp"`
+	Volume    float64 `json:"volume"`
+}
+
+// Volatility holds historical volatility and ATR
+type Volatility struct {
+	HV  float64 `json:"hv"`
+	ATR float64 `json:"atr"`
+}
+
+// RiskAlert is a risk warning raised by the analysis
+type RiskAlert struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Timestamp   int64  `json:"timestamp"`
+}
+
+// BasketReport is the AI analysis of a watchlist as a whole
+type BasketReport struct {
+	ReportID string `json:"report_id"`
+	// Kind is always "basket"; it tells basket reports from symbol reports
+	Kind           string       `json:"kind"`
+	Watchlist      string       `json:"watchlist"`
+	Symbols        []string     `json:"symbols"`
+	Timeframe      []string     `json:"timeframe"`
+	Timestamp      int64        `json:"timestamp"`
+	TrendDirection string       `json:"trend_direction"`
+	Summary        string       `json:"summary"`
+	Leaders        []string     `json:"leaders"`
+	Laggards       []string     `json:"laggards"`
+	SymbolViews    []SymbolView `json:"symbol_views"`
+	RiskAlerts     []RiskAlert  `json:"risk_alerts"`
+	// Snapshots is the market data the analysis was based on
+	Snapshots   []SymbolSnapshot `json:"snapshots"`
+	Usage       *Usage           `json:"usage,omitempty"`
+	OwnerID     string           `json:"owner_id,omitempty"`
+	WorkspaceID string           `json:"workspace_id,omitempty"`
+	MonitorID   string           `json:"monitor_id,omitempty"`
+}
+
+// SymbolView is the AI's short take on one symbol of a basket
+type SymbolView struct {
+	Symbol         string `json:"symbol"`
+	TrendDirection string `json:"trend_direction"`
+	Comment        string `json:"comment"`
+}
@@ -0,0 +1,342 @@
+package paper
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/songzhibin97/CryptoPulse/exchange"
+)
+
+// Order sides
+const (
+	Buy  = "buy"
+	Sell = "sell"
+)
+
+// Order sources
+const (
+	SourceManual = "manual"
+	SourceReport = "report"
+)
+
+// Bounds of the history kept per account; older entries are dropped
+const (
+	maxFills        = 1000
+	maxEquityPoints = 2000
+)
+
+// dust is the position size below which a position counts as closed
+const dust = 1e-12
+
+var (
+	// ErrInvalidOrder is returned for malformed orders
+	ErrInvalidOrder = errors.New("invalid order")
+	// ErrInsufficientFunds is returned for buys that cost more than the cash
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrInsufficientPosition is returned for sells beyond the position of
+	// an account that may not short
+	ErrInsufficientPosition = errors.New("insufficient position")
+	// ErrNoLiquidity is returned when the book has no levels on the side
+	ErrNoLiquidity = errors.New("no liquidity on the order's side")
+)
+
+// Order is a market order. Qty is the base asset amount; QuoteQty instead
+// spends or raises that much of the quote asset.
+type Order struct {
+	Symbol   string  `json:"symbol"`
+	Side     string  `json:"side"`
+	Qty      float64 `json:"qty"`
+	QuoteQty float64 `json:"quote_qty"`
+}
+
+// Validate normalizes the symbol and checks the side and amounts
+func (o *Order) Validate() error {
+	o.Symbol = strings.ToUpper(strings.TrimSpace(o.Symbol))
+	o.Side = strings.ToLower(o.Side)
+	switch {
+	case o.Symbol == "":
+		return fmt.Errorf("%w: symbol is required", ErrInvalidOrder)
+	case o.Side != Buy && o.Side != Sell:
+		return fmt.Errorf("%w: side must be buy or sell", ErrInvalidOrder)
+	case o.Qty < 0 || o.QuoteQty < 0 || (o.Qty > 0) == (o.QuoteQty > 0):
+		return fmt.Errorf("%w: exactly one of qty and quote_qty must be positive", ErrInvalidOrder)
+	}
+	return nil
+}
+
+// Fill is an executed simulated order
+type Fill struct {
+	ID     string  `json:"id"`
+	Time   int64   `json:"time"`
+	Symbol string  `json:"symbol"`
+	Side   string  `json:"side"`
+	Qty    float64 `json:"qty"`
+	// Price includes slippage; BookPrice is the average of the levels walked
+	Price     float64 `json:"price"`
+	BookPrice float64 `json:"book_price"`
+	Notional  float64 `json:"notional"`
+	Fee       float64 `json:"fee"`
+	// RealizedPnL is the profit of the part that reduced a position, before fees
+	RealizedPnL float64 `json:"realized_pnl"`
+	// Partial is true when the fetched levels could not fill the whole order
+	Partial  bool   `json:"partial"`
+	BookTime int64  `json:"book_time"`
+	Source   string `json:"source"`
+	ReportID string `json:"report_id,omitempty"`
+	UserID   string `json:"user_id,omitempty"`
+}
+
+// Position is the holding of one symbol
+type Position struct {
+	Symbol string `json:"symbol"`
+	// Qty is the base asset held; negative for a short
+	Qty       float64 `json:"qty"`
+	AvgPrice  float64 `json:"avg_price"`
+	MarkPrice float64 `json:"mark_price"`
+	MarkTime  int64   `json:"mark_time"`
+	// RealizedPnL accumulates over the position's fills, before fees
+	RealizedPnL   float64 `json:"realized_pnl"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+}
+
+// EquityPoint is the account's value at a fill or mark
+type EquityPoint struct {
+	Time   int64   `json:"time"`
+	Equity float64 `json:"equity"`
+	Cash   float64 `json:"cash"`
+}
+
+// AutoTrade decides how an account acts on the trend of new reports
+type AutoTrade struct {
+	Enabled bool `json:"enabled"`
+	// Notional is the quote amount of each position opened on a signal
+	Notional float64 `json:"notional"`
+	// MinAgreement skips consensus reports whose models agree less
+	MinAgreement float64 `json:"min_agreement"`
+	// Symbols limits auto-trading to these symbols; empty trades all
+	Symbols []string `json:"symbols"`
+	// CloseOnNeutral closes the position when a report turns neutral
+	CloseOnNeutral bool `json:"close_on_neutral"`
+}
+
+// Settings are an account's trading rules
+type Settings struct {
+	// AllowShort lets sells go beyond the position
+	AllowShort bool      `json:"allow_short"`
+	AutoTrade  AutoTrade `json:"auto_trade"`
+}
+
+// Validate checks the auto-trading amounts
+func (s *Settings) Validate() error {
+	a := &s.AutoTrade
+	switch {
+	case a.Notional < 0:
+		return errors.New("auto_trade.notional must not be negative")
+	case a.Enabled && a.Notional == 0:
+		return errors.New("auto_trade.notional is required when auto-trading")
+	case a.MinAgreement < 0 || a.MinAgreement > 1:
+		return errors.New("auto_trade.min_agreement must be between 0 and 1")
+	}
+	for i, symbol := range a.Symbols {
+		a.Symbols[i] = strings.ToUpper(strings.TrimSpace(symbol))
+	}
+	return nil
+}
+
+// Account is the simulated trading account of a workspace
+type Account struct {
+	WorkspaceID     string               `json:"workspace_id"`
+	StartingBalance float64              `json:"starting_balance"`
+	Cash            float64              `json:"cash"`
+	Fees            float64              `json:"fees"`
+	Positions       map[string]*Position `json:"positions"`
+	Fills           []Fill               `json:"fills"`
+	Equity          []EquityPoint        `json:"equity"`
+	Settings        Settings             `json:"settings"`
+	CreatedAt       int64                `json:"created_at"`
+}
+
+// Summary is an account's state without its history
+type Summary struct {
+	WorkspaceID     string     `json:"workspace_id"`
+	StartingBalance float64    `json:"starting_balance"`
+	Cash            float64    `json:"cash"`
+	Equity          float64    `json:"equity"`
+	PnL             float64    `json:"pnl"`
+	ReturnPct       float64    `json:"return_pct"`
+	Fees            float64    `json:"fees"`
+	Positions       []Position `json:"positions"`
+	Settings        Settings   `json:"settings"`
+	CreatedAt       int64      `json:"created_at"`
+}
+
+func newAccount(workspaceID string, balance float64, now int64) *Account {
+	return &Account{
+		WorkspaceID:     workspaceID,
+		StartingBalance: balance,
+		Cash:            balance,
+		Positions:       make(map[string]*Position),
+		Fills:           []Fill{},
+		Equity:          []EquityPoint{{Time: now, Equity: balance, Cash: balance}},
+		CreatedAt:       now,
+	}
+}
+
+// value returns cash plus the marked value of all positions
+func (a *Account) value() float64 {
+	equity := a.Cash
+	for _, p := range a.Positions {
+		equity += p.Qty * p.MarkPrice
+	}
+	return equity
+}
+
+// summary computes the account's equity and unrealized profits
+func (a *Account) summary() Summary {
+	s := Summary{
+		WorkspaceID:     a.WorkspaceID,
+		StartingBalance: a.StartingBalance,
+		Cash:            round(a.Cash),
+		Equity:          round(a.value()),
+		Fees:            round(a.Fees),
+		Positions:       make([]Position, 0, len(a.Positions)),
+		Settings:        a.Settings,
+		CreatedAt:       a.CreatedAt,
+	}
+	s.PnL = round(s.Equity - a.StartingBalance)
+	if a.StartingBalance > 0 {
+		s.ReturnPct = math.Round(s.PnL/a.StartingBalance*1e4) / 100
+	}
+	for _, p := range a.Positions {
+		pos := *p
+		pos.UnrealizedPnL = round(p.Qty * (p.MarkPrice - p.AvgPrice))
+		s.Positions = append(s.Positions, pos)
+	}
+	slices.SortFunc(s.Positions, func(x, y Position) int { return strings.Compare(x.Symbol, y.Symbol) })
+	return s
+}
+
+// held returns the signed quantity of a symbol the account holds
+func (a *Account) held(symbol string) float64 {
+	if p, ok := a.Positions[symbol]; ok {
+		return p.Qty
+	}
+	return 0
+}
+
+// mark values a position at the book's mid and records an equity point
+func (a *Account) mark(symbol string, mid float64, now int64) {
+	if p, ok := a.Positions[symbol]; ok && mid > 0 {
+		p.MarkPrice, p.MarkTime = mid, now
+	}
+	a.record(now)
+}
+
+// record appends the current equity to the curve
+func (a *Account) record(now int64) {
+	a.Equity = append(a.Equity, EquityPoint{Time: now, Equity: round(a.value()), Cash: round(a.Cash)})
+	if len(a.Equity) > maxEquityPoints {
+		a.Equity = a.Equity[len(a.Equity)-maxEquityPoints:]
+	}
+}
+
+// simulate walks the book for an order and applies slippage against it.
+// It returns the fill price, the book's average price and the quantity.
+func simulate(book exchange.Book, o Order, slippageBps float64) (price, bookPrice, qty float64, partial bool, err error) {
+	levels, sign := book.Asks, 1.0
+	if o.Side == Sell {
+		levels, sign = book.Bids, -1.0
+	}
+	if len(levels) == 0 {
+		return 0, 0, 0, false, ErrNoLiquidity
+	}
+	if o.Qty > 0 {
+		bookPrice, qty = exchange.Fill(levels, o.Qty)
+		partial = qty < o.Qty
+	} else {
+		bookPrice, qty = fillQuote(levels, o.QuoteQty)
+		partial = bookPrice*qty < o.QuoteQty*(1-1e-9)
+	}
+	if qty == 0 {
+		return 0, 0, 0, false, ErrNoLiquidity
+	}
+	price = bookPrice * (1 + sign*slippageBps/1e4)
+	return price, bookPrice, qty, partial, nil
+}
+
+// fillQuote walks one side of the book until notional of the quote asset
+// is spent and returns the average price and base quantity
+func fillQuote(levels []exchange.Level, notional float64) (avg, qty float64) {
+	var spent float64
+	for _, l := range levels {
+		if spent >= notional || l.Price <= 0 {
+			break
+		}
+		take := min(l.Qty, (notional-spent)/l.Price)
+		spent += take * l.Price
+		qty += take
+	}
+	if qty == 0 {
+		return 0, 0
+	}
+	return spent / qty, qty
+}
+
+// apply books a fill into the account after checking its cash and
+// position limits; f has its side, quantity and price set
+func (a *Account) apply(f *Fill, feeBps float64) error {
+	f.Notional = f.Qty * f.Price
+	f.Fee = f.Notional * feeBps / 1e4
+	p := a.Positions[f.Symbol]
+	held := 0.0
+	if p != nil {
+		held = p.Qty
+	}
+	signed := f.Qty
+	if f.Side == Sell {
+		signed = -f.Qty
+		if !a.Settings.AllowShort && f.Qty > held+dust {
+			return fmt.Errorf("%w: selling %g %s with %g held", ErrInsufficientPosition, f.Qty, f.Symbol, held)
+		}
+	} else if f.Notional+f.Fee > a.Cash+dust {
+		return fmt.Errorf("%w: buying costs %.2f with %.2f cash", ErrInsufficientFunds, f.Notional+f.Fee, a.Cash)
+	}
+
+	if p == nil {
+		p = &Position{Symbol: f.Symbol}
+		a.Positions[f.Symbol] = p
+	}
+	switch {
+	case p.Qty == 0 || (p.Qty > 0) == (signed > 0):
+		p.AvgPrice = (math.Abs(p.Qty)*p.AvgPrice + f.Qty*f.Price) / (math.Abs(p.Qty) + f.Qty)
+	default:
+		closed := min(f.Qty, math.Abs(p.Qty))
+		f.RealizedPnL = closed * (f.Price - p.AvgPrice)
+		if p.Qty < 0 {
+			f.RealizedPnL = -f.RealizedPnL
+		}
+		p.RealizedPnL += f.RealizedPnL
+		if f.Qty > closed {
+			// The fill flipped the position; the rest opened at the fill price
+			p.AvgPrice = f.Price
+		}
+	}
+	p.Qty += signed
+	if math.Abs(p.Qty) < dust {
+		delete(a.Positions, f.Symbol)
+	}
+	a.Cash -= signed*f.Price + f.Fee
+	a.Fees += f.Fee
+	a.Fills = append(a.Fills, *f)
+	if len(a.Fills) > maxFills {
+		a.Fills = a.Fills[len(a.Fills)-maxFills:]
+	}
+	return nil
+}
+
+func round(f float64) float64 {
+	return math.Round(f*1e8) / 1e8
+}
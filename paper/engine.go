@@ -0,0 +1,343 @@
+package paper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/exchange"
+	"github.com/songzhibin97/CryptoPulse/models"
+)
+
+// BookSource returns a current order book of a symbol
+type BookSource func(ctx context.Context, symbol string) (exchange.Book, error)
+
+// Engine runs the simulated trading accounts of all workspaces and
+// persists them to a JSON file
+type Engine struct {
+	ctx      context.Context
+	path     string
+	store    *config.Store
+	books    BookSource
+	logger   zerolog.Logger
+	accounts map[string]*Account
+	mu       sync.Mutex
+}
+
+// Open loads the accounts stored at path, which need not exist yet. The
+// engine fills orders once Connect gave it an order book source.
+func Open(path string, store *config.Store, logger zerolog.Logger) (*Engine, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	e := &Engine{ctx: context.Background(), path: path, store: store, logger: logger, accounts: make(map[string]*Account)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return e, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var accounts []*Account
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return nil, fmt.Errorf("parse paper account file %s: %w", path, err)
+	}
+	for _, a := range accounts {
+		if a.Positions == nil {
+			a.Positions = make(map[string]*Position)
+		}
+		e.accounts[a.WorkspaceID] = a
+	}
+	return e, nil
+}
+
+// Connect sets where fills take order books from; trades on report
+// signals run until ctx ends. It must be called before the engine is used.
+func (e *Engine) Connect(ctx context.Context, books BookSource) {
+	e.ctx, e.books = ctx, books
+}
+
+// Account returns the summary of a workspace's account, which starts with
+// the configured balance
+func (e *Engine) Account(workspaceID string) Summary {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.accountLocked(workspaceID).summary()
+}
+
+// Fills returns the most recent fills of a workspace, newest first
+func (e *Engine) Fills(workspaceID string, limit int) []Fill {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	fills := e.accountLocked(workspaceID).Fills
+	out := make([]Fill, 0, min(limit, len(fills)))
+	for i := len(fills) - 1; i >= 0 && len(out) < limit; i-- {
+		out = append(out, fills[i])
+	}
+	return out
+}
+
+// EquityCurve returns a workspace's equity over time, oldest first
+func (e *Engine) EquityCurve(workspaceID string) []EquityPoint {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Clone(e.accountLocked(workspaceID).Equity)
+}
+
+// Configure replaces an account's settings
+func (e *Engine) Configure(workspaceID string, settings Settings) (Summary, error) {
+	if err := settings.Validate(); err != nil {
+		return Summary{}, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	a := e.accountLocked(workspaceID)
+	prev := a.Settings
+	a.Settings = settings
+	if err := e.save(); err != nil {
+		a.Settings = prev
+		return Summary{}, err
+	}
+	return a.summary(), nil
+}
+
+// Reset closes out an account: positions and history are dropped and the
+// cash is set to balance, or the configured starting balance for zero.
+// Settings are kept.
+func (e *Engine) Reset(workspaceID string, balance float64) (Summary, error) {
+	if balance < 0 {
+		return Summary{}, errors.New("balance must not be negative")
+	}
+	if balance == 0 {
+		balance = e.store.Get().Paper.Balance()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	prev := e.accountLocked(workspaceID)
+	a := newAccount(workspaceID, balance, time.Now().UnixMilli())
+	a.Settings = prev.Settings
+	e.accounts[workspaceID] = a
+	if err := e.save(); err != nil {
+		e.accounts[workspaceID] = prev
+		return Summary{}, err
+	}
+	return a.summary(), nil
+}
+
+// Refresh marks every position of an account at a current book
+func (e *Engine) Refresh(ctx context.Context, workspaceID string) (Summary, error) {
+	e.mu.Lock()
+	symbols := make([]string, 0)
+	for symbol := range e.accountLocked(workspaceID).Positions {
+		symbols = append(symbols, symbol)
+	}
+	e.mu.Unlock()
+	sort.Strings(symbols)
+	var errs []error
+	mids := make(map[string]float64, len(symbols))
+	for _, symbol := range symbols {
+		book, err := e.books(ctx, symbol)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", symbol, err))
+			continue
+		}
+		mids[symbol] = book.Mid()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	a := e.accountLocked(workspaceID)
+	now := time.Now().UnixMilli()
+	for symbol, mid := range mids {
+		if p, ok := a.Positions[symbol]; ok && mid > 0 {
+			p.MarkPrice, p.MarkTime = mid, now
+		}
+	}
+	if len(mids) > 0 {
+		a.record(now)
+		if err := e.save(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return a.summary(), errors.Join(errs...)
+}
+
+// Place fills a market order of a workspace against a current book
+func (e *Engine) Place(ctx context.Context, workspaceID, userID string, o Order) (Fill, error) {
+	if err := o.Validate(); err != nil {
+		return Fill{}, err
+	}
+	book, err := e.books(ctx, o.Symbol)
+	if err != nil {
+		return Fill{}, err
+	}
+	return e.fill(workspaceID, o, book, Fill{Source: SourceManual, UserID: userID})
+}
+
+// fill simulates an order against book and books it, with f's source
+func (e *Engine) fill(workspaceID string, o Order, book exchange.Book, f Fill) (Fill, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.fillLocked(workspaceID, o, book, f)
+}
+
+// fillLocked is fill for callers holding e.mu
+func (e *Engine) fillLocked(workspaceID string, o Order, book exchange.Book, f Fill) (Fill, error) {
+	cfg := e.store.Get().Paper
+	price, bookPrice, qty, partial, err := simulate(book, o, cfg.SlippageBps)
+	if err != nil {
+		return Fill{}, err
+	}
+	now := time.Now().UnixMilli()
+	f.ID, f.Time, f.Symbol, f.Side = uuid.New().String(), now, o.Symbol, o.Side
+	f.Qty, f.Price, f.BookPrice, f.Partial, f.BookTime = qty, price, bookPrice, partial, book.Time
+
+	a := e.accountLocked(workspaceID)
+	if err := a.apply(&f, cfg.FeeBps); err != nil {
+		return Fill{}, err
+	}
+	a.mark(o.Symbol, book.Mid(), now)
+	if err := e.save(); err != nil {
+		// The fill stays in memory and is written with the next change
+		e.logger.Error().Err(err).Str("workspace_id", workspaceID).Msg("Failed to save paper accounts")
+	}
+	e.logger.Info().
+		Str("workspace_id", workspaceID).
+		Str("symbol", f.Symbol).
+		Str("side", f.Side).
+		Float64("qty", f.Qty).
+		Float64("price", f.Price).
+		Float64("fee", f.Fee).
+		Str("source", f.Source).
+		Msg("Paper order filled")
+	return f, nil
+}
+
+// OnReport acts on a stored report in the background: accounts holding
+// the symbol are marked to a current book, and accounts auto-trading it
+// follow the report's trend
+func (e *Engine) OnReport(rep models.Report) {
+	go e.handleReport(rep)
+}
+
+// handleReport fetches a book for a report that concerns an account, then
+// plans the trade again from the position held once the book is in, under
+// e.mu, so reports handled concurrently never open the same position twice
+func (e *Engine) handleReport(rep models.Report) {
+	e.mu.Lock()
+	a, ok := e.accounts[rep.WorkspaceID]
+	trade := false
+	var held float64
+	if ok {
+		held = a.held(rep.Symbol)
+		_, _, _, trade = plan(rep, held, a.Settings)
+	}
+	e.mu.Unlock()
+	if !ok || (held == 0 && !trade) {
+		return
+	}
+
+	book, err := e.books(e.ctx, rep.Symbol)
+	if err != nil {
+		if e.ctx.Err() == nil {
+			e.logger.Warn().Err(err).Str("report_id", rep.ReportID).Str("symbol", rep.Symbol).Msg("Failed to fetch order book for paper account")
+		}
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	a = e.accountLocked(rep.WorkspaceID)
+	side, qty, open, trade := plan(rep, a.held(rep.Symbol), a.Settings)
+	if !trade {
+		a.mark(rep.Symbol, book.Mid(), time.Now().UnixMilli())
+		if err := e.save(); err != nil {
+			e.logger.Error().Err(err).Msg("Failed to save paper accounts")
+		}
+		return
+	}
+	if mid := book.Mid(); open && mid > 0 {
+		qty += a.Settings.AutoTrade.Notional / mid
+	}
+	if qty <= 0 {
+		return
+	}
+	order := Order{Symbol: rep.Symbol, Side: side, Qty: qty}
+	if _, err := e.fillLocked(rep.WorkspaceID, order, book, Fill{Source: SourceReport, ReportID: rep.ReportID}); err != nil {
+		e.logger.Warn().Err(err).Str("report_id", rep.ReportID).Str("workspace_id", rep.WorkspaceID).Msg("Paper trade on report signal failed")
+	}
+}
+
+// plan decides the order a report's trend calls for given the position
+// held: its side, the quantity that closes an opposite position and
+// whether a new position of the auto-trade notional is opened on top.
+// Reports of single ensemble members and manual submissions never trade.
+func plan(rep models.Report, held float64, s Settings) (side string, closeQty float64, open, trade bool) {
+	at := s.AutoTrade
+	if !at.Enabled || (len(at.Symbols) > 0 && !slices.Contains(at.Symbols, rep.Symbol)) {
+		return "", 0, false, false
+	}
+	if rep.EnsembleMemberOf != "" || rep.Manual {
+		return "", 0, false, false
+	}
+	if rep.Ensemble != nil && rep.Ensemble.TrendAgreement < at.MinAgreement {
+		return "", 0, false, false
+	}
+	switch rep.TechnicalAnalysis.TrendDirection {
+	case "bullish":
+		if held <= 0 {
+			return Buy, -held, true, true
+		}
+	case "bearish":
+		if held > 0 || (held == 0 && s.AllowShort) {
+			return Sell, held, s.AllowShort, true
+		}
+	case "neutral":
+		if at.CloseOnNeutral && held > 0 {
+			return Sell, held, false, true
+		}
+		if at.CloseOnNeutral && held < 0 {
+			return Buy, -held, false, true
+		}
+	}
+	return "", 0, false, false
+}
+
+// accountLocked returns a workspace's account, creating it with the
+// configured balance; callers hold e.mu
+func (e *Engine) accountLocked(workspaceID string) *Account {
+	a, ok := e.accounts[workspaceID]
+	if !ok {
+		a = newAccount(workspaceID, e.store.Get().Paper.Balance(), time.Now().UnixMilli())
+		e.accounts[workspaceID] = a
+	}
+	return a
+}
+
+// save writes all accounts; callers hold e.mu
+func (e *Engine) save() error {
+	accounts := make([]*Account, 0, len(e.accounts))
+	for _, a := range e.accounts {
+		accounts = append(accounts, a)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].CreatedAt < accounts[j].CreatedAt })
+	data, err := json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := e.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, e.path)
+}
@@ -0,0 +1,199 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/CryptoPulse/metrics"
+)
+
+// Priority orders the jobs waiting in a pool
+type Priority int
+
+const (
+	// Background jobs are monitor cycles and scheduled analyses
+	Background Priority = iota
+	// Interactive jobs are on-demand requests; they run before any waiting
+	// background job
+	Interactive
+)
+
+func (p Priority) String() string {
+	if p == Interactive {
+		return "interactive"
+	}
+	return "background"
+}
+
+var (
+	// ErrFull is returned when a pool already holds its maximum of waiting jobs
+	ErrFull = errors.New("job queue is full")
+	// ErrClosed is returned when a pool is not running
+	ErrClosed = errors.New("job queue is closed")
+)
+
+// job is a queued call waiting for a worker
+type job struct {
+	ctx      context.Context
+	fn       func(context.Context) error
+	queuedAt time.Time
+	started  chan struct{}
+	err      chan error
+}
+
+// Pool runs jobs on a fixed number of workers. Waiting jobs are bounded and
+// interactive ones are taken before background ones.
+type Pool struct {
+	name     string
+	workers  int
+	capacity int
+	ctx      context.Context
+	// ready holds at least one token per waiting job
+	ready   chan struct{}
+	waiting [2][]*job
+	busy    int
+	mu      sync.Mutex
+}
+
+// Stats is a snapshot of a pool
+type Stats struct {
+	Name     string `json:"name"`
+	Workers  int    `json:"workers"`
+	Busy     int    `json:"busy"`
+	Capacity int    `json:"capacity"`
+	// Queued counts waiting jobs by priority
+	Queued map[string]int `json:"queued"`
+}
+
+// New creates a pool; it runs no jobs until Start is called
+func New(name string, workers, capacity int) *Pool {
+	return &Pool{
+		name:     name,
+		workers:  workers,
+		capacity: capacity,
+		ready:    make(chan struct{}, capacity),
+	}
+}
+
+// Start runs the workers until ctx is cancelled. Waiting jobs then fail
+// with ErrClosed; running ones finish.
+func (p *Pool) Start(ctx context.Context) {
+	p.mu.Lock()
+	p.ctx = ctx
+	p.mu.Unlock()
+	for i := 0; i < p.workers; i++ {
+		go p.work(ctx)
+	}
+}
+
+func (p *Pool) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.ready:
+		}
+		j := p.next()
+		if j == nil {
+			// The job was cancelled while waiting
+			continue
+		}
+		metrics.ObserveQueueWait(p.name, time.Since(j.queuedAt))
+		close(j.started)
+		j.err <- j.fn(j.ctx)
+		p.mu.Lock()
+		p.busy--
+		p.mu.Unlock()
+	}
+}
+
+// next takes the waiting job with the highest priority
+func (p *Pool) next() *job {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for prio := Interactive; prio >= Background; prio-- {
+		if q := p.waiting[prio]; len(q) > 0 {
+			j := q[0]
+			p.waiting[prio] = q[1:]
+			p.busy++
+			metrics.SetQueueDepth(p.name, prio.String(), len(p.waiting[prio]))
+			return j
+		}
+	}
+	return nil
+}
+
+// Do runs fn on a worker and returns its error. It fails fast with ErrFull
+// when the pool is saturated, and returns ctx's error if ctx ends before a
+// worker picks the job up. Once started, fn runs to completion.
+func (p *Pool) Do(ctx context.Context, prio Priority, fn func(context.Context) error) error {
+	j := &job{ctx: ctx, fn: fn, queuedAt: time.Now(), started: make(chan struct{}), err: make(chan error, 1)}
+	p.mu.Lock()
+	if p.ctx == nil || p.ctx.Err() != nil {
+		p.mu.Unlock()
+		return ErrClosed
+	}
+	if len(p.waiting[Background])+len(p.waiting[Interactive]) >= p.capacity {
+		p.mu.Unlock()
+		metrics.CountQueueRejected(p.name, prio.String())
+		return ErrFull
+	}
+	p.waiting[prio] = append(p.waiting[prio], j)
+	metrics.SetQueueDepth(p.name, prio.String(), len(p.waiting[prio]))
+	poolCtx := p.ctx
+	p.mu.Unlock()
+	select {
+	case p.ready <- struct{}{}:
+	default:
+		// Tokens left by cancelled jobs already cover this one
+	}
+
+	var err error
+	select {
+	case <-j.started:
+		return <-j.err
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-poolCtx.Done():
+		err = ErrClosed
+	}
+	if !p.remove(j) {
+		// A worker took the job meanwhile
+		return <-j.err
+	}
+	return err
+}
+
+// remove drops a waiting job, reporting whether it was still waiting
+func (p *Pool) remove(j *job) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for prio, q := range p.waiting {
+		for i, w := range q {
+			if w == j {
+				p.waiting[prio] = append(q[:i:i], q[i+1:]...)
+				metrics.SetQueueDepth(p.name, Priority(prio).String(), len(p.waiting[prio]))
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Stats returns the pool's current load
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return Stats{
+		Name:     p.name,
+		Workers:  p.workers,
+		Busy:     p.busy,
+		Capacity: p.capacity,
+		Queued: map[string]int{
+			Background.String():  len(p.waiting[Background]),
+			Interactive.String(): len(p.waiting[Interactive]),
+		},
+	}
+}
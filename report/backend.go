@@ -0,0 +1,66 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/songzhibin97/CryptoPulse/config"
+)
+
+// Collections of stored documents
+const (
+	CollectionReports = "reports"
+	CollectionFailed  = "failed"
+)
+
+var (
+	// ErrNotFound is returned for documents a backend does not hold
+	ErrNotFound = errors.New("document not found")
+	// ErrInvalidID is returned for IDs that could escape the collection
+	ErrInvalidID = errors.New("invalid document id")
+)
+
+// validID matches the IDs backends accept: report and analysis UUIDs and
+// other simple names
+var validID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,127}$`)
+
+// Backend stores the JSON documents of reports and failed analyses by
+// collection and ID
+type Backend interface {
+	// Name identifies the backend in logs
+	Name() string
+	Put(ctx context.Context, collection, id string, data []byte) error
+	// Get returns ErrNotFound for unknown documents
+	Get(ctx context.Context, collection, id string) ([]byte, error)
+	// List returns the IDs of a collection in no particular order
+	List(ctx context.Context, collection string) ([]string, error)
+	// Delete returns ErrNotFound for unknown documents
+	Delete(ctx context.Context, collection, id string) error
+	// Check verifies that the backend is reachable and writable
+	Check(ctx context.Context) error
+	Close() error
+}
+
+// Open creates the configured backend; the filesystem backend stores
+// documents under dir
+func Open(cfg config.Config, dir string) (Backend, error) {
+	storage := cfg.Storage
+	switch storage.Kind() {
+	case config.StorageFilesystem:
+		return NewFileBackend(dir)
+	case config.StorageSQLite:
+		return OpenSQLite(storage.SQLitePath())
+	case config.StorageS3:
+		return NewS3Backend(storage.S3, cfg.ProxyURL), nil
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", storage.Backend)
+}
+
+func checkID(id string) error {
+	if !validID.MatchString(id) {
+		return fmt.Errorf("%w: %q", ErrInvalidID, id)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package report
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/songzhibin97/CryptoPulse/models"
+)
+
+//go:embed basket_schema.json
+var basketSchemaJSON []byte
+
+// BasketSchema returns the JSON schema that AI responses to basket prompts
+// must follow
+func BasketSchema() json.RawMessage {
+	return json.RawMessage(basketSchemaJSON)
+}
+
+// ParseBasket extracts and validates a basket report from an AI response.
+// Every symbol the response names must belong to the basket.
+func ParseBasket(raw string, symbols []string) (models.BasketReport, error) {
+	var rep models.BasketReport
+	body := ExtractJSON(raw)
+	if err := validateSchema(basketSchemaJSON, body); err != nil {
+		return rep, err
+	}
+	if err := json.Unmarshal([]byte(body), &rep); err != nil {
+		return rep, &ValidationError{Problems: []string{fmt.Sprintf("decode report failed: %v", err)}}
+	}
+	var problems []string
+	check := func(symbol, path string) {
+		if !slices.Contains(symbols, symbol) {
+			problems = append(problems, fmt.Sprintf("%s: %q is not in the basket", path, symbol))
+		}
+	}
+	for i, s := range rep.Leaders {
+		check(s, fmt.Sprintf("$.leaders[%d]", i))
+	}
+	for i, s := range rep.Laggards {
+		check(s, fmt.Sprintf("$.laggards[%d]", i))
+	}
+	for i, v := range rep.SymbolViews {
+		check(v.Symbol, fmt.Sprintf("$.symbol_views[%d].symbol", i))
+	}
+	if len(problems) > 0 {
+		return rep, &ValidationError{Problems: problems}
+	}
+	return rep, nil
+}
@@ -0,0 +1,211 @@
+package report
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/songzhibin97/CryptoPulse/models"
+)
+
+// levelTolerance is the relative distance within which levels from
+// different models are considered the same level
+const levelTolerance = 0.002
+
+// Consensus merges reports produced by several models on the same market
+// snapshot. Numeric readings are averaged, the trend direction is decided by
+// majority vote (ties are neutral), nearby support/resistance levels are
+// clustered and averaged, and signals and alerts are de-duplicated.
+func Consensus(reports []models.Report) models.Report {
+	if len(reports) == 0 {
+		return models.Report{}
+	}
+	n := float64(len(reports))
+	first := reports[0]
+	out := models.Report{
+		Symbol:       first.Symbol,
+		AnalysisType: first.AnalysisType,
+		Timeframe:    first.Timeframe,
+		Sentiment: models.Sentiment{
+			VolumeDistribution: first.Sentiment.VolumeDistribution,
+		},
+	}
+
+	maSums := make(map[string]float64)
+	maCounts := make(map[string]int)
+	var techLevels, bookLevels [][]models.Level
+	seenSignals := make(map[string]bool)
+	seenAlerts := make(map[string]bool)
+	seenTrades := make(map[string]bool)
+	seenWalls := make(map[string]bool)
+	for _, r := range reports {
+		if r.Timestamp > out.Timestamp {
+			out.Timestamp = r.Timestamp
+		}
+		out.CapitalFlow.BuySellRatio += r.CapitalFlow.BuySellRatio / n
+		out.CapitalFlow.NetFlow += r.CapitalFlow.NetFlow / n
+		for _, t := range r.CapitalFlow.LargeTrades {
+			if !seenTrades[t.Price+"|"+t.Volume] {
+				seenTrades[t.Price+"|"+t.Volume] = true
+				out.CapitalFlow.LargeTrades = append(out.CapitalFlow.LargeTrades, t)
+			}
+		}
+
+		ta := r.TechnicalAnalysis
+		for k, v := range ta.MA {
+			if v != nil {
+				maSums[k] += *v
+				maCounts[k]++
+			} else if _, ok := maCounts[k]; !ok {
+				maCounts[k] = 0
+			}
+		}
+		out.TechnicalAnalysis.RSI += ta.RSI / n
+		out.TechnicalAnalysis.MACD.MACD += ta.MACD.MACD / n
+		out.TechnicalAnalysis.MACD.Signal += ta.MACD.Signal / n
+		out.TechnicalAnalysis.MACD.Histogram += ta.MACD.Histogram / n
+		out.TechnicalAnalysis.Bollinger.Upper += ta.Bollinger.Upper / n
+		out.TechnicalAnalysis.Bollinger.Middle += ta.Bollinger.Middle / n
+		out.TechnicalAnalysis.Bollinger.Lower += ta.Bollinger.Lower / n
+		techLevels = append(techLevels, ta.SupportResistance)
+		for _, s := range ta.TrendSignals {
+			if !seenSignals[s] {
+				seenSignals[s] = true
+				out.TechnicalAnalysis.TrendSignals = append(out.TechnicalAnalysis.TrendSignals, s)
+			}
+		}
+
+		ob := r.OrderBook
+		out.OrderBook.BuySellDepthRatio += ob.BuySellDepthRatio / n
+		levels := make([]models.Level, 0, len(ob.SupportResistance))
+		for _, l := range ob.SupportResistance {
+			levels = append(levels, models.Level{Price: l.Price, Type: l.Type, Strength: l.Depth})
+		}
+		bookLevels = append(bookLevels, levels)
+		for _, w := range ob.FakeWalls {
+			if !seenWalls[w.Price+"|"+w.Type] {
+				seenWalls[w.Price+"|"+w.Type] = true
+				out.OrderBook.FakeWalls = append(out.OrderBook.FakeWalls, w)
+			}
+		}
+
+		out.Sentiment.Volatility.HV += r.Sentiment.Volatility.HV / n
+		out.Sentiment.Volatility.ATR += r.Sentiment.Volatility.ATR / n
+		out.Sentiment.FearGreedIndex += r.Sentiment.FearGreedIndex / n
+
+		for _, a := range r.RiskAlerts {
+			if !seenAlerts[a.Type+"|"+a.Description] {
+				seenAlerts[a.Type+"|"+a.Description] = true
+				out.RiskAlerts = append(out.RiskAlerts, a)
+			}
+		}
+	}
+
+	out.TechnicalAnalysis.MA = make(map[string]*float64, len(maCounts))
+	for k, count := range maCounts {
+		if count == 0 {
+			out.TechnicalAnalysis.MA[k] = nil
+			continue
+		}
+		avg := maSums[k] / float64(count)
+		out.TechnicalAnalysis.MA[k] = &avg
+	}
+	out.TechnicalAnalysis.SupportResistance = mergeLevels(techLevels)
+	for _, l := range mergeLevels(bookLevels) {
+		out.OrderBook.SupportResistance = append(out.OrderBook.SupportResistance, models.DepthLevel{Price: l.Price, Type: l.Type, Depth: l.Strength})
+	}
+	out.TechnicalAnalysis.TrendDirection, out.Ensemble = trendVote(reports)
+	return out
+}
+
+// trendVote picks the majority trend direction across reports
+func trendVote(reports []models.Report) (string, *models.EnsembleInfo) {
+	votes := make(map[string]int)
+	for _, r := range reports {
+		votes[r.TechnicalAnalysis.TrendDirection]++
+	}
+	direction, best, tie := "neutral", 0, false
+	for _, d := range []string{"bullish", "bearish", "neutral"} {
+		switch {
+		case votes[d] > best:
+			direction, best, tie = d, votes[d], false
+		case votes[d] == best && best > 0:
+			tie = true
+		}
+	}
+	if tie {
+		direction = "neutral"
+	}
+	return direction, &models.EnsembleInfo{
+		TrendVotes:     votes,
+		TrendAgreement: float64(votes[direction]) / float64(len(reports)),
+	}
+}
+
+type levelCluster struct {
+	typ      string
+	sum      float64
+	strength float64
+	count    int
+}
+
+// mergeLevels clusters levels of the same type whose prices lie within
+// levelTolerance of each other. Each cluster's price is the mean of its
+// members and its strength is averaged over all reports, so levels that only
+// one model found are down-weighted.
+func mergeLevels(perReport [][]models.Level) []models.Level {
+	type point struct {
+		price    float64
+		typ      string
+		strength float64
+	}
+	var points []point
+	for _, levels := range perReport {
+		for _, l := range levels {
+			price, err := strconv.ParseFloat(l.Price, 64)
+			if err != nil || price <= 0 {
+				continue
+			}
+			points = append(points, point{price: price, typ: l.Type, strength: l.Strength})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].price < points[j].price })
+
+	var clusters []*levelCluster
+	for _, p := range points {
+		var match *levelCluster
+		for _, c := range clusters {
+			if c.typ == p.typ && math.Abs(p.price-c.sum/float64(c.count))/p.price <= levelTolerance {
+				match = c
+				break
+			}
+		}
+		if match == nil {
+			match = &levelCluster{typ: p.typ}
+			clusters = append(clusters, match)
+		}
+		match.sum += p.price
+		match.strength += p.strength
+		match.count++
+	}
+
+	n := float64(len(perReport))
+	merged := make([]models.Level, 0, len(clusters))
+	for _, c := range clusters {
+		merged = append(merged, models.Level{
+			Price:    formatPrice(c.sum / float64(c.count)),
+			Type:     c.typ,
+			Strength: c.strength / n,
+		})
+	}
+	return merged
+}
+
+// formatPrice rounds a price to 8 significant digits
+func formatPrice(f float64) string {
+	if f == 0 {
+		return "0"
+	}
+	scale := math.Pow(10, 7-math.Floor(math.Log10(math.Abs(f))))
+	return strconv.FormatFloat(math.Round(f*scale)/scale, 'f', -1, 64)
+}
@@ -0,0 +1,136 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/songzhibin97/CryptoPulse/models"
+)
+
+// RSI bounds of the oversold and overbought regimes
+const (
+	rsiOversold   = 30
+	rsiOverbought = 70
+)
+
+// Diff compares a report with an earlier one of the same symbol
+func Diff(from, to models.Report) models.ReportDiff {
+	d := models.ReportDiff{
+		From:          from.ReportID,
+		To:            to.ReportID,
+		FromTime:      from.Timestamp,
+		ToTime:        to.Timestamp,
+		AddedLevels:   []models.LevelRef{},
+		RemovedLevels: []models.LevelRef{},
+		NewAlerts:     []models.RiskAlert{},
+		ClearedAlerts: []models.RiskAlert{},
+		Summary:       []string{},
+	}
+	if from.Price > 0 && to.Price > 0 {
+		d.PriceChangePct = math.Round((to.Price/from.Price-1)*1e4) / 100
+	}
+
+	fromTrend, toTrend := from.TechnicalAnalysis.TrendDirection, to.TechnicalAnalysis.TrendDirection
+	if !strings.EqualFold(fromTrend, toTrend) {
+		d.TrendFlip = &models.Change{From: fromTrend, To: toTrend}
+		d.Summary = append(d.Summary, fmt.Sprintf("Trend flipped from %s to %s", orNone(fromTrend), orNone(toTrend)))
+	}
+	fromRSI, toRSI := from.TechnicalAnalysis.RSI, to.TechnicalAnalysis.RSI
+	if a, b := rsiRegime(fromRSI), rsiRegime(toRSI); a != b {
+		d.RSIRegime = &models.Change{From: a, To: b}
+		d.Summary = append(d.Summary, fmt.Sprintf("RSI moved from %s to %s (%s → %s)", a, b, num(fromRSI), num(toRSI)))
+	}
+
+	fromAlerts := alertTypes(from.RiskAlerts)
+	toAlerts := alertTypes(to.RiskAlerts)
+	for _, a := range to.RiskAlerts {
+		if !fromAlerts[a.Type] {
+			d.NewAlerts = append(d.NewAlerts, a)
+			d.Summary = append(d.Summary, fmt.Sprintf("New risk alert %s: %s", a.Type, a.Description))
+		}
+	}
+	for _, a := range from.RiskAlerts {
+		if !toAlerts[a.Type] {
+			d.ClearedAlerts = append(d.ClearedAlerts, a)
+			d.Summary = append(d.Summary, fmt.Sprintf("Risk alert %s cleared", a.Type))
+		}
+	}
+
+	fromLevels, toLevels := levelRefs(from), levelRefs(to)
+	for _, l := range toLevels {
+		if !containsLevel(fromLevels, l) {
+			d.AddedLevels = append(d.AddedLevels, l)
+			d.Summary = append(d.Summary, fmt.Sprintf("New %s %s at %s", sourceName(l.Source), l.Type, l.Price))
+		}
+	}
+	for _, l := range fromLevels {
+		if !containsLevel(toLevels, l) {
+			d.RemovedLevels = append(d.RemovedLevels, l)
+			d.Summary = append(d.Summary, fmt.Sprintf("Removed %s %s at %s", sourceName(l.Source), l.Type, l.Price))
+		}
+	}
+	if d.PriceChangePct != 0 {
+		d.Summary = append(d.Summary, fmt.Sprintf("Price %+.2f%% since the previous report", d.PriceChangePct))
+	}
+	return d
+}
+
+func rsiRegime(rsi float64) string {
+	switch {
+	case rsi >= rsiOverbought:
+		return "overbought"
+	case rsi > 0 && rsi <= rsiOversold:
+		return "oversold"
+	}
+	return "neutral"
+}
+
+func alertTypes(alerts []models.RiskAlert) map[string]bool {
+	types := make(map[string]bool, len(alerts))
+	for _, a := range alerts {
+		types[a.Type] = true
+	}
+	return types
+}
+
+func levelRefs(rep models.Report) []models.LevelRef {
+	refs := make([]models.LevelRef, 0, len(rep.TechnicalAnalysis.SupportResistance)+len(rep.OrderBook.SupportResistance))
+	for _, l := range rep.TechnicalAnalysis.SupportResistance {
+		refs = append(refs, models.LevelRef{Source: "technical", Type: l.Type, Price: l.Price})
+	}
+	for _, l := range rep.OrderBook.SupportResistance {
+		refs = append(refs, models.LevelRef{Source: "order_book", Type: l.Type, Price: l.Price})
+	}
+	return refs
+}
+
+// containsLevel reports whether levels hold one of l's source and type
+// within levelTolerance of its price
+func containsLevel(levels []models.LevelRef, l models.LevelRef) bool {
+	p := parse(l.Price)
+	for _, other := range levels {
+		if other.Source != l.Source || !strings.EqualFold(other.Type, l.Type) {
+			continue
+		}
+		q := parse(other.Price)
+		if p == q || (p > 0 && math.Abs(p-q)/p <= levelTolerance) {
+			return true
+		}
+	}
+	return false
+}
+
+func sourceName(source string) string {
+	if source == "order_book" {
+		return "order book"
+	}
+	return source
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
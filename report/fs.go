@@ -0,0 +1,117 @@
+package report
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileBackend keeps reports as <dir>/<id>.json and failed analyses as
+// <dir>/failed/<id>.json
+type FileBackend struct {
+	dir string
+}
+
+// NewFileBackend creates the directories of a file backend
+func NewFileBackend(dir string) (*FileBackend, error) {
+	if err := os.MkdirAll(filepath.Join(dir, CollectionFailed), 0755); err != nil {
+		return nil, err
+	}
+	return &FileBackend{dir: dir}, nil
+}
+
+// Name returns "filesystem"
+func (b *FileBackend) Name() string {
+	return "filesystem"
+}
+
+func (b *FileBackend) path(collection, id string) (string, error) {
+	if err := checkID(id); err != nil {
+		return "", err
+	}
+	return filepath.Join(b.collectionDir(collection), id+".json"), nil
+}
+
+func (b *FileBackend) collectionDir(collection string) string {
+	if collection == CollectionReports {
+		return b.dir
+	}
+	return filepath.Join(b.dir, collection)
+}
+
+// Put writes a document through a temporary file so readers never see a
+// partial one
+func (b *FileBackend) Put(_ context.Context, collection, id string, data []byte) error {
+	path, err := b.path(collection, id)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Get reads a document
+func (b *FileBackend) Get(_ context.Context, collection, id string) ([]byte, error) {
+	path, err := b.path(collection, id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// List returns the IDs of the .json files of a collection
+func (b *FileBackend) List(_ context.Context, collection string) ([]string, error) {
+	entries, err := os.ReadDir(b.collectionDir(collection))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if id, ok := strings.CutSuffix(e.Name(), ".json"); ok && !e.IsDir() && validID.MatchString(id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Delete removes a document
+func (b *FileBackend) Delete(_ context.Context, collection, id string) error {
+	path, err := b.path(collection, id)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Check verifies that the report directory is writable
+func (b *FileBackend) Check(context.Context) error {
+	f, err := os.CreateTemp(b.dir, ".check-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// Close does nothing
+func (b *FileBackend) Close() error {
+	return nil
+}
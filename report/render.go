@@ -0,0 +1,445 @@
+package report
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/songzhibin97/CryptoPulse/models"
+	"github.com/songzhibin97/CryptoPulse/signals"
+)
+
+// ErrNotRenderable is returned by Decode for basket reports, which have no
+// renderers
+var ErrNotRenderable = errors.New("only symbol reports can be rendered")
+
+// Decode parses a stored symbol report
+func Decode(data []byte) (models.Report, error) {
+	var rep struct {
+		models.Report
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(data, &rep); err != nil {
+		return models.Report{}, err
+	}
+	if rep.Kind == "basket" {
+		return models.Report{}, ErrNotRenderable
+	}
+	return rep.Report, nil
+}
+
+// Markdown renders a report for notes and chats
+func Markdown(rep models.Report) []byte {
+	var b bytes.Buffer
+	ta := rep.TechnicalAnalysis
+	sig := signals.Extract(rep)
+	fmt.Fprintf(&b, "# %s analysis\n\n", rep.Symbol)
+	fmt.Fprintf(&b, "- **Time:** %s\n", formatTime(rep.Timestamp))
+	fmt.Fprintf(&b, "- **Timeframe:** %s\n", strings.Join(rep.Timeframe, ", "))
+	if rep.Price > 0 {
+		fmt.Fprintf(&b, "- **Price:** %s\n", num(rep.Price))
+	}
+	fmt.Fprintf(&b, "- **Trend:** %s\n", ta.TrendDirection)
+	fmt.Fprintf(&b, "- **Signal:** %s, confidence %s", sig.Direction, num(sig.Confidence))
+	if sig.Stop > 0 && sig.Target > 0 {
+		fmt.Fprintf(&b, ", entry %s, stop %s, target %s (R:R %s)", num(sig.Entry), num(sig.Stop), num(sig.Target), num(sig.RiskReward))
+	}
+	fmt.Fprintf(&b, "\n- **Report ID:** `%s`\n", rep.ReportID)
+
+	if c := rep.Changes; c != nil {
+		fmt.Fprintf(&b, "\n## Changes since %s\n\n", formatTime(c.FromTime))
+		if len(c.Summary) == 0 {
+			b.WriteString("- No changes\n")
+		}
+		for _, line := range c.Summary {
+			fmt.Fprintf(&b, "- %s\n", line)
+		}
+	}
+
+	if len(ta.TrendSignals) > 0 {
+		b.WriteString("\n## Trend signals\n\n")
+		for _, s := range ta.TrendSignals {
+			fmt.Fprintf(&b, "- %s\n", s)
+		}
+	}
+
+	b.WriteString("\n## Indicators\n\n| Indicator | Value |\n| --- | --- |\n")
+	fmt.Fprintf(&b, "| RSI | %s |\n", num(ta.RSI))
+	fmt.Fprintf(&b, "| MACD | %s / signal %s / histogram %s |\n", num(ta.MACD.MACD), num(ta.MACD.Signal), num(ta.MACD.Histogram))
+	fmt.Fprintf(&b, "| Bollinger | %s / %s / %s |\n", num(ta.Bollinger.Upper), num(ta.Bollinger.Middle), num(ta.Bollinger.Lower))
+	for _, name := range sortedKeys(ta.MA) {
+		if v := ta.MA[name]; v != nil {
+			fmt.Fprintf(&b, "| %s | %s |\n", mdCell(name), num(*v))
+		}
+	}
+	fmt.Fprintf(&b, "| HV / ATR | %s / %s |\n", num(rep.Sentiment.Volatility.HV), num(rep.Sentiment.Volatility.ATR))
+	fmt.Fprintf(&b, "| Fear & greed | %s |\n", num(rep.Sentiment.FearGreedIndex))
+	fmt.Fprintf(&b, "| Buy/sell ratio | %s |\n", num(rep.CapitalFlow.BuySellRatio))
+	fmt.Fprintf(&b, "| Net flow | %s |\n", num(rep.CapitalFlow.NetFlow))
+	fmt.Fprintf(&b, "| Depth ratio | %s |\n", num(rep.OrderBook.BuySellDepthRatio))
+
+	if rows := levelRows(rep); len(rows) > 0 {
+		b.WriteString("\n## Levels\n\n| Source | Type | Price | Strength | Depth |\n| --- | --- | --- | --- | --- |\n")
+		for _, r := range rows {
+			fmt.Fprintf(&b, "| %s |\n", strings.Join(mdCells(r), " | "))
+		}
+	}
+
+	if len(rep.CapitalFlow.LargeTrades) > 0 {
+		b.WriteString("\n## Large trades\n\n| Price | Volume | Impact |\n| --- | --- | --- |\n")
+		for _, t := range rep.CapitalFlow.LargeTrades {
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", mdCell(t.Price), mdCell(t.Volume), mdCell(t.Impact))
+		}
+	}
+
+	if len(rep.RiskAlerts) > 0 {
+		b.WriteString("\n## Risk alerts\n\n")
+		for _, a := range rep.RiskAlerts {
+			fmt.Fprintf(&b, "- **%s**: %s\n", a.Type, a.Description)
+		}
+	}
+
+	if e := rep.Ensemble; e != nil {
+		fmt.Fprintf(&b, "\n## Ensemble\n\nTrend agreement %s\n\n", num(e.TrendAgreement))
+		for _, m := range e.Members {
+			outcome := m.TrendDirection
+			if m.Error != "" {
+				outcome = "failed: " + m.Error
+			}
+			fmt.Fprintf(&b, "- %s %s: %s\n", m.Backend, m.Model, outcome)
+		}
+	}
+
+	if u := rep.Usage; u != nil {
+		fmt.Fprintf(&b, "\n---\n\n_%s %s, %d prompt + %d completion tokens, %d attempt(s), %d ms, $%s_\n",
+			u.Backend, u.Model, u.PromptTokens, u.CompletionTokens, u.Attempts, u.LatencyMs, num(u.CostUSD))
+	}
+	return b.Bytes()
+}
+
+// LevelsCSV renders the report's technical, order book and fake wall levels
+func LevelsCSV(rep models.Report) ([]byte, error) {
+	return writeCSV([]string{"source", "type", "price", "strength", "depth"}, levelRows(rep))
+}
+
+// AlertsCSV renders the report's risk alerts
+func AlertsCSV(rep models.Report) ([]byte, error) {
+	rows := make([][]string, 0, len(rep.RiskAlerts))
+	for _, a := range rep.RiskAlerts {
+		rows = append(rows, []string{rep.Symbol, a.Type, a.Description, formatTime(a.Timestamp)})
+	}
+	return writeCSV([]string{"symbol", "type", "description", "time"}, rows)
+}
+
+// HTML renders a report as a self-contained page with a chart of each
+// interval's stored candles and the report's levels
+func HTML(rep models.Report) ([]byte, error) {
+	intervals := make([]string, 0, len(rep.Klines))
+	for interval := range rep.Klines {
+		intervals = append(intervals, interval)
+	}
+	slices.SortFunc(intervals, func(a, b string) int {
+		da, _ := models.IntervalDuration(a)
+		db, _ := models.IntervalDuration(b)
+		return cmp.Compare(da, db)
+	})
+	charts := make([]chart, 0, len(intervals))
+	for _, interval := range intervals {
+		if svg := candleChart(rep, rep.Klines[interval]); svg != "" {
+			charts = append(charts, chart{Interval: interval, SVG: template.HTML(svg)})
+		}
+	}
+	var b bytes.Buffer
+	err := pageTemplate.Execute(&b, struct {
+		Symbol string
+		Time   string
+		Charts []chart
+		Body   template.HTML
+	}{
+		Symbol: rep.Symbol,
+		Time:   formatTime(rep.Timestamp),
+		Charts: charts,
+		Body:   template.HTML(markdownHTML(Markdown(rep))),
+	})
+	return b.Bytes(), err
+}
+
+type chart struct {
+	Interval string
+	SVG      template.HTML
+}
+
+var pageTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Symbol}} analysis {{.Time}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 960px; margin: 24px auto; color: #222; }
+table { border-collapse: collapse; margin: 8px 0; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+code { background: #f3f3f3; padding: 1px 4px; }
+.chart { margin: 16px 0; }
+</style>
+</head>
+<body>
+{{.Body}}
+{{range .Charts}}<div class="chart"><h2>{{.Interval}}</h2>{{.SVG}}</div>
+{{end}}</body>
+</html>
+`))
+
+// Chart geometry in SVG units
+const (
+	chartWidth  = 900
+	chartHeight = 320
+	chartPad    = 60
+)
+
+// candleChart draws candles with the report's levels and price as an SVG,
+// or returns "" without candles
+func candleChart(rep models.Report, klines []models.Kline) string {
+	if len(klines) == 0 {
+		return ""
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, k := range klines {
+		lo, hi = math.Min(lo, parse(k.Low)), math.Max(hi, parse(k.High))
+	}
+	span := hi - lo
+	if span <= 0 {
+		span = math.Max(hi*0.01, 1)
+	}
+	// Levels far outside the candles would flatten them; they are left out
+	type line struct {
+		price float64
+		color string
+		label string
+	}
+	var lines []line
+	add := func(price float64, color, label string) {
+		if price > 0 && price >= lo-span && price <= hi+span {
+			lines = append(lines, line{price, color, label})
+		}
+	}
+	for _, l := range rep.TechnicalAnalysis.SupportResistance {
+		add(parse(l.Price), levelColor(l.Type), l.Type+" "+l.Price)
+	}
+	for _, l := range rep.OrderBook.SupportResistance {
+		add(parse(l.Price), levelColor(l.Type), "book "+l.Type+" "+l.Price)
+	}
+	add(rep.Price, "#1f77b4", "price "+num(rep.Price))
+	for _, l := range lines {
+		lo, hi = math.Min(lo, l.price), math.Max(hi, l.price)
+	}
+	if hi <= lo {
+		hi = lo + span
+	}
+	plotW := float64(chartWidth - 2*chartPad)
+	plotH := float64(chartHeight - 2*20)
+	y := func(p float64) float64 { return 20 + (hi-p)/(hi-lo)*plotH }
+	step := plotW / float64(len(klines))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<rect x="%d" y="20" width="%.0f" height="%.0f" fill="#fff" stroke="#ddd"/>`, chartPad, plotW, plotH)
+	for i := 0; i <= 4; i++ {
+		p := lo + (hi-lo)*float64(i)/4
+		fmt.Fprintf(&b, `<text x="4" y="%.1f" font-size="11" fill="#666">%s</text>`, y(p)+4, num(p))
+	}
+	for i, k := range klines {
+		o, c := parse(k.Open), parse(k.Close)
+		color := "#26a69a"
+		if c < o {
+			color = "#ef5350"
+		}
+		x := chartPad + step*(float64(i)+0.5)
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"/>`, x, y(parse(k.High)), x, y(parse(k.Low)), color)
+		top, bottom := y(math.Max(o, c)), y(math.Min(o, c))
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`, x-step*0.35, top, step*0.7, math.Max(bottom-top, 1), color)
+	}
+	for _, l := range lines {
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%.0f" y2="%.1f" stroke="%s" stroke-dasharray="6 4"/>`, chartPad, y(l.price), chartPad+plotW, y(l.price), l.color)
+		fmt.Fprintf(&b, `<text x="%.0f" y="%.1f" font-size="11" fill="%s" text-anchor="end">%s</text>`, chartPad+plotW-4, y(l.price)-3, l.color, html.EscapeString(l.label))
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="11" fill="#666">%s</text>`, chartPad, chartHeight-4, formatTime(klines[0].OpenTime))
+	fmt.Fprintf(&b, `<text x="%.0f" y="%d" font-size="11" fill="#666" text-anchor="end">%s</text>`, chartPad+plotW, chartHeight-4, formatTime(klines[len(klines)-1].CloseTime))
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+func levelColor(typ string) string {
+	if strings.Contains(strings.ToLower(typ), "support") {
+		return "#2e7d32"
+	}
+	return "#c62828"
+}
+
+// markdownHTML converts the subset of Markdown that Markdown writes:
+// headings, bullet lists, tables, rules and inline bold, italic and code
+func markdownHTML(md []byte) string {
+	var b strings.Builder
+	lines := strings.Split(string(md), "\n")
+	inList := false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if inList && !strings.HasPrefix(line, "- ") {
+			b.WriteString("</ul>\n")
+			inList = false
+		}
+		switch {
+		case strings.HasPrefix(line, "## "):
+			fmt.Fprintf(&b, "<h2>%s</h2>\n", inline(line[3:]))
+		case strings.HasPrefix(line, "# "):
+			fmt.Fprintf(&b, "<h1>%s</h1>\n", inline(line[2:]))
+		case strings.HasPrefix(line, "- "):
+			if !inList {
+				b.WriteString("<ul>\n")
+				inList = true
+			}
+			fmt.Fprintf(&b, "<li>%s</li>\n", inline(line[2:]))
+		case line == "---":
+			b.WriteString("<hr>\n")
+		case strings.HasPrefix(line, "| "):
+			b.WriteString("<table>\n")
+			start := i
+			for j := i; j < len(lines) && strings.HasPrefix(lines[j], "| "); j++ {
+				i = j
+				if strings.HasPrefix(lines[j], "| ---") {
+					continue
+				}
+				tag := "td"
+				if j == start {
+					tag = "th"
+				}
+				b.WriteString("<tr>")
+				for _, cell := range splitRow(lines[j]) {
+					fmt.Fprintf(&b, "<%s>%s</%s>", tag, inline(cell), tag)
+				}
+				b.WriteString("</tr>\n")
+			}
+			b.WriteString("</table>\n")
+		case line != "":
+			fmt.Fprintf(&b, "<p>%s</p>\n", inline(line))
+		}
+	}
+	if inList {
+		b.WriteString("</ul>\n")
+	}
+	return b.String()
+}
+
+// splitRow splits a table row on unescaped pipes
+func splitRow(row string) []string {
+	row = strings.TrimSuffix(strings.TrimPrefix(row, "| "), " |")
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case strings.HasPrefix(row[i:], " | "):
+			cells = append(cells, cell.String())
+			cell.Reset()
+			i += 2
+		default:
+			cell.WriteByte(row[i])
+		}
+	}
+	return append(cells, cell.String())
+}
+
+// inline escapes text and converts **bold**, _italic_ and `code` spans
+func inline(s string) string {
+	s = html.EscapeString(s)
+	for _, m := range []struct{ mark, open, close string }{
+		{"**", "<strong>", "</strong>"},
+		{"`", "<code>", "</code>"},
+	} {
+		for strings.Count(s, m.mark) >= 2 {
+			s = strings.Replace(s, m.mark, m.open, 1)
+			s = strings.Replace(s, m.mark, m.close, 1)
+		}
+	}
+	if len(s) > 2 && strings.HasPrefix(s, "_") && strings.HasSuffix(s, "_") {
+		s = "<em>" + s[1:len(s)-1] + "</em>"
+	}
+	return s
+}
+
+// levelRows lists every level of a report as source, type, price,
+// strength and depth
+func levelRows(rep models.Report) [][]string {
+	var rows [][]string
+	for _, l := range rep.TechnicalAnalysis.SupportResistance {
+		rows = append(rows, []string{"technical", l.Type, l.Price, num(l.Strength), ""})
+	}
+	for _, l := range rep.OrderBook.SupportResistance {
+		rows = append(rows, []string{"order_book", l.Type, l.Price, "", num(l.Depth)})
+	}
+	for _, l := range rep.OrderBook.FakeWalls {
+		rows = append(rows, []string{"fake_wall", l.Type, l.Price, "", num(l.Depth)})
+	}
+	return rows
+}
+
+func writeCSV(header []string, rows [][]string) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// mdCell escapes pipes and line breaks that would break a table row
+func mdCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+func mdCells(cells []string) []string {
+	out := make([]string, len(cells))
+	for i, c := range cells {
+		out[i] = mdCell(c)
+	}
+	return out
+}
+
+func sortedKeys(m map[string]*float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func formatTime(ms int64) string {
+	if ms == 0 {
+		return ""
+	}
+	return time.UnixMilli(ms).UTC().Format("2006-01-02 15:04 UTC")
+}
+
+func num(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func parse(s string) float64 {
+	f, _ := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+	return f
+}
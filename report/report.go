@@ -1,34 +1,283 @@
 package report
 
 import (
-	"os"
-	"path/filepath"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/CryptoPulse/models"
 )
 
 // ReportManager manages report storage
 type ReportManager struct {
-	reportDir string
+	backend     Backend
+	subscribers []func(models.Report)
+	deleted     []func(reportID string)
+	// latest is the newest report by workspace and symbol, without its
+	// candles, and series the IDs of every report by workspace and symbol,
+	// oldest first. Both are loaded from storage on first use.
+	latest     map[string]models.Report
+	series     map[string][]seriesEntry
+	seriesOf   map[string]string
+	latestOnce sync.Once
+	mu         sync.RWMutex
 }
 
-// NewReportManager creates a new ReportManager
-func NewReportManager(reportDir string) *ReportManager {
-	if err := os.MkdirAll(reportDir, 0755); err != nil {
-		panic(err)
-	}
-	return &ReportManager{reportDir: reportDir}
+// seriesEntry is a stored report in the series index
+type seriesEntry struct {
+	id        string
+	timestamp int64
+}
+
+// FailedAnalysis records AI responses that could not be turned into a valid report
+type FailedAnalysis struct {
+	AnalysisID string `json:"analysis_id"`
+	Symbol     string `json:"symbol"`
+	// Status is "partial" when the last response was JSON that failed
+	// validation, and "failed" when no JSON could be extracted at all
+	Status       string        `json:"status"`
+	Attempts     int           `json:"attempts"`
+	Problems     []string      `json:"problems"`
+	RawResponses []string      `json:"raw_responses"`
+	Usage        *models.Usage `json:"usage,omitempty"`
+	CreatedAt    int64         `json:"created_at"`
+	OwnerID      string        `json:"owner_id,omitempty"`
+	WorkspaceID  string        `json:"workspace_id,omitempty"`
+	MonitorID    string        `json:"monitor_id,omitempty"`
+}
+
+// NewReportManager creates a ReportManager storing documents in backend
+func NewReportManager(backend Backend) *ReportManager {
+	return &ReportManager{backend: backend}
+}
+
+// Backend returns the storage of the manager
+func (rm *ReportManager) Backend() Backend {
+	return rm.backend
 }
 
-// SaveReport saves a report to disk
+// SaveReport stores a report
 func (rm *ReportManager) SaveReport(reportID, content string) error {
-	filePath := filepath.Join(rm.reportDir, reportID+".json")
-	return os.WriteFile(filePath, []byte(content), 0644)
+	return rm.backend.Put(context.Background(), CollectionReports, reportID, []byte(content))
+}
+
+// Subscribe registers fn to be called with every symbol report after it
+// is stored. fn runs on the saving goroutine and must not block.
+func (rm *ReportManager) Subscribe(fn func(models.Report)) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.subscribers = append(rm.subscribers, fn)
+}
+
+// Publish passes a stored report to the subscribers
+func (rm *ReportManager) Publish(rep models.Report) {
+	rm.loadLatest()
+	rm.mu.Lock()
+	rm.index(rep)
+	subscribers := rm.subscribers
+	rm.mu.Unlock()
+	for _, fn := range subscribers {
+		fn(rep)
+	}
+}
+
+// OnDelete registers fn to be called with the ID of every report deleted
+// through the manager
+func (rm *ReportManager) OnDelete(fn func(reportID string)) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.deleted = append(rm.deleted, fn)
 }
 
-// GetReportPath returns the file path for a report
-func (rm *ReportManager) GetReportPath(reportID string) (string, bool) {
-	filePath := filepath.Join(rm.reportDir, reportID+".json")
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return "", false
+// DeleteReport removes a stored report, or returns ErrNotFound. A deleted
+// latest report leaves its symbol without one, so the next report of the
+// symbol has no changes attached.
+func (rm *ReportManager) DeleteReport(reportID string) error {
+	if err := rm.backend.Delete(context.Background(), CollectionReports, reportID); err != nil {
+		return err
 	}
-	return filePath, true
+	rm.mu.Lock()
+	if key, ok := rm.seriesOf[reportID]; ok {
+		if rm.latest[key].ReportID == reportID {
+			delete(rm.latest, key)
+		}
+		rm.series[key] = slices.DeleteFunc(rm.series[key], func(e seriesEntry) bool { return e.id == reportID })
+		delete(rm.seriesOf, reportID)
+	}
+	deleted := rm.deleted
+	rm.mu.Unlock()
+	for _, fn := range deleted {
+		fn(reportID)
+	}
+	return nil
+}
+
+// DeleteFailed removes a stored failed analysis, or returns ErrNotFound
+func (rm *ReportManager) DeleteFailed(analysisID string) error {
+	return rm.backend.Delete(context.Background(), CollectionFailed, analysisID)
+}
+
+// Latest returns the newest stored report of a symbol in a workspace
+func (rm *ReportManager) Latest(workspaceID, symbol string) (models.Report, bool) {
+	rm.loadLatest()
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	rep, ok := rm.latest[workspaceID+"/"+symbol]
+	return rep, ok
+}
+
+// Previous returns the newest stored report of rep's symbol and workspace
+// that is older than rep
+func (rm *ReportManager) Previous(rep models.Report) (models.Report, bool, error) {
+	rm.loadLatest()
+	rm.mu.RLock()
+	series := rm.series[seriesKey(rep)]
+	i := sort.Search(len(series), func(i int) bool { return series[i].timestamp >= rep.Timestamp })
+	var prevID string
+	if i > 0 {
+		prevID = series[i-1].id
+	}
+	rm.mu.RUnlock()
+	if prevID == "" {
+		return models.Report{}, false, nil
+	}
+	data, err := rm.Report(prevID)
+	if errors.Is(err, ErrNotFound) {
+		// Deleted since it was looked up
+		return models.Report{}, false, nil
+	}
+	if err != nil {
+		return models.Report{}, false, err
+	}
+	prev, err := Decode(data)
+	if err != nil {
+		return models.Report{}, false, err
+	}
+	return prev, true, nil
+}
+
+// loadLatest fills the indexes from the stored reports once, unless
+// Reports has already done so
+func (rm *ReportManager) loadLatest() {
+	rm.latestOnce.Do(func() {
+		// Unreadable reports are left out; the indexes only feed change summaries
+		reports, _ := rm.readReports()
+		rm.fillIndex(reports)
+	})
+}
+
+// fillIndex builds the indexes from every stored report
+func (rm *ReportManager) fillIndex(reports []models.Report) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.latest = make(map[string]models.Report)
+	rm.series = make(map[string][]seriesEntry)
+	rm.seriesOf = make(map[string]string, len(reports))
+	for _, rep := range reports {
+		rm.index(rep)
+	}
+}
+
+func seriesKey(rep models.Report) string {
+	return rep.WorkspaceID + "/" + rep.Symbol
+}
+
+// index adds rep to the series of its symbol, and to the latest index if it
+// is the newest of them; callers hold rm.mu
+func (rm *ReportManager) index(rep models.Report) {
+	key := seriesKey(rep)
+	if _, ok := rm.seriesOf[rep.ReportID]; !ok {
+		series := rm.series[key]
+		i := sort.Search(len(series), func(i int) bool { return series[i].timestamp > rep.Timestamp })
+		rm.series[key] = slices.Insert(series, i, seriesEntry{id: rep.ReportID, timestamp: rep.Timestamp})
+		rm.seriesOf[rep.ReportID] = key
+	}
+	if cur, ok := rm.latest[key]; ok && cur.Timestamp > rep.Timestamp {
+		return
+	}
+	rep.Klines, rep.Changes = nil, nil
+	rm.latest[key] = rep
+}
+
+// Report returns a stored report, or ErrNotFound
+func (rm *ReportManager) Report(reportID string) ([]byte, error) {
+	return rm.backend.Get(context.Background(), CollectionReports, reportID)
+}
+
+// Reports reads every stored symbol report; basket reports, reports of
+// ensemble members and documents that cannot be parsed are skipped. The
+// first successful call also builds the indexes behind Latest and Previous.
+func (rm *ReportManager) Reports() ([]models.Report, error) {
+	reports, err := rm.readReports()
+	if err != nil {
+		return nil, err
+	}
+	rm.latestOnce.Do(func() { rm.fillIndex(reports) })
+	return reports, nil
+}
+
+func (rm *ReportManager) readReports() ([]models.Report, error) {
+	ctx := context.Background()
+	ids, err := rm.backend.List(ctx, CollectionReports)
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]models.Report, 0, len(ids))
+	for _, id := range ids {
+		data, err := rm.backend.Get(ctx, CollectionReports, id)
+		if errors.Is(err, ErrNotFound) {
+			// Deleted since it was listed
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var rep struct {
+			models.Report
+			Kind string `json:"kind"`
+		}
+		if json.Unmarshal(data, &rep) != nil || rep.Kind == "basket" || rep.ReportID == "" || rep.EnsembleMemberOf != "" {
+			continue
+		}
+		reports = append(reports, rep.Report)
+	}
+	return reports, nil
+}
+
+// SaveFailed saves a failed or partial analysis for later inspection
+func (rm *ReportManager) SaveFailed(failed FailedAnalysis) error {
+	data, err := json.MarshalIndent(failed, "", "  ")
+	if err != nil {
+		return err
+	}
+	return rm.backend.Put(context.Background(), CollectionFailed, failed.AnalysisID, data)
+}
+
+// Check verifies that the storage backend is usable
+func (rm *ReportManager) Check() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return rm.backend.Check(ctx)
+}
+
+// Failed returns a stored failed analysis, or ErrNotFound
+func (rm *ReportManager) Failed(analysisID string) ([]byte, error) {
+	return rm.backend.Get(context.Background(), CollectionFailed, analysisID)
+}
+
+// Meta identifies who a stored report or failed analysis belongs to
+type Meta struct {
+	OwnerID     string `json:"owner_id"`
+	WorkspaceID string `json:"workspace_id"`
+}
+
+// ParseMeta returns the ownership of a stored report or failed analysis
+func ParseMeta(data []byte) (Meta, error) {
+	var meta Meta
+	err := json.Unmarshal(data, &meta)
+	return meta, err
 }
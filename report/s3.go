@@ -0,0 +1,289 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/tracing"
+)
+
+// emptySHA256 is the payload hash of requests without a body
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Backend keeps documents as objects <prefix><collection>/<id>.json of
+// an S3-compatible bucket, signing requests with AWS Signature Version 4
+type S3Backend struct {
+	cfg    config.S3Config
+	client *resty.Client
+	now    func() time.Time
+}
+
+// NewS3Backend creates a backend for the configured bucket. Requests go
+// through proxy when it is set.
+func NewS3Backend(cfg config.S3Config, proxy config.URL) *S3Backend {
+	base := &http.Transport{}
+	if proxy.IsSet() {
+		base.Proxy = http.ProxyURL(proxy.URL)
+	}
+	b := &S3Backend{cfg: cfg, now: time.Now}
+	b.client = resty.New().
+		SetTransport(tracing.Transport(base)).
+		SetTimeout(30 * time.Second).
+		SetRetryCount(2).
+		SetRetryWaitTime(time.Second).
+		SetPreRequestHook(func(_ *resty.Client, req *http.Request) error {
+			b.sign(req)
+			return nil
+		})
+	return b
+}
+
+// Name returns "s3"
+func (b *S3Backend) Name() string {
+	return "s3"
+}
+
+// bucketURL returns the URL of the bucket with path appended
+func (b *S3Backend) bucketURL(key string) string {
+	u := *b.cfg.Endpoint.URL
+	if b.cfg.PathStyle {
+		u.Path = path.Join("/", u.Path, b.cfg.Bucket, key)
+	} else {
+		u.Host = b.cfg.Bucket + "." + u.Host
+		u.Path = path.Join("/", u.Path, key)
+	}
+	if strings.HasSuffix(key, "/") || key == "" {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/"
+	}
+	return u.String()
+}
+
+func (b *S3Backend) key(collection, id string) string {
+	return b.cfg.Prefix + collection + "/" + id + ".json"
+}
+
+// Put uploads a document
+func (b *S3Backend) Put(ctx context.Context, collection, id string, data []byte) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	resp, err := b.client.R().SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("X-Amz-Content-Sha256", hex.EncodeToString(sum[:])).
+		SetBody(data).
+		Put(b.bucketURL(b.key(collection, id)))
+	return s3Error(resp, err)
+}
+
+// Get downloads a document
+func (b *S3Backend) Get(ctx context.Context, collection, id string) ([]byte, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	resp, err := b.client.R().SetContext(ctx).Get(b.bucketURL(b.key(collection, id)))
+	if err := s3Error(resp, err); err != nil {
+		return nil, err
+	}
+	return resp.Body(), nil
+}
+
+// listResult is the part of a ListObjectsV2 response the backend reads
+type listResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List pages through the objects of a collection
+func (b *S3Backend) List(ctx context.Context, collection string) ([]string, error) {
+	prefix := b.cfg.Prefix + collection + "/"
+	var ids []string
+	token := ""
+	for {
+		req := b.client.R().SetContext(ctx).
+			SetQueryParam("list-type", "2").
+			SetQueryParam("prefix", prefix)
+		if token != "" {
+			req.SetQueryParam("continuation-token", token)
+		}
+		resp, err := req.Get(b.bucketURL(""))
+		if err := s3Error(resp, err); err != nil {
+			return nil, err
+		}
+		var page listResult
+		if err := xml.Unmarshal(resp.Body(), &page); err != nil {
+			return nil, fmt.Errorf("parse object list: %w", err)
+		}
+		for _, obj := range page.Contents {
+			name := strings.TrimPrefix(obj.Key, prefix)
+			if id, ok := strings.CutSuffix(name, ".json"); ok && validID.MatchString(id) {
+				ids = append(ids, id)
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return ids, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// Delete removes a document. S3 reports success for missing keys, so it
+// checks for the object first.
+func (b *S3Backend) Delete(ctx context.Context, collection, id string) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+	key := b.bucketURL(b.key(collection, id))
+	if err := s3Error(b.client.R().SetContext(ctx).Head(key)); err != nil {
+		return err
+	}
+	return s3Error(b.client.R().SetContext(ctx).Delete(key))
+}
+
+// Check verifies that the bucket exists and the credentials may use it
+func (b *S3Backend) Check(ctx context.Context) error {
+	return s3Error(b.client.R().SetContext(ctx).Head(b.bucketURL("")))
+}
+
+// Close does nothing
+func (b *S3Backend) Close() error {
+	return nil
+}
+
+func s3Error(resp *resty.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode() == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode() >= http.StatusBadRequest:
+		return fmt.Errorf("s3 %s %s: status %d: %s", resp.Request.Method, resp.Request.RawRequest.URL.Path, resp.StatusCode(), bytes.TrimSpace(resp.Body()))
+	}
+	return nil
+}
+
+// sign adds the AWS Signature Version 4 authorization of an S3 request.
+// Requests are left unsigned without credentials, for public buckets.
+func (b *S3Backend) sign(req *http.Request) {
+	payload := req.Header.Get("X-Amz-Content-Sha256")
+	if payload == "" {
+		payload = emptySHA256
+		req.Header.Set("X-Amz-Content-Sha256", payload)
+	}
+	if b.cfg.AccessKey == "" {
+		return
+	}
+	now := b.now().UTC()
+	stamp := now.Format("20060102T150405Z")
+	day := stamp[:8]
+	req.Header.Set("X-Amz-Date", stamp)
+
+	names := []string{"host"}
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") || lower == "content-type" || lower == "range" {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	var headers strings.Builder
+	for _, name := range names {
+		value := req.Host
+		if name != "host" {
+			value = strings.TrimSpace(req.Header.Get(name))
+		} else if value == "" {
+			value = req.URL.Host
+		}
+		headers.WriteString(name + ":" + value + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL),
+		canonicalQuery(req.URL.Query()),
+		headers.String(),
+		signed,
+		payload,
+	}, "\n")
+	scope := day + "/" + b.cfg.SigningRegion() + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+b.cfg.SecretKey), day)
+	key = hmacSHA256(key, b.cfg.SigningRegion())
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.cfg.AccessKey, scope, signed, signature))
+}
+
+// canonicalPath URI-encodes each path segment once, as S3 expects
+func canonicalPath(u *url.URL) string {
+	p := u.EscapedPath()
+	if p == "" {
+		return "/"
+	}
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		if unescaped, err := url.PathUnescape(s); err == nil {
+			segments[i] = uriEncode(unescaped)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery sorts and URI-encodes the query parameters
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but the unreserved characters
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,119 @@
+//go:build cgo
+
+package report
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS documents (
+	collection TEXT NOT NULL,
+	id         TEXT NOT NULL,
+	data       BLOB NOT NULL,
+	updated_at INTEGER NOT NULL,
+	PRIMARY KEY (collection, id)
+)`
+
+// SQLiteBackend keeps documents in one table of a SQLite database
+type SQLiteBackend struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens or creates the database at path
+func OpenSQLite(path string) (*SQLiteBackend, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	// WAL lets the API read while a monitor writes; the busy timeout covers
+	// writers queuing behind each other
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteBackend{db: db}, nil
+}
+
+// Name returns "sqlite"
+func (b *SQLiteBackend) Name() string {
+	return "sqlite"
+}
+
+// Put inserts or replaces a document
+func (b *SQLiteBackend) Put(ctx context.Context, collection, id string, data []byte) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+	_, err := b.db.ExecContext(ctx,
+		`INSERT INTO documents (collection, id, data, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (collection, id) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`,
+		collection, id, data, time.Now().UnixMilli())
+	return err
+}
+
+// Get reads a document
+func (b *SQLiteBackend) Get(ctx context.Context, collection, id string) ([]byte, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	var data []byte
+	err := b.db.QueryRowContext(ctx, `SELECT data FROM documents WHERE collection = ? AND id = ?`, collection, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// List returns the IDs of a collection
+func (b *SQLiteBackend) List(ctx context.Context, collection string) ([]string, error) {
+	rows, err := b.db.QueryContext(ctx, `SELECT id FROM documents WHERE collection = ?`, collection)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Delete removes a document
+func (b *SQLiteBackend) Delete(ctx context.Context, collection, id string) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+	res, err := b.db.ExecContext(ctx, `DELETE FROM documents WHERE collection = ? AND id = ?`, collection, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Check pings the database
+func (b *SQLiteBackend) Check(ctx context.Context) error {
+	return b.db.PingContext(ctx)
+}
+
+// Close closes the database
+func (b *SQLiteBackend) Close() error {
+	return b.db.Close()
+}
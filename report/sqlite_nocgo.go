@@ -0,0 +1,10 @@
+//go:build !cgo
+
+package report
+
+import "errors"
+
+// OpenSQLite fails in builds without cgo, which the SQLite driver needs
+func OpenSQLite(path string) (Backend, error) {
+	return nil, errors.New("sqlite storage is unavailable: this binary was built with CGO_ENABLED=0")
+}
@@ -0,0 +1,194 @@
+package report
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/songzhibin97/CryptoPulse/models"
+)
+
+//go:embed schema.json
+var schemaJSON []byte
+
+// Schema returns the JSON schema that AI responses must follow
+func Schema() json.RawMessage {
+	return json.RawMessage(schemaJSON)
+}
+
+// ValidationError lists the problems found in an AI response
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid report: " + strings.Join(e.Problems, "; ")
+}
+
+// Parse extracts the JSON object from an AI response, validates it against
+// the report schema and decodes it into a typed report
+func Parse(raw string) (models.Report, error) {
+	var rep models.Report
+	body := ExtractJSON(raw)
+	if err := validateSchema(schemaJSON, body); err != nil {
+		return rep, err
+	}
+
+	if err := json.Unmarshal([]byte(body), &rep); err != nil {
+		return rep, &ValidationError{Problems: []string{fmt.Sprintf("decode report failed: %v", err)}}
+	}
+	var problems []string
+	for i, l := range rep.TechnicalAnalysis.SupportResistance {
+		checkPrice(l.Price, fmt.Sprintf("$.technical_analysis.support_resistance[%d].price", i), &problems)
+	}
+	for i, l := range rep.OrderBook.SupportResistance {
+		checkPrice(l.Price, fmt.Sprintf("$.order_book.support_resistance[%d].price", i), &problems)
+	}
+	if len(problems) > 0 {
+		return rep, &ValidationError{Problems: problems}
+	}
+	return rep, nil
+}
+
+// validateSchema checks a JSON document against a schema, returning a
+// *ValidationError listing every problem
+func validateSchema(schemaDoc []byte, body string) error {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		return &ValidationError{Problems: []string{fmt.Sprintf("response is not valid JSON: %v", err)}}
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(schemaDoc, &schema); err != nil {
+		return fmt.Errorf("load report schema failed: %w", err)
+	}
+	var problems []string
+	validateNode(schema, doc, "$", &problems)
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// ExtractJSON strips markdown code fences and surrounding prose from an AI
+// response, returning the outermost JSON object
+func ExtractJSON(raw string) string {
+	s := strings.TrimSpace(raw)
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s, "```")
+		s = strings.TrimPrefix(s, "json")
+		s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+		s = strings.TrimSpace(s)
+	}
+	if start, end := strings.Index(s, "{"), strings.LastIndex(s, "}"); start >= 0 && end > start {
+		return s[start : end+1]
+	}
+	return s
+}
+
+func checkPrice(price, path string, problems *[]string) {
+	if f, err := strconv.ParseFloat(price, 64); err != nil || f <= 0 {
+		*problems = append(*problems, fmt.Sprintf("%s: %q is not a positive number", path, price))
+	}
+}
+
+// validateNode checks value against the subset of JSON schema used by
+// schema.json: type, enum, required, properties, additionalProperties,
+// items, minimum and maximum
+func validateNode(schema map[string]interface{}, value interface{}, path string, problems *[]string) {
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		for _, e := range enum {
+			if e == value {
+				return
+			}
+		}
+		*problems = append(*problems, fmt.Sprintf("%s: must be one of %v, got %v", path, enum, value))
+		return
+	}
+	if t, ok := schema["type"]; ok && !matchesType(t, value) {
+		*problems = append(*problems, fmt.Sprintf("%s: expected %v, got %s", path, t, jsonType(value)))
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, r := range required {
+				if _, ok := v[r.(string)]; !ok {
+					*problems = append(*problems, fmt.Sprintf("%s.%s: is required", path, r))
+				}
+			}
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		additional, _ := schema["additionalProperties"].(map[string]interface{})
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if sub, ok := props[k].(map[string]interface{}); ok {
+				validateNode(sub, v[k], path+"."+k, problems)
+			} else if additional != nil {
+				validateNode(additional, v[k], path+"."+k, problems)
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validateNode(items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && v < min {
+			*problems = append(*problems, fmt.Sprintf("%s: %v is below minimum %v", path, v, min))
+		}
+		if max, ok := schema["maximum"].(float64); ok && v > max {
+			*problems = append(*problems, fmt.Sprintf("%s: %v is above maximum %v", path, v, max))
+		}
+	}
+}
+
+func matchesType(t interface{}, value interface{}) bool {
+	switch t := t.(type) {
+	case string:
+		switch t {
+		case "integer":
+			f, ok := value.(float64)
+			return ok && f == math.Trunc(f)
+		case "number":
+			_, ok := value.(float64)
+			return ok
+		default:
+			return jsonType(value) == t
+		}
+	case []interface{}:
+		for _, alt := range t {
+			if matchesType(alt, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
@@ -0,0 +1,98 @@
+package retention
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// bundleFile is a document added to an archive bundle
+type bundleFile struct {
+	name string
+	data []byte
+}
+
+// BundleName returns the archive bundle of the UTC day of t
+func BundleName(t time.Time) string {
+	return "reports-" + t.UTC().Format("2006-01-02") + ".tar.gz"
+}
+
+// appendBundle adds files to the bundle of now's day in dir. The bundle is
+// rewritten through a temporary file, so a failed write leaves the previous
+// bundle intact.
+func appendBundle(dir string, now time.Time, files []bundleFile) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, BundleName(now))
+	tmp, err := os.CreateTemp(dir, ".bundle-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	gz := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(gz)
+	if err := copyBundle(path, tw); err != nil {
+		return "", err
+	}
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.data)), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return "", err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return "", err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// copyBundle writes the entries of an existing bundle to tw
+func copyBundle(path string, tw *tar.Writer) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
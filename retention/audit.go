@@ -0,0 +1,86 @@
+package retention
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Deletion is an entry of the audit log: a deleted document, who deleted
+// it and why
+type Deletion struct {
+	// Time is when the document was deleted, in Unix milliseconds; it is
+	// unset on dry runs
+	Time int64 `json:"deleted_at,omitempty"`
+	Doc
+	// Actor is the name of the user who deleted the document, or
+	// "retention" for sweeps
+	Actor  string `json:"actor"`
+	Reason string `json:"reason"`
+	// Archive is the bundle holding a copy of the document
+	Archive string `json:"archive,omitempty"`
+}
+
+// appendAudit appends deletions to the audit log at path
+func appendAudit(path string, deletions []Deletion) error {
+	if len(deletions) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, d := range deletions {
+		if err := enc.Encode(d); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Deletions reads the audit log, newest first. Entries that do not match f
+// are left out, and at most limit are returned when limit is positive.
+func (m *Manager) Deletions(f Filter, limit int) ([]Deletion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	file, err := os.Open(m.store.Get().Retention.AuditPath())
+	if os.IsNotExist(err) {
+		return []Deletion{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var all []Deletion
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var d Deletion
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			return nil, fmt.Errorf("parse audit entry failed: %w", err)
+		}
+		if f.Match(d.Doc) {
+			all = append(all, d)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	out := make([]Deletion, 0, len(all))
+	for i := len(all) - 1; i >= 0 && (limit <= 0 || len(out) < limit); i-- {
+		out = append(out, all[i])
+	}
+	return out, nil
+}
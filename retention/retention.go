@@ -0,0 +1,376 @@
+package retention
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/metrics"
+	"github.com/songzhibin97/CryptoPulse/report"
+)
+
+// Kinds of stored documents
+const (
+	KindReport = "report"
+	KindBasket = "basket"
+	KindFailed = "failed"
+)
+
+// Reasons recorded for deletions
+const (
+	ReasonAPI      = "api"
+	ReasonBulk     = "bulk"
+	ReasonKeepDays = "keep_days"
+	ReasonKeepLast = "keep_last"
+)
+
+// ActorRetention is the actor recorded for deletions made by sweeps
+const ActorRetention = "retention"
+
+// Doc is what retention knows about a stored report or failed analysis
+type Doc struct {
+	Collection  string `json:"collection"`
+	ID          string `json:"id"`
+	Kind        string `json:"kind"`
+	Symbol      string `json:"symbol,omitempty"`
+	Watchlist   string `json:"watchlist,omitempty"`
+	MonitorID   string `json:"monitor_id,omitempty"`
+	OwnerID     string `json:"owner_id,omitempty"`
+	WorkspaceID string `json:"workspace_id,omitempty"`
+	// Time is when the report was made or the analysis failed, in Unix
+	// milliseconds
+	Time int64 `json:"time"`
+}
+
+// Parse reads the metadata of a stored document
+func Parse(collection, id string, data []byte) (Doc, error) {
+	var raw struct {
+		Kind        string `json:"kind"`
+		Symbol      string `json:"symbol"`
+		Watchlist   string `json:"watchlist"`
+		MonitorID   string `json:"monitor_id"`
+		OwnerID     string `json:"owner_id"`
+		WorkspaceID string `json:"workspace_id"`
+		Timestamp   int64  `json:"timestamp"`
+		CreatedAt   int64  `json:"created_at"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Doc{}, fmt.Errorf("parse %s/%s: %w", collection, id, err)
+	}
+	d := Doc{
+		Collection:  collection,
+		ID:          id,
+		Kind:        KindReport,
+		Symbol:      raw.Symbol,
+		Watchlist:   raw.Watchlist,
+		MonitorID:   raw.MonitorID,
+		OwnerID:     raw.OwnerID,
+		WorkspaceID: raw.WorkspaceID,
+		Time:        raw.Timestamp,
+	}
+	switch {
+	case collection == report.CollectionFailed:
+		d.Kind, d.Time = KindFailed, raw.CreatedAt
+	case raw.Kind == KindBasket:
+		d.Kind = KindBasket
+	}
+	return d, nil
+}
+
+// series groups the reports keep_last counts together: those of one
+// monitor, or of one symbol or watchlist in a workspace for reports that
+// did not record their monitor
+func (d Doc) series() string {
+	if d.MonitorID != "" {
+		return "monitor/" + d.MonitorID
+	}
+	if d.Kind == KindBasket {
+		return d.WorkspaceID + "/basket/" + d.Watchlist
+	}
+	return d.WorkspaceID + "/" + d.Symbol
+}
+
+// Filter selects documents for bulk deletion. Documents must belong to
+// WorkspaceID unless All is set; other zero fields match everything.
+type Filter struct {
+	// All matches documents of every workspace, for admins
+	All         bool
+	WorkspaceID string
+	// OwnerID also matches documents from before workspaces existed that
+	// this user owns
+	OwnerID   string
+	Symbol    string
+	MonitorID string
+	Kind      string
+	// Before and After bound the document time in Unix milliseconds:
+	// before is exclusive, after inclusive
+	Before int64
+	After  int64
+}
+
+// Match reports whether a document passes the filter
+func (f Filter) Match(d Doc) bool {
+	return f.matchWorkspace(d) &&
+		(f.Symbol == "" || d.Symbol == f.Symbol) &&
+		(f.MonitorID == "" || d.MonitorID == f.MonitorID) &&
+		(f.Kind == "" || d.Kind == f.Kind) &&
+		(f.Before == 0 || d.Time < f.Before) &&
+		(f.After == 0 || d.Time >= f.After)
+}
+
+// matchWorkspace reports whether a document belongs to the filter's
+// workspace. An empty WorkspaceID matches nothing without All.
+func (f Filter) matchWorkspace(d Doc) bool {
+	switch {
+	case f.All:
+		return true
+	case f.WorkspaceID == "":
+		return false
+	case d.WorkspaceID == "":
+		return f.OwnerID != "" && d.OwnerID == f.OwnerID
+	}
+	return d.WorkspaceID == f.WorkspaceID
+}
+
+// Result is the outcome of a deletion or sweep
+type Result struct {
+	StartedAt int64 `json:"started_at"`
+	DryRun    bool  `json:"dry_run,omitempty"`
+	// Deleted lists the documents removed, or those that would be on a dry
+	// run
+	Deleted []Deletion `json:"deleted"`
+	// Archive is the bundle the documents were copied to
+	Archive string `json:"archive,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Manager deletes stored documents, archiving and auditing each deletion,
+// and periodically applies the configured retention policies
+type Manager struct {
+	store   *config.Store
+	reports *report.ReportManager
+	logger  zerolog.Logger
+	last    *Result
+	// mu serializes deletions so bundles and the audit log are written by
+	// one of them at a time
+	mu sync.Mutex
+}
+
+// New creates a Manager for the documents of reports
+func New(store *config.Store, reports *report.ReportManager, logger zerolog.Logger) *Manager {
+	return &Manager{store: store, reports: reports, logger: logger}
+}
+
+// Run sweeps at the configured interval while retention is enabled, until
+// ctx ends. Config changes apply from the next sweep.
+func (m *Manager) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		cfg := m.store.Get().Retention
+		if cfg.Enabled {
+			if _, err := m.Sweep(ctx, false); err != nil && ctx.Err() == nil {
+				m.logger.Error().Err(err).Msg("Retention sweep failed")
+			}
+		}
+		timer.Reset(cfg.Every())
+	}
+}
+
+// Last returns the result of the last sweep that deleted documents, or
+// nil before the first
+func (m *Manager) Last() *Result {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+// Docs reads the metadata of every stored document. Documents that cannot
+// be parsed are skipped, so a corrupt one is never deleted by a policy.
+func (m *Manager) Docs(ctx context.Context) ([]Doc, error) {
+	backend := m.reports.Backend()
+	var docs []Doc
+	for _, collection := range []string{report.CollectionReports, report.CollectionFailed} {
+		ids, err := backend.List(ctx, collection)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			data, err := backend.Get(ctx, collection, id)
+			if errors.Is(err, report.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			d, err := Parse(collection, id, data)
+			if err != nil {
+				m.logger.Warn().Err(err).Msg("Skipping unreadable document")
+				continue
+			}
+			docs = append(docs, d)
+		}
+	}
+	return docs, nil
+}
+
+// Find returns the documents matching f, newest first
+func (m *Manager) Find(ctx context.Context, f Filter) ([]Doc, error) {
+	docs, err := m.Docs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	docs = slices.DeleteFunc(docs, func(d Doc) bool { return !f.Match(d) })
+	slices.SortFunc(docs, func(a, b Doc) int { return cmp.Compare(b.Time, a.Time) })
+	return docs, nil
+}
+
+// Due returns the documents the policies of cfg delete at now, by reason
+func Due(docs []Doc, cfg config.RetentionConfig, now time.Time) map[string][]Doc {
+	due := make(map[string][]Doc)
+	series := make(map[string][]Doc)
+	for _, d := range docs {
+		policy := cfg.Policy(d.WorkspaceID, d.Symbol)
+		if policy.KeepDays > 0 && d.Time > 0 && d.Time < now.AddDate(0, 0, -policy.KeepDays).UnixMilli() {
+			due[ReasonKeepDays] = append(due[ReasonKeepDays], d)
+			continue
+		}
+		if d.Kind != KindFailed {
+			series[d.series()] = append(series[d.series()], d)
+		}
+	}
+	for _, docs := range series {
+		slices.SortFunc(docs, func(a, b Doc) int { return cmp.Compare(b.Time, a.Time) })
+		for i, d := range docs {
+			if keep := cfg.Policy(d.WorkspaceID, d.Symbol).KeepLast; keep > 0 && i >= keep {
+				due[ReasonKeepLast] = append(due[ReasonKeepLast], d)
+			}
+		}
+	}
+	return due
+}
+
+// Sweep applies the retention policies now. A dry run returns what would
+// be deleted without deleting it.
+func (m *Manager) Sweep(ctx context.Context, dryRun bool) (Result, error) {
+	start := time.Now()
+	res := Result{StartedAt: start.UnixMilli(), DryRun: dryRun, Deleted: []Deletion{}}
+	docs, err := m.Docs(ctx)
+	if err != nil {
+		res.Error = err.Error()
+		return res, err
+	}
+	due := Due(docs, m.store.Get().Retention, start)
+	for _, reason := range []string{ReasonKeepDays, ReasonKeepLast} {
+		if len(due[reason]) == 0 {
+			continue
+		}
+		if dryRun {
+			for _, d := range due[reason] {
+				res.Deleted = append(res.Deleted, Deletion{Doc: d, Actor: ActorRetention, Reason: reason})
+			}
+			continue
+		}
+		out, err := m.Delete(ctx, due[reason], ActorRetention, reason)
+		res.Deleted = append(res.Deleted, out.Deleted...)
+		if out.Archive != "" {
+			res.Archive = out.Archive
+		}
+		if err != nil {
+			res.Error = err.Error()
+			return res, err
+		}
+	}
+	if !dryRun {
+		m.logger.Info().
+			Int("documents", len(docs)).
+			Int("deleted", len(res.Deleted)).
+			Dur("duration_ms", time.Since(start)).
+			Msg("Retention sweep finished")
+		if len(res.Deleted) > 0 {
+			m.mu.Lock()
+			m.last = &res
+			m.mu.Unlock()
+		}
+	}
+	return res, nil
+}
+
+// Delete removes docs, copying them into the day's archive bundle first
+// when archiving is enabled, and records each deletion in the audit log.
+// Documents that are already gone are skipped. Nothing is deleted when
+// the archive cannot be written.
+func (m *Manager) Delete(ctx context.Context, docs []Doc, actor, reason string) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cfg := m.store.Get().Retention
+	now := time.Now()
+	res := Result{StartedAt: now.UnixMilli(), Deleted: []Deletion{}}
+
+	backend := m.reports.Backend()
+	files := make([]bundleFile, 0, len(docs))
+	present := make([]Doc, 0, len(docs))
+	for _, d := range docs {
+		data, err := backend.Get(ctx, d.Collection, d.ID)
+		if errors.Is(err, report.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+		files = append(files, bundleFile{name: d.Collection + "/" + d.ID + ".json", data: data})
+		present = append(present, d)
+	}
+	if len(present) == 0 {
+		return res, nil
+	}
+	if cfg.Archive {
+		path, err := appendBundle(cfg.ArchivePath(), now, files)
+		if err != nil {
+			return res, fmt.Errorf("archive documents: %w", err)
+		}
+		res.Archive = path
+	}
+
+	var errs []error
+	for _, d := range present {
+		var err error
+		if d.Collection == report.CollectionFailed {
+			err = m.reports.DeleteFailed(d.ID)
+		} else {
+			err = m.reports.DeleteReport(d.ID)
+		}
+		if err != nil && !errors.Is(err, report.ErrNotFound) {
+			errs = append(errs, fmt.Errorf("delete %s/%s: %w", d.Collection, d.ID, err))
+			continue
+		}
+		res.Deleted = append(res.Deleted, Deletion{Time: now.UnixMilli(), Doc: d, Actor: actor, Reason: reason, Archive: res.Archive})
+	}
+	if err := appendAudit(cfg.AuditPath(), res.Deleted); err != nil {
+		errs = append(errs, fmt.Errorf("write audit log: %w", err))
+	}
+	metrics.CountDeletedReports(reason, len(res.Deleted))
+	m.logger.Info().
+		Str("actor", actor).
+		Str("reason", reason).
+		Int("deleted", len(res.Deleted)).
+		Str("archive", res.Archive).
+		Msg("Deleted reports")
+	err := errors.Join(errs...)
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res, err
+}
@@ -0,0 +1,430 @@
+package retention
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/report"
+)
+
+// now is the time the policies are applied at in TestDue
+var now = time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)
+
+// daysAgo returns a document time the given number of days before t
+func daysAgo(t time.Time, days float64) int64 {
+	return t.Add(-time.Duration(days * float64(24*time.Hour))).UnixMilli()
+}
+
+// ids returns the sorted "collection/id" names of docs
+func ids(docs []Doc) []string {
+	out := make([]string, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.Collection+"/"+d.ID)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestDue(t *testing.T) {
+	report1 := func(id, monitor, ws, symbol string, days float64) Doc {
+		return Doc{Collection: report.CollectionReports, ID: id, Kind: KindReport, MonitorID: monitor, WorkspaceID: ws, Symbol: symbol, Time: daysAgo(now, days)}
+	}
+	docs := []Doc{
+		// Monitor m1 keeps its newest 2; r1 is also past keep_days
+		report1("r1", "m1", "ws1", "BTCUSDT", 10),
+		report1("r2", "m1", "ws1", "BTCUSDT", 4),
+		report1("r3", "m1", "ws1", "BTCUSDT", 3),
+		report1("r4", "m1", "ws1", "BTCUSDT", 2),
+		report1("r5", "m1", "ws1", "BTCUSDT", 1),
+		// Another monitor of the same symbol is a series of its own
+		report1("r6", "m2", "ws1", "BTCUSDT", 3),
+		report1("r7", "m2", "ws1", "BTCUSDT", 2),
+		// Reports without a monitor are grouped by workspace and symbol
+		report1("r8", "", "ws1", "SOLUSDT", 3),
+		report1("r9", "", "ws1", "SOLUSDT", 2),
+		report1("r10", "", "ws1", "SOLUSDT", 1),
+		report1("r11", "", "ws2", "SOLUSDT", 3),
+		// The ETHUSDT rule keeps only the newest report but keeps them forever
+		report1("r12", "m3", "ws1", "ETHUSDT", 30),
+		report1("r13", "m3", "ws1", "ETHUSDT", 20),
+		// Workspace ws3 keeps everything
+		report1("r14", "m4", "ws3", "BTCUSDT", 100),
+		report1("r15", "m4", "ws3", "BTCUSDT", 50),
+		report1("r16", "m4", "ws3", "BTCUSDT", 40),
+		// Baskets are grouped by watchlist
+		{Collection: report.CollectionReports, ID: "b1", Kind: KindBasket, WorkspaceID: "ws1", Watchlist: "l1", Time: daysAgo(now, 3)},
+		{Collection: report.CollectionReports, ID: "b2", Kind: KindBasket, WorkspaceID: "ws1", Watchlist: "l1", Time: daysAgo(now, 2)},
+		{Collection: report.CollectionReports, ID: "b3", Kind: KindBasket, WorkspaceID: "ws1", Watchlist: "l1", Time: daysAgo(now, 1)},
+		{Collection: report.CollectionReports, ID: "b4", Kind: KindBasket, WorkspaceID: "ws1", Watchlist: "l2", Time: daysAgo(now, 3)},
+		// Failed analyses only expire, however many there are
+		{Collection: report.CollectionFailed, ID: "f1", Kind: KindFailed, MonitorID: "m1", WorkspaceID: "ws1", Symbol: "BTCUSDT", Time: daysAgo(now, 8)},
+		{Collection: report.CollectionFailed, ID: "f2", Kind: KindFailed, MonitorID: "m1", WorkspaceID: "ws1", Symbol: "BTCUSDT", Time: daysAgo(now, 1)},
+		{Collection: report.CollectionFailed, ID: "f3", Kind: KindFailed, MonitorID: "m1", WorkspaceID: "ws1", Symbol: "BTCUSDT", Time: daysAgo(now, 0.5)},
+		// Documents without a time never expire
+		report1("r17", "m5", "ws1", "XRPUSDT", 0),
+	}
+	docs[len(docs)-1].Time = 0
+	cfg := config.RetentionConfig{
+		Default: config.RetentionPolicy{KeepDays: 7, KeepLast: 2},
+		Rules: []config.RetentionRule{
+			{Workspace: "ws3", RetentionPolicy: config.RetentionPolicy{}},
+			{Symbol: "ethusdt", RetentionPolicy: config.RetentionPolicy{KeepLast: 1}},
+		},
+	}
+
+	due := Due(docs, cfg, now)
+	want := map[string][]string{
+		ReasonKeepDays: {"failed/f1", "reports/r1"},
+		ReasonKeepLast: {"reports/b1", "reports/r12", "reports/r2", "reports/r3", "reports/r8"},
+	}
+	for _, reason := range []string{ReasonKeepDays, ReasonKeepLast} {
+		if got := ids(due[reason]); !slices.Equal(got, want[reason]) {
+			t.Errorf("%s: due %v, want %v", reason, got, want[reason])
+		}
+	}
+	if reasons := slices.Sorted(maps.Keys(due)); len(reasons) != 2 {
+		t.Errorf("due reasons %v", reasons)
+	}
+}
+
+func TestDueNothingConfigured(t *testing.T) {
+	docs := []Doc{
+		{Collection: report.CollectionReports, ID: "r1", Kind: KindReport, MonitorID: "m1", Time: daysAgo(now, 1000)},
+		{Collection: report.CollectionFailed, ID: "f1", Kind: KindFailed, Time: daysAgo(now, 1000)},
+	}
+	if due := Due(docs, config.RetentionConfig{}, now); len(due) != 0 {
+		t.Errorf("due without policies: %v", due)
+	}
+}
+
+// fixture is a Manager over a temporary filesystem backend
+type fixture struct {
+	m       *Manager
+	backend *report.FileBackend
+	cfg     config.RetentionConfig
+	// stored holds the content of every document put, by "collection/id"
+	stored map[string][]byte
+}
+
+func newFixture(t *testing.T, policy config.RetentionPolicy) *fixture {
+	t.Helper()
+	dir := t.TempDir()
+	backend, err := report.NewFileBackend(filepath.Join(dir, "reports"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.RetentionConfig{
+		Archive:    true,
+		ArchiveDir: filepath.Join(dir, "archive"),
+		AuditFile:  filepath.Join(dir, "audit.jsonl"),
+		Default:    policy,
+	}
+	store := config.NewStore("", config.Config{Retention: cfg}, zerolog.Nop())
+	m := New(store, report.NewReportManager(backend), zerolog.Nop())
+	return &fixture{m: m, backend: backend, cfg: cfg, stored: make(map[string][]byte)}
+}
+
+// putReport stores a report of monitor made the given days ago
+func (f *fixture) putReport(t *testing.T, id, monitor string, days float64) {
+	t.Helper()
+	f.put(t, report.CollectionReports, id, fmt.Sprintf(
+		`{"report_id":%q,"symbol":"BTCUSDT","monitor_id":%q,"workspace_id":"ws1","timestamp":%d}`,
+		id, monitor, daysAgo(time.Now(), days)))
+}
+
+// putFailed stores a failed analysis made the given days ago
+func (f *fixture) putFailed(t *testing.T, id string, days float64) {
+	t.Helper()
+	f.put(t, report.CollectionFailed, id, fmt.Sprintf(
+		`{"analysis_id":%q,"symbol":"BTCUSDT","status":"failed","workspace_id":"ws1","created_at":%d}`,
+		id, daysAgo(time.Now(), days)))
+}
+
+func (f *fixture) put(t *testing.T, collection, id, data string) {
+	t.Helper()
+	if err := f.backend.Put(context.Background(), collection, id, []byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	f.stored[collection+"/"+id] = []byte(data)
+}
+
+// remaining returns the "collection/id" names of the stored documents
+func (f *fixture) remaining(t *testing.T) []string {
+	t.Helper()
+	docs, err := f.m.Docs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ids(docs)
+}
+
+// bundle reads the entries of an archive bundle by name
+func bundle(t *testing.T, path string) map[string][]byte {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[hdr.Name] = data
+	}
+}
+
+// audit reads the audit log in the order it was written
+func audit(t *testing.T, path string) []Deletion {
+	t.Helper()
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var out []Deletion
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var d Deletion
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			t.Fatalf("audit entry %s: %v", scanner.Text(), err)
+		}
+		out = append(out, d)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// deleted returns the "collection/id" names of deletions
+func deleted(deletions []Deletion) []string {
+	docs := make([]Doc, 0, len(deletions))
+	for _, d := range deletions {
+		docs = append(docs, d.Doc)
+	}
+	return ids(docs)
+}
+
+func TestSweep(t *testing.T) {
+	f := newFixture(t, config.RetentionPolicy{KeepDays: 7, KeepLast: 2})
+	f.putReport(t, "r1", "m1", 10)
+	f.putReport(t, "r2", "m1", 3)
+	f.putReport(t, "r3", "m1", 2)
+	f.putReport(t, "r4", "m1", 1)
+	f.putReport(t, "r5", "m2", 5)
+	f.putFailed(t, "f1", 8)
+	f.putFailed(t, "f2", 1)
+	// Unreadable documents are never deleted
+	f.put(t, report.CollectionReports, "broken", "{")
+	ctx := context.Background()
+
+	wantDeleted := []string{"failed/f1", "reports/r1", "reports/r2"}
+	wantKept := []string{"failed/f2", "reports/r3", "reports/r4", "reports/r5"}
+
+	dry, err := f.m.Sweep(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := deleted(dry.Deleted); !slices.Equal(got, wantDeleted) {
+		t.Errorf("dry run deletes %v, want %v", got, wantDeleted)
+	}
+	if got := f.remaining(t); len(got) != len(wantDeleted)+len(wantKept) {
+		t.Errorf("dry run deleted documents: %v left", got)
+	}
+	if entries := audit(t, f.cfg.AuditPath()); len(entries) != 0 {
+		t.Errorf("dry run audited %d deletions", len(entries))
+	}
+	if _, err := os.Stat(f.cfg.ArchivePath()); !os.IsNotExist(err) {
+		t.Errorf("dry run created the archive: %v", err)
+	}
+
+	res, err := f.m.Sweep(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := deleted(res.Deleted); !slices.Equal(got, wantDeleted) {
+		t.Errorf("sweep deleted %v, want %v", got, wantDeleted)
+	}
+	if got := f.remaining(t); !slices.Equal(got, wantKept) {
+		t.Errorf("left after sweep %v, want %v", got, wantKept)
+	}
+	if _, err := f.backend.Get(ctx, report.CollectionReports, "broken"); err != nil {
+		t.Errorf("unreadable document: %v", err)
+	}
+	for _, d := range res.Deleted {
+		wantReason := ReasonKeepLast
+		if d.ID == "r1" || d.ID == "f1" {
+			wantReason = ReasonKeepDays
+		}
+		if d.Reason != wantReason || d.Actor != ActorRetention {
+			t.Errorf("%s deleted by %s for %s, want retention for %s", d.ID, d.Actor, d.Reason, wantReason)
+		}
+	}
+
+	// The bundle holds exactly the deleted documents as they were stored
+	if filepath.Dir(res.Archive) != f.cfg.ArchivePath() || filepath.Base(res.Archive) != BundleName(time.UnixMilli(res.Deleted[0].Time)) {
+		t.Errorf("archive %s, want %s in %s", res.Archive, BundleName(time.UnixMilli(res.Deleted[0].Time)), f.cfg.ArchivePath())
+	}
+	entries := bundle(t, res.Archive)
+	if got := slices.Sorted(maps.Keys(entries)); !slices.Equal(got, []string{"failed/f1.json", "reports/r1.json", "reports/r2.json"}) {
+		t.Errorf("bundle entries %v", got)
+	}
+	for _, name := range wantDeleted {
+		if got := entries[name+".json"]; string(got) != string(f.stored[name]) {
+			t.Errorf("bundle %s = %s, want %s", name, got, f.stored[name])
+		}
+	}
+
+	// The audit log has one entry per deleted document naming the bundle
+	logged := audit(t, f.cfg.AuditPath())
+	if got := deleted(logged); !slices.Equal(got, wantDeleted) {
+		t.Errorf("audited %v, want %v", got, wantDeleted)
+	}
+	for _, d := range logged {
+		if d.Archive != res.Archive || d.Time == 0 || d.Actor != ActorRetention {
+			t.Errorf("audit entry %+v", d)
+		}
+		if d.WorkspaceID != "ws1" || d.Symbol != "BTCUSDT" {
+			t.Errorf("audit entry %s lost its metadata: %+v", d.ID, d.Doc)
+		}
+	}
+	if last := f.m.Last(); last == nil || !slices.Equal(deleted(last.Deleted), wantDeleted) {
+		t.Errorf("Last = %+v", last)
+	}
+
+	// A second sweep has nothing left to delete
+	again, err := f.m.Sweep(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Deleted) != 0 {
+		t.Errorf("second sweep deleted %v", deleted(again.Deleted))
+	}
+	if n := len(audit(t, f.cfg.AuditPath())); n != len(wantDeleted) {
+		t.Errorf("audit log has %d entries after a second sweep, want %d", n, len(wantDeleted))
+	}
+}
+
+func TestDelete(t *testing.T) {
+	f := newFixture(t, config.RetentionPolicy{})
+	f.putReport(t, "r1", "m1", 2)
+	f.putReport(t, "r2", "m1", 1)
+	f.putReport(t, "r3", "m2", 1)
+	f.putFailed(t, "f1", 1)
+	ctx := context.Background()
+
+	first, err := f.m.Find(ctx, Filter{WorkspaceID: "ws1", MonitorID: "m1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(first); !slices.Equal(got, []string{"reports/r1", "reports/r2"}) {
+		t.Fatalf("Find = %v", got)
+	}
+	res, err := f.m.Delete(ctx, first, "alice", ReasonBulk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := deleted(res.Deleted); !slices.Equal(got, []string{"reports/r1", "reports/r2"}) {
+		t.Errorf("deleted %v", got)
+	}
+
+	// Documents already gone are skipped; the day's bundle is extended
+	second := []Doc{
+		{Collection: report.CollectionReports, ID: "r1", Kind: KindReport},
+		{Collection: report.CollectionFailed, ID: "f1", Kind: KindFailed, WorkspaceID: "ws1"},
+	}
+	res2, err := f.m.Delete(ctx, second, "bob", ReasonAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := deleted(res2.Deleted); !slices.Equal(got, []string{"failed/f1"}) {
+		t.Errorf("deleted %v", got)
+	}
+	if got := f.remaining(t); !slices.Equal(got, []string{"reports/r3"}) {
+		t.Errorf("left %v", got)
+	}
+	if res2.Archive == res.Archive {
+		entries := bundle(t, res2.Archive)
+		if got := slices.Sorted(maps.Keys(entries)); !slices.Equal(got, []string{"failed/f1.json", "reports/r1.json", "reports/r2.json"}) {
+			t.Errorf("bundle entries %v", got)
+		}
+	}
+
+	logged := audit(t, f.cfg.AuditPath())
+	var got []string
+	for _, d := range logged {
+		got = append(got, d.ID+" "+d.Actor+" "+d.Reason)
+	}
+	// Found documents are deleted newest first
+	want := []string{"r2 alice bulk", "r1 alice bulk", "f1 bob api"}
+	if !slices.Equal(got, want) {
+		t.Errorf("audit log %v, want %v", got, want)
+	}
+
+	// Deletions reads the log newest first, filtered like documents
+	list, err := f.m.Deletions(Filter{WorkspaceID: "ws1"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != "f1" || list[1].ID != "r1" {
+		t.Errorf("Deletions = %v", deleted(list))
+	}
+}
+
+func TestDeleteWithoutArchive(t *testing.T) {
+	f := newFixture(t, config.RetentionPolicy{})
+	f.m.store = config.NewStore("", config.Config{Retention: config.RetentionConfig{
+		ArchiveDir: f.cfg.ArchiveDir,
+		AuditFile:  f.cfg.AuditFile,
+	}}, zerolog.Nop())
+	f.putReport(t, "r1", "m1", 1)
+	docs, err := f.m.Find(context.Background(), Filter{All: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := f.m.Delete(context.Background(), docs, "alice", ReasonAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Archive != "" {
+		t.Errorf("archived to %s with archiving off", res.Archive)
+	}
+	if _, err := os.Stat(f.cfg.ArchivePath()); !os.IsNotExist(err) {
+		t.Errorf("archive directory created: %v", err)
+	}
+	if logged := audit(t, f.cfg.AuditPath()); len(logged) != 1 || logged[0].Archive != "" {
+		t.Errorf("audit log %+v", logged)
+	}
+}
@@ -0,0 +1,409 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/metrics"
+	"github.com/songzhibin97/CryptoPulse/queue"
+	"github.com/songzhibin97/CryptoPulse/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Signal types reported for scanner hits
+const (
+	SignalGainer       = "gainer"
+	SignalLoser        = "loser"
+	SignalOverbought   = "rsi_overbought"
+	SignalOversold     = "rsi_oversold"
+	SignalVolumeSpike  = "volume_spike"
+	SignalBreakoutUp   = "breakout_up"
+	SignalBreakoutDown = "breakout_down"
+)
+
+// volumeDays is the number of closed daily candles the average daily
+// volume is taken over
+const volumeDays = 20
+
+// ErrRunning is returned when a scan is requested while one is running
+var ErrRunning = errors.New("scan is already running")
+
+// Signal is a criterion a pair met
+type Signal struct {
+	Type string `json:"type"`
+	// Value is what met the criterion: the 24h change in percent, the RSI,
+	// the volume multiple or the high or low that was broken
+	Value float64 `json:"value"`
+}
+
+// Hit is a pair that met at least one criterion
+type Hit struct {
+	Symbol         string   `json:"symbol"`
+	LastPrice      float64  `json:"last_price"`
+	ChangePct24h   float64  `json:"change_pct_24h"`
+	QuoteVolume24h float64  `json:"quote_volume_24h"`
+	RSI            float64  `json:"rsi,omitempty"`
+	VolumeRatio    float64  `json:"volume_ratio,omitempty"`
+	Signals        []Signal `json:"signals"`
+	// Score sums how far each signal's value is past its threshold, as a
+	// multiple of the threshold; a signal that just met it adds 1
+	Score float64 `json:"score"`
+}
+
+// Result is the outcome of one scan
+type Result struct {
+	StartedAt  int64               `json:"started_at"`
+	FinishedAt int64               `json:"finished_at"`
+	QuoteAsset string              `json:"quote_asset"`
+	Criteria   config.ScanCriteria `json:"criteria"`
+	// Pairs counts the trading pairs of the quote asset and Evaluated the
+	// ones above the volume floor
+	Pairs     int   `json:"pairs"`
+	Evaluated int   `json:"evaluated"`
+	Hits      []Hit `json:"hits"`
+	// Throttled counts the waits for request weight to free up
+	Throttled int    `json:"throttled"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Scanner periodically checks every trading pair of a quote asset against
+// the configured criteria and keeps the ranked hits of the last scan
+type Scanner struct {
+	store   *config.Store
+	fetch   *queue.Pool
+	logger  zerolog.Logger
+	last    *Result
+	running bool
+	mu      sync.Mutex
+}
+
+// New creates a scanner whose requests run on the fetch pool behind
+// interactive and monitor work
+func New(store *config.Store, fetch *queue.Pool, logger zerolog.Logger) *Scanner {
+	return &Scanner{store: store, fetch: fetch, logger: logger}
+}
+
+// Run scans at the configured interval while the scanner is enabled, until
+// ctx ends. Config changes apply from the next scan.
+func (s *Scanner) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		cfg := s.store.Get().Scanner
+		if cfg.Enabled {
+			if _, err := s.Scan(ctx); err != nil && !errors.Is(err, ErrRunning) && ctx.Err() == nil {
+				s.logger.Error().Err(err).Msg("Market scan failed")
+			}
+		}
+		timer.Reset(cfg.Every())
+	}
+}
+
+// Last returns the result of the last scan and whether a scan is running.
+// The result is nil before the first scan finished.
+func (s *Scanner) Last() (*Result, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last, s.running
+}
+
+// Scan runs one scan now and keeps its result. A failed scan is kept with
+// its error; one cut short by ctx is dropped.
+func (s *Scanner) Scan(ctx context.Context) (Result, error) {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return Result{}, ErrRunning
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "MarketScan")
+	res, err := s.scan(ctx, s.store.Get())
+	res.FinishedAt = time.Now().Unix()
+	span.SetAttributes(attribute.Int("scan.pairs", res.Pairs), attribute.Int("scan.hits", len(res.Hits)))
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+		res.Error = err.Error()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	metrics.ObserveScan(outcome, time.Since(start))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = false
+	if ctx.Err() == nil {
+		s.last = &res
+	}
+	s.logger.Info().
+		Int("pairs", res.Pairs).
+		Int("evaluated", res.Evaluated).
+		Int("hits", len(res.Hits)).
+		Int("throttled", res.Throttled).
+		Dur("duration_ms", time.Since(start)).
+		Msg("Market scan finished")
+	return res, err
+}
+
+// ticker is a pair's 24h rolling window statistics
+type ticker struct {
+	Symbol             string `json:"symbol"`
+	LastPrice          string `json:"lastPrice"`
+	PriceChangePercent string `json:"priceChangePercent"`
+	Volume             string `json:"volume"`
+	QuoteVolume        string `json:"quoteVolume"`
+}
+
+// candle is a closed daily kline
+type candle struct {
+	high, low, close, volume float64
+}
+
+func (s *Scanner) scan(ctx context.Context, cfg config.Config) (Result, error) {
+	sc := cfg.Scanner
+	criteria := sc.Criteria
+	res := Result{StartedAt: time.Now().Unix(), QuoteAsset: sc.Quote(), Criteria: criteria, Hits: []Hit{}}
+	client := newClient(cfg)
+	get := func(url string, out interface{}) error {
+		if err := s.waitForWeight(ctx, sc.Weight(), &res); err != nil {
+			return err
+		}
+		return s.fetch.Do(ctx, queue.Background, func(ctx context.Context) error {
+			resp, err := client.R().SetContext(ctx).Get(url)
+			if err != nil {
+				return err
+			}
+			if resp.StatusCode() != http.StatusOK {
+				return fmt.Errorf("status %d: %s", resp.StatusCode(), resp.String())
+			}
+			return json.Unmarshal(resp.Body(), out)
+		})
+	}
+
+	var info struct {
+		Symbols []struct {
+			Symbol     string `json:"symbol"`
+			Status     string `json:"status"`
+			QuoteAsset string `json:"quoteAsset"`
+		} `json:"symbols"`
+	}
+	if err := get("https://api1.binance.com/api/v3/exchangeInfo", &info); err != nil {
+		return res, fmt.Errorf("fetch exchange info failed: %w", err)
+	}
+	trading := make(map[string]bool)
+	for _, sym := range info.Symbols {
+		if sym.Status == "TRADING" && sym.QuoteAsset == res.QuoteAsset {
+			trading[sym.Symbol] = true
+		}
+	}
+	res.Pairs = len(trading)
+
+	var tickers []ticker
+	if err := get("https://api1.binance.com/api/v3/ticker/24hr", &tickers); err != nil {
+		return res, fmt.Errorf("fetch tickers failed: %w", err)
+	}
+	var candidates []ticker
+	for _, t := range tickers {
+		if trading[t.Symbol] && parseNum(t.QuoteVolume) >= criteria.MinQuoteVolume {
+			candidates = append(candidates, t)
+		}
+	}
+	res.Evaluated = len(candidates)
+
+	needKlines := criteria.RSIOverbought > 0 || criteria.RSIOversold > 0 || criteria.VolumeSpike > 0 || criteria.BreakoutDays > 0
+	// One more than needed for the live candle, which is dropped
+	limit := max(criteria.BreakoutDays, 3*criteria.Period(), volumeDays) + 1
+	for _, t := range candidates {
+		var candles []candle
+		if needKlines {
+			var raw [][]interface{}
+			url := fmt.Sprintf("https://api1.binance.com/api/v3/klines?symbol=%s&interval=1d&limit=%d", t.Symbol, limit)
+			if err := get(url, &raw); err != nil {
+				if ctx.Err() != nil || errors.Is(err, queue.ErrClosed) {
+					return res, err
+				}
+				s.logger.Warn().Err(err).Str("symbol", t.Symbol).Msg("Fetch scanner klines error")
+				continue
+			}
+			candles = closedCandles(raw)
+		}
+		if hit, ok := evaluate(t, candles, criteria); ok {
+			res.Hits = append(res.Hits, hit)
+		}
+	}
+	sort.SliceStable(res.Hits, func(i, j int) bool {
+		if res.Hits[i].Score != res.Hits[j].Score {
+			return res.Hits[i].Score > res.Hits[j].Score
+		}
+		return res.Hits[i].QuoteVolume24h > res.Hits[j].QuoteVolume24h
+	})
+	return res, nil
+}
+
+// waitForWeight blocks until the next minute while the request weight the
+// exchange reported for this minute is at the limit
+func (s *Scanner) waitForWeight(ctx context.Context, limit int, res *Result) error {
+	for metrics.UsedWeight("binance") >= float64(limit) {
+		res.Throttled++
+		metrics.CountScanThrottled()
+		wait := time.Until(time.Now().Truncate(time.Minute).Add(time.Minute + time.Second))
+		s.logger.Debug().Dur("wait", wait).Msg("Scanner waiting for request weight")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	return nil
+}
+
+// evaluate checks a pair against the criteria. RSI and breakouts compare
+// the last price with closed daily candles.
+func evaluate(t ticker, candles []candle, c config.ScanCriteria) (Hit, bool) {
+	hit := Hit{
+		Symbol:         t.Symbol,
+		LastPrice:      parseNum(t.LastPrice),
+		ChangePct24h:   parseNum(t.PriceChangePercent),
+		QuoteVolume24h: parseNum(t.QuoteVolume),
+	}
+	add := func(typ string, value, strength float64) {
+		hit.Signals = append(hit.Signals, Signal{Type: typ, Value: value})
+		hit.Score += strength
+	}
+
+	if c.MinChangePct > 0 && math.Abs(hit.ChangePct24h) >= c.MinChangePct {
+		typ := SignalGainer
+		if hit.ChangePct24h < 0 {
+			typ = SignalLoser
+		}
+		add(typ, hit.ChangePct24h, math.Abs(hit.ChangePct24h)/c.MinChangePct)
+	}
+
+	closes := make([]float64, 0, len(candles)+1)
+	for _, k := range candles {
+		closes = append(closes, k.close)
+	}
+	if r, ok := rsi(append(closes, hit.LastPrice), c.Period()); ok {
+		hit.RSI = math.Round(r*100) / 100
+		switch {
+		case c.RSIOverbought > 0 && r >= c.RSIOverbought:
+			add(SignalOverbought, hit.RSI, r/c.RSIOverbought)
+		case c.RSIOversold > 0 && r <= c.RSIOversold && r > 0:
+			add(SignalOversold, hit.RSI, c.RSIOversold/r)
+		}
+	}
+
+	if recent := candles[max(len(candles)-volumeDays, 0):]; len(recent) >= 5 {
+		var sum float64
+		for _, k := range recent {
+			sum += k.volume
+		}
+		if avg := sum / float64(len(recent)); avg > 0 {
+			hit.VolumeRatio = math.Round(parseNum(t.Volume)/avg*100) / 100
+			if c.VolumeSpike > 0 && hit.VolumeRatio >= c.VolumeSpike {
+				add(SignalVolumeSpike, hit.VolumeRatio, hit.VolumeRatio/c.VolumeSpike)
+			}
+		}
+	}
+
+	if n := c.BreakoutDays; n > 0 && len(candles) >= n && hit.LastPrice > 0 {
+		high, low := math.Inf(-1), math.Inf(1)
+		for _, k := range candles[len(candles)-n:] {
+			high, low = math.Max(high, k.high), math.Min(low, k.low)
+		}
+		switch {
+		case hit.LastPrice > high:
+			add(SignalBreakoutUp, high, hit.LastPrice/high)
+		case hit.LastPrice < low:
+			add(SignalBreakoutDown, low, low/hit.LastPrice)
+		}
+	}
+
+	hit.Score = math.Round(hit.Score*100) / 100
+	return hit, len(hit.Signals) > 0
+}
+
+// rsi returns Wilder's relative strength index of the last value
+func rsi(closes []float64, period int) (float64, bool) {
+	if len(closes) <= period {
+		return 0, false
+	}
+	p := float64(period)
+	var gain, loss float64
+	for i := 1; i <= period; i++ {
+		if d := closes[i] - closes[i-1]; d > 0 {
+			gain += d
+		} else {
+			loss -= d
+		}
+	}
+	gain, loss = gain/p, loss/p
+	for i := period + 1; i < len(closes); i++ {
+		d := closes[i] - closes[i-1]
+		gain = (gain*(p-1) + math.Max(d, 0)) / p
+		loss = (loss*(p-1) + math.Max(-d, 0)) / p
+	}
+	if loss == 0 {
+		return 100, true
+	}
+	return 100 - 100/(1+gain/loss), true
+}
+
+// closedCandles decodes a klines response, dropping the live candle
+func closedCandles(raw [][]interface{}) []candle {
+	now := time.Now().UnixMilli()
+	candles := make([]candle, 0, len(raw))
+	for _, k := range raw {
+		if len(k) < 7 {
+			continue
+		}
+		if closeTime, _ := k[6].(float64); int64(closeTime) >= now {
+			continue
+		}
+		str := func(i int) float64 {
+			s, _ := k[i].(string)
+			return parseNum(s)
+		}
+		candles = append(candles, candle{high: str(2), low: str(3), close: str(4), volume: str(5)})
+	}
+	return candles
+}
+
+// newClient creates the Binance client of a scan
+func newClient(cfg config.Config) *resty.Client {
+	base := &http.Transport{}
+	if cfg.ProxyURL.IsSet() {
+		base.Proxy = http.ProxyURL(cfg.ProxyURL.URL)
+	}
+	return metrics.InstrumentUpstream(resty.New().
+		SetTransport(tracing.Transport(base)).
+		SetTimeout(10*time.Second).
+		SetRetryCount(3).
+		SetRetryWaitTime(2*time.Second), "binance")
+}
+
+func parseNum(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@every 1h",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"-1 * * * *",
+		"x * * * *",
+		"1,x * * * *",
+		"5-1 * * * *",
+		"1-x * * * *",
+		"*/0 * * * *",
+		"*/-5 * * * *",
+		"*/x * * * *",
+		"@close",
+		"@close 1w",
+		"@close 7m",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestParseValid(t *testing.T) {
+	for _, spec := range []string{
+		"* * * * *",
+		"0 8 * * *",
+		"*/15 1-5/2 1,15 * 7",
+		"0-59/30 0,12 1-31 1-12 0-7",
+		" @daily ",
+		"@close 4h",
+		"@close  1d",
+	} {
+		if _, err := Parse(spec); err != nil {
+			t.Errorf("Parse(%q): %v", spec, err)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	date := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"every minute", "* * * * *", date(2024, 6, 1, 10, 0), date(2024, 6, 1, 10, 1)},
+		{"seconds are dropped", "15 * * * *", date(2024, 6, 1, 10, 14).Add(59 * time.Second), date(2024, 6, 1, 10, 15)},
+		{"strictly after", "0 9,17 * * *", date(2024, 6, 1, 9, 0), date(2024, 6, 1, 17, 0)},
+		{"step", "*/15 * * * *", date(2024, 6, 1, 10, 7), date(2024, 6, 1, 10, 15)},
+		{"step across the hour", "*/15 * * * *", date(2024, 6, 1, 10, 50), date(2024, 6, 1, 11, 0)},
+		{"step within a range", "10-30/10 * * * *", date(2024, 6, 1, 10, 31), date(2024, 6, 1, 11, 10)},
+		{"value with step", "5/20 * * * *", date(2024, 6, 1, 10, 26), date(2024, 6, 1, 10, 45)},
+		{"list", "0 1,13,22 * * *", date(2024, 6, 1, 13, 30), date(2024, 6, 1, 22, 0)},
+		{"across midnight", "30 0 * * *", date(2024, 6, 1, 23, 45), date(2024, 6, 2, 0, 30)},
+		{"across a month", "0 0 1 * *", date(2024, 1, 31, 12, 0), date(2024, 2, 1, 0, 0)},
+		{"across a year", "0 0 1 1 *", date(2024, 12, 31, 23, 59), date(2025, 1, 1, 0, 0)},
+		{"last minute of the year", "59 23 31 12 *", date(2024, 12, 31, 23, 59), date(2025, 12, 31, 23, 59)},
+		{"monthly across a year", "@monthly", date(2024, 12, 15, 0, 0), date(2025, 1, 1, 0, 0)},
+		{"skips short months", "0 0 31 * *", date(2024, 4, 1, 0, 0), date(2024, 5, 31, 0, 0)},
+		{"leap day", "0 0 29 2 *", date(2025, 3, 1, 0, 0), date(2028, 2, 29, 0, 0)},
+		{"month list across a year", "0 0 1 3,9 *", date(2024, 10, 1, 0, 0), date(2025, 3, 1, 0, 0)},
+		{"day of week", "0 0 * * 1", date(2024, 6, 2, 12, 0), date(2024, 6, 3, 0, 0)},
+		{"sunday as 7", "0 0 * * 7", date(2024, 6, 3, 0, 0), date(2024, 6, 9, 0, 0)},
+		{"sunday as 0", "0 0 * * 0", date(2024, 6, 3, 0, 0), date(2024, 6, 9, 0, 0)},
+		{"weekdays across a weekend", "0 8 * * 1-5", date(2024, 6, 7, 9, 0), date(2024, 6, 10, 8, 0)},
+		{"day of month only", "0 0 15 * *", date(2024, 6, 20, 0, 0), date(2024, 7, 15, 0, 0)},
+		// With both day fields restricted a day matching either one fires
+		{"either day field, day of month", "0 0 13 * 5", date(2024, 10, 12, 0, 0), date(2024, 10, 13, 0, 0)},
+		{"either day field, day of week", "0 0 13 * 5", date(2024, 10, 13, 0, 0), date(2024, 10, 18, 0, 0)},
+		{"non-UTC input", "0 0 * * *", time.Date(2024, 6, 1, 7, 0, 0, 0, time.FixedZone("UTC+8", 8*3600)), date(2024, 6, 1, 0, 0)},
+		{"close 15m at a close", "@close 15m", date(2024, 6, 1, 10, 15), date(2024, 6, 1, 10, 30)},
+		{"close 4h across a year", "@close 4h", date(2024, 12, 31, 22, 30), date(2025, 1, 1, 0, 0)},
+		{"close 1d on a leap day", "@close 1d", date(2024, 2, 29, 10, 0), date(2024, 3, 1, 0, 0)},
+		{"close 12h", "@close 12h", date(2024, 6, 1, 0, 0).Add(-time.Second), date(2024, 6, 1, 0, 0)},
+		{"never fires", "0 0 31 2 *", date(2024, 1, 1, 0, 0), time.Time{}},
+		{"never fires on day 30 of February", "0 0 30 2 *", date(2024, 1, 1, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sched, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.spec, err)
+			}
+			if got := sched.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) of %q = %s, want %s", tt.from, tt.spec, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,413 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// Missed-run policies decide what happens to runs missed while the server
+// was down
+const (
+	// PolicySkip drops missed runs and waits for the next scheduled time
+	PolicySkip = "skip"
+	// PolicyCatchUp runs once at startup for any number of missed runs
+	PolicyCatchUp = "catch_up"
+)
+
+// missedGrace is how late a run may start after a restart before it counts
+// as missed
+const missedGrace = time.Minute
+
+var (
+	// ErrNotFound is returned for unknown job IDs
+	ErrNotFound = errors.New("schedule not found")
+	// ErrRunning is returned when a job is triggered while it is running
+	ErrRunning = errors.New("schedule is already running")
+	// ErrNotStarted is returned when a job is triggered before Run
+	ErrNotStarted = errors.New("scheduler is not running")
+)
+
+// Job is a scheduled one-shot analysis
+type Job struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name,omitempty"`
+	Symbol    string   `json:"symbol"`
+	Intervals []string `json:"intervals"`
+	// Schedule is a cron expression in UTC, a descriptor like "@daily" or
+	// "@close 4h"
+	Schedule     string   `json:"schedule"`
+	Backend      string   `json:"backend,omitempty"`
+	Ensemble     []string `json:"ensemble,omitempty"`
+	MissedPolicy string   `json:"missed_policy"`
+	OwnerID      string   `json:"owner_id,omitempty"`
+	WorkspaceID  string   `json:"workspace_id"`
+	CreatedAt    int64    `json:"created_at"`
+	// NextRun is the next scheduled time in Unix seconds; the run starts
+	// after the scheduler's close delay so the candle closing then is final.
+	// It is zero once the schedule never fires again.
+	NextRun int64 `json:"next_run"`
+	LastRun *Run  `json:"last_run,omitempty"`
+	Running bool  `json:"running,omitempty"`
+}
+
+// Run is the outcome of one execution of a job
+type Run struct {
+	// ScheduledAt is the scheduled time, zero for manual runs
+	ScheduledAt int64  `json:"scheduled_at,omitempty"`
+	StartedAt   int64  `json:"started_at"`
+	FinishedAt  int64  `json:"finished_at,omitempty"`
+	AnalysisID  string `json:"analysis_id,omitempty"`
+	ReportID    string `json:"report_id,omitempty"`
+	Error       string `json:"error,omitempty"`
+	// CatchUp marks a run made at startup for runs missed during downtime
+	CatchUp bool `json:"catch_up,omitempty"`
+}
+
+// Result identifies the analysis a run produced
+type Result struct {
+	AnalysisID string
+	ReportID   string
+}
+
+// RunFunc performs the analysis of a job
+type RunFunc func(ctx context.Context, job Job) (Result, error)
+
+// Scheduler runs jobs on their schedules and persists them with their last
+// and next runs to a JSON file, so missed runs can be detected after a
+// restart
+type Scheduler struct {
+	path       string
+	closeDelay time.Duration
+	jobs       map[string]*Job
+	schedules  map[string]Schedule
+	running    map[string]bool
+	wake       chan struct{}
+	ctx        context.Context
+	run        RunFunc
+	inflight   sync.WaitGroup
+	logger     zerolog.Logger
+	mu         sync.Mutex
+}
+
+// New creates a Scheduler persisting to path. Runs start closeDelay after
+// their scheduled time.
+func New(path string, closeDelay time.Duration, logger zerolog.Logger) (*Scheduler, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	s := &Scheduler{
+		path:       path,
+		closeDelay: closeDelay,
+		jobs:       make(map[string]*Job),
+		schedules:  make(map[string]Schedule),
+		running:    make(map[string]bool),
+		wake:       make(chan struct{}, 1),
+		logger:     logger,
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, fmt.Errorf("parse schedule file %s: %w", path, err)
+	}
+	for _, job := range jobs {
+		sched, err := Parse(job.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %s: %w", job.ID, err)
+		}
+		s.jobs[job.ID], s.schedules[job.ID] = job, sched
+	}
+	return s, nil
+}
+
+// Add validates and stores a new job and returns it with its ID and first
+// run time
+func (s *Scheduler) Add(job Job) (Job, error) {
+	sched, err := Parse(job.Schedule)
+	if err != nil {
+		return Job{}, err
+	}
+	switch job.MissedPolicy {
+	case "":
+		job.MissedPolicy = PolicySkip
+	case PolicySkip, PolicyCatchUp:
+	default:
+		return Job{}, fmt.Errorf("unknown missed_policy %q (use %s or %s)", job.MissedPolicy, PolicySkip, PolicyCatchUp)
+	}
+	now := time.Now()
+	next := sched.Next(now)
+	if next.IsZero() {
+		return Job{}, fmt.Errorf("schedule %q never fires", job.Schedule)
+	}
+	job.ID = uuid.New().String()
+	job.CreatedAt = now.Unix()
+	job.NextRun = next.Unix()
+	job.LastRun, job.Running = nil, false
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := job
+	s.jobs[job.ID], s.schedules[job.ID] = &stored, sched
+	if err := s.save(); err != nil {
+		delete(s.jobs, job.ID)
+		delete(s.schedules, job.ID)
+		return Job{}, err
+	}
+	s.notify()
+	return job, nil
+}
+
+// Remove deletes a job; a run in progress finishes
+func (s *Scheduler) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.jobs, id)
+	if err := s.save(); err != nil {
+		s.jobs[id] = job
+		return err
+	}
+	delete(s.schedules, id)
+	s.notify()
+	return nil
+}
+
+// Job returns a job by ID
+func (s *Scheduler) Job(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return s.snapshot(job), true
+}
+
+// Jobs returns the jobs of a workspace, oldest first
+func (s *Scheduler) Jobs(workspaceID string) []Job {
+	return s.jobsWhere(func(job *Job) bool { return job.WorkspaceID == workspaceID })
+}
+
+// AllJobs returns the jobs of every workspace, oldest first
+func (s *Scheduler) AllJobs() []Job {
+	return s.jobsWhere(func(*Job) bool { return true })
+}
+
+func (s *Scheduler) jobsWhere(match func(*Job) bool) []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		if match(job) {
+			jobs = append(jobs, s.snapshot(job))
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt < jobs[j].CreatedAt })
+	return jobs
+}
+
+// Trigger runs a job now, outside its schedule
+func (s *Scheduler) Trigger(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	switch {
+	case !ok:
+		return ErrNotFound
+	case s.run == nil || s.ctx.Err() != nil:
+		return ErrNotStarted
+	case s.running[id]:
+		return ErrRunning
+	}
+	s.startLocked(job, Run{})
+	return nil
+}
+
+// Run executes jobs on their schedules until ctx is cancelled. Runs missed
+// while the server was down are skipped or caught up once according to each
+// job's policy.
+func (s *Scheduler) Run(ctx context.Context, run RunFunc) {
+	s.mu.Lock()
+	s.ctx, s.run = ctx, run
+	now := time.Now()
+	for id, job := range s.jobs {
+		if job.NextRun == 0 || job.NextRun >= now.Add(-s.closeDelay-missedGrace).Unix() {
+			continue
+		}
+		missed := job.NextRun
+		job.NextRun = s.nextRun(id, now)
+		if job.MissedPolicy == PolicyCatchUp {
+			s.logger.Info().Str("schedule_id", id).Time("missed", time.Unix(missed, 0)).Msg("Catching up missed scheduled analysis")
+			s.startLocked(job, Run{ScheduledAt: missed, CatchUp: true})
+		} else {
+			s.logger.Info().Str("schedule_id", id).Time("missed", time.Unix(missed, 0)).Msg("Skipping missed scheduled analysis")
+		}
+	}
+	if err := s.save(); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to save schedules")
+	}
+	s.mu.Unlock()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+		timer.Reset(s.dispatch(time.Now()))
+	}
+}
+
+// Wait blocks until runs in progress have finished or ctx ends
+func (s *Scheduler) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("scheduled analyses still running: %w", ctx.Err())
+	}
+}
+
+// dispatch starts the jobs that are due at now and returns how long to
+// sleep until the next one
+func (s *Scheduler) dispatch(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wait := time.Hour
+	changed := false
+	for id, job := range s.jobs {
+		if job.NextRun == 0 {
+			continue
+		}
+		due := time.Unix(job.NextRun, 0).Add(s.closeDelay)
+		if due.After(now) {
+			wait = min(wait, due.Sub(now))
+			continue
+		}
+		scheduled := job.NextRun
+		// Schedule from the current time so a long pause does not cause a burst
+		job.NextRun = s.nextRun(id, now.Add(-s.closeDelay))
+		changed = true
+		if s.running[id] {
+			s.logger.Warn().Str("schedule_id", id).Msg("Scheduled analysis still running, skipping this run")
+		} else {
+			s.startLocked(job, Run{ScheduledAt: scheduled})
+		}
+		if next := time.Unix(job.NextRun, 0).Add(s.closeDelay); next.After(now) {
+			wait = min(wait, next.Sub(now))
+		}
+	}
+	if changed {
+		if err := s.save(); err != nil {
+			s.logger.Error().Err(err).Msg("Failed to save schedules")
+		}
+	}
+	return wait
+}
+
+// nextRun returns the next scheduled time of a job after t, or zero when
+// its schedule never fires again, which disables the job; callers hold s.mu
+func (s *Scheduler) nextRun(id string, t time.Time) int64 {
+	next := s.schedules[id].Next(t)
+	if next.IsZero() {
+		s.logger.Warn().Str("schedule_id", id).Str("schedule", s.jobs[id].Schedule).Msg("Schedule never fires again, disabling it")
+		return 0
+	}
+	return next.Unix()
+}
+
+// startLocked runs a job in the background; callers hold s.mu
+func (s *Scheduler) startLocked(job *Job, r Run) {
+	s.running[job.ID] = true
+	snapshot := s.snapshot(job)
+	s.inflight.Add(1)
+	go func() {
+		defer s.inflight.Done()
+		r.StartedAt = time.Now().Unix()
+		s.logger.Info().Str("schedule_id", snapshot.ID).Str("symbol", snapshot.Symbol).Strs("intervals", snapshot.Intervals).Msg("Running scheduled analysis")
+		res, err := s.run(s.ctx, snapshot)
+		r.FinishedAt = time.Now().Unix()
+		r.AnalysisID, r.ReportID = res.AnalysisID, res.ReportID
+		if err != nil {
+			r.Error = err.Error()
+			s.logger.Error().Err(err).Str("schedule_id", snapshot.ID).Msg("Scheduled analysis failed")
+		} else {
+			s.logger.Info().Str("schedule_id", snapshot.ID).Str("analysis_id", res.AnalysisID).Str("report_id", res.ReportID).Msg("Scheduled analysis completed")
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.running, snapshot.ID)
+		if job, ok := s.jobs[snapshot.ID]; ok {
+			job.LastRun = &r
+			if err := s.save(); err != nil {
+				s.logger.Error().Err(err).Msg("Failed to save schedules")
+			}
+		}
+	}()
+}
+
+// snapshot copies a job for callers outside the lock
+func (s *Scheduler) snapshot(job *Job) Job {
+	out := *job
+	out.Intervals = slices.Clone(job.Intervals)
+	out.Ensemble = slices.Clone(job.Ensemble)
+	if job.LastRun != nil {
+		r := *job.LastRun
+		out.LastRun = &r
+	}
+	out.Running = s.running[job.ID]
+	return out
+}
+
+// notify wakes the run loop to recompute its timer
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) save() error {
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt < jobs[j].CreatedAt })
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
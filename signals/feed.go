@@ -0,0 +1,147 @@
+package signals
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+
+	"github.com/songzhibin97/CryptoPulse/models"
+)
+
+// maxSignals bounds the feed's history; older signals are dropped
+const maxSignals = 5000
+
+// subscriberBuffer is the number of signals a subscriber may fall behind
+// before further signals are dropped for it
+const subscriberBuffer = 64
+
+// Query filters the feed. Signals must belong to WorkspaceID unless All is
+// set; other zero fields match everything.
+type Query struct {
+	// All matches signals of every workspace, for admins
+	All         bool
+	WorkspaceID string
+	// OwnerID also matches signals of reports from before workspaces
+	// existed that this user owns
+	OwnerID   string
+	Symbol    string
+	Direction string
+	// Since matches signals at or after this Unix millisecond time
+	Since         int64
+	MinConfidence float64
+	Limit         int
+}
+
+// Match reports whether a signal passes the filter; Since and Limit are
+// not considered
+func (q Query) Match(s Signal) bool {
+	return q.matchWorkspace(s) &&
+		(q.Symbol == "" || s.Symbol == q.Symbol) &&
+		(q.Direction == "" || s.Direction == q.Direction) &&
+		s.Confidence >= q.MinConfidence
+}
+
+// matchWorkspace reports whether a signal belongs to the query's
+// workspace. An empty WorkspaceID matches nothing without All.
+func (q Query) matchWorkspace(s Signal) bool {
+	switch {
+	case q.All:
+		return true
+	case q.WorkspaceID == "":
+		return false
+	case s.WorkspaceID == "":
+		return q.OwnerID != "" && s.OwnerID == q.OwnerID
+	}
+	return s.WorkspaceID == q.WorkspaceID
+}
+
+// Feed keeps the signals of recent reports and passes new ones to
+// subscribers
+type Feed struct {
+	signals     []Signal // oldest first
+	subscribers map[chan Signal]Query
+	mu          sync.RWMutex
+}
+
+// NewFeed creates a feed holding the signals of reports, which need not be
+// sorted. Reports of ensemble members are left out, as in Add.
+func NewFeed(reports []models.Report) *Feed {
+	f := &Feed{subscribers: make(map[chan Signal]Query)}
+	for _, rep := range reports {
+		if rep.EnsembleMemberOf != "" {
+			continue
+		}
+		f.signals = append(f.signals, Extract(rep))
+	}
+	slices.SortStableFunc(f.signals, func(a, b Signal) int { return cmp.Compare(a.Time, b.Time) })
+	if len(f.signals) > maxSignals {
+		f.signals = f.signals[len(f.signals)-maxSignals:]
+	}
+	return f
+}
+
+// Add extracts the signal of a stored report and passes it to matching
+// subscribers. It never blocks; subscribers that fell behind miss it.
+// Reports of ensemble members are ignored: only their consensus signals.
+func (f *Feed) Add(rep models.Report) {
+	if rep.EnsembleMemberOf != "" {
+		return
+	}
+	s := Extract(rep)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.signals = append(f.signals, s)
+	if len(f.signals) > maxSignals {
+		f.signals = f.signals[len(f.signals)-maxSignals:]
+	}
+	for ch, q := range f.subscribers {
+		if !q.Match(s) {
+			continue
+		}
+		select {
+		case ch <- s:
+		default:
+		}
+	}
+}
+
+// Remove drops the signal of a deleted report
+func (f *Feed) Remove(reportID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.signals = slices.DeleteFunc(f.signals, func(s Signal) bool { return s.ReportID == reportID })
+}
+
+// Query returns the matching signals, newest first
+func (f *Feed) Query(q Query) []Signal {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	out := make([]Signal, 0)
+	for i := len(f.signals) - 1; i >= 0; i-- {
+		s := f.signals[i]
+		if q.Limit > 0 && len(out) >= q.Limit {
+			break
+		}
+		if s.Time >= q.Since && q.Match(s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Subscribe returns a channel receiving new signals that match q and a
+// function that ends the subscription
+func (f *Feed) Subscribe(q Query) (<-chan Signal, func()) {
+	ch := make(chan Signal, subscriberBuffer)
+	f.mu.Lock()
+	f.subscribers[ch] = q
+	f.mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			f.mu.Lock()
+			delete(f.subscribers, ch)
+			f.mu.Unlock()
+		})
+	}
+}
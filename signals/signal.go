@@ -0,0 +1,190 @@
+package signals
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/songzhibin97/CryptoPulse/models"
+)
+
+// Signal directions
+const (
+	Long  = "long"
+	Short = "short"
+	Flat  = "flat"
+)
+
+// ATR multiples of the stop and target when no level lies beyond the entry
+const (
+	stopATR   = 1.5
+	targetATR = 3
+)
+
+// riskPenalty is taken off the confidence for every risk alert
+const riskPenalty = 0.05
+
+// Signal is the actionable view of a report
+type Signal struct {
+	ReportID    string `json:"report_id"`
+	Symbol      string `json:"symbol"`
+	WorkspaceID string `json:"workspace_id,omitempty"`
+	OwnerID     string `json:"owner_id,omitempty"`
+	Time        int64  `json:"time"`
+	Direction   string `json:"direction"`
+	// Confidence in [0, 1] is the models' agreement on the trend scaled by
+	// how many indicators confirm it, less a penalty per risk alert
+	Confidence float64 `json:"confidence"`
+	// Entry is the price when the report was stored, or the Bollinger
+	// middle band for reports that did not record it
+	Entry float64 `json:"entry"`
+	// Stop and Target are the nearest levels beyond the entry, or ATR
+	// multiples of it; both are 0 for flat signals
+	Stop       float64 `json:"stop"`
+	Target     float64 `json:"target"`
+	RiskReward float64 `json:"risk_reward"`
+	// Horizon is the longest timeframe of the analysis; the signal expires
+	// one candle of it after Time
+	Horizon   string   `json:"horizon"`
+	ExpiresAt int64    `json:"expires_at"`
+	Risks     []string `json:"risks"`
+}
+
+// Extract normalizes a report into a signal
+func Extract(rep models.Report) Signal {
+	s := Signal{
+		ReportID:    rep.ReportID,
+		Symbol:      rep.Symbol,
+		WorkspaceID: rep.WorkspaceID,
+		OwnerID:     rep.OwnerID,
+		Time:        rep.Timestamp,
+		Direction:   direction(rep.TechnicalAnalysis.TrendDirection),
+		Entry:       rep.Price,
+		Risks:       make([]string, 0, len(rep.RiskAlerts)),
+	}
+	if s.Entry == 0 {
+		s.Entry = rep.TechnicalAnalysis.Bollinger.Middle
+	}
+	for _, alert := range rep.RiskAlerts {
+		s.Risks = append(s.Risks, alert.Type)
+	}
+	var longest int64
+	for _, interval := range rep.Timeframe {
+		if d, ok := models.IntervalDuration(interval); ok && d.Milliseconds() > longest {
+			longest, s.Horizon = d.Milliseconds(), interval
+		}
+	}
+	if longest > 0 {
+		s.ExpiresAt = s.Time + longest
+	}
+
+	agreement := 1.0
+	if rep.Ensemble != nil {
+		agreement = rep.Ensemble.TrendAgreement
+	}
+	if s.Direction == Flat {
+		s.Confidence = round(clamp(agreement - riskPenalty*float64(len(rep.RiskAlerts))))
+		return s
+	}
+	sign := 1.0
+	if s.Direction == Short {
+		sign = -1
+	}
+	s.Confidence = round(clamp(agreement*(0.5+0.5*confirmation(rep, s.Entry, sign)) - riskPenalty*float64(len(rep.RiskAlerts))))
+	if s.Entry > 0 {
+		s.Stop, s.Target = levels(rep, s.Entry, sign)
+		if risk := math.Abs(s.Entry - s.Stop); s.Stop > 0 && s.Target > 0 && risk > 0 {
+			s.RiskReward = round(math.Abs(s.Target-s.Entry) / risk)
+		}
+	}
+	return s
+}
+
+func direction(trend string) string {
+	switch strings.ToLower(trend) {
+	case "bullish":
+		return Long
+	case "bearish":
+		return Short
+	}
+	return Flat
+}
+
+// confirmation returns the share of the indicators present that point in
+// the direction of sign, or one half when none are present
+func confirmation(rep models.Report, entry, sign float64) float64 {
+	ta := rep.TechnicalAnalysis
+	var agree, total float64
+	check := func(present bool, v float64) {
+		if !present {
+			return
+		}
+		total++
+		if v*sign > 0 {
+			agree++
+		}
+	}
+	check(ta.RSI > 0, ta.RSI-50)
+	check(ta.MACD.Histogram != 0, ta.MACD.Histogram)
+	check(ta.Bollinger.Middle > 0 && entry != ta.Bollinger.Middle, entry-ta.Bollinger.Middle)
+	check(rep.CapitalFlow.BuySellRatio > 0, rep.CapitalFlow.BuySellRatio-1)
+	check(rep.OrderBook.BuySellDepthRatio > 0, rep.OrderBook.BuySellDepthRatio-1)
+	if total == 0 {
+		return 0.5
+	}
+	return agree / total
+}
+
+// levels returns the nearest technical or order book level on each side
+// of the entry as stop and target, falling back to ATR multiples
+func levels(rep models.Report, entry, sign float64) (stop, target float64) {
+	prices := make([]float64, 0)
+	for _, l := range rep.TechnicalAnalysis.SupportResistance {
+		prices = append(prices, price(l.Price))
+	}
+	for _, l := range rep.OrderBook.SupportResistance {
+		prices = append(prices, price(l.Price))
+	}
+	var below, above float64
+	for _, p := range prices {
+		switch {
+		case p <= 0:
+		case p < entry && p > below:
+			below = p
+		case p > entry && (above == 0 || p < above):
+			above = p
+		}
+	}
+	if sign < 0 {
+		stop, target = above, below
+	} else {
+		stop, target = below, above
+	}
+	if atr := rep.Sentiment.Volatility.ATR; atr > 0 {
+		if stop == 0 {
+			stop = entry - sign*stopATR*atr
+		}
+		if target == 0 {
+			target = entry + sign*targetATR*atr
+		}
+	}
+	return math.Max(stop, 0), math.Max(target, 0)
+}
+
+// price parses a level price, which models sometimes write with thousands
+// separators
+func price(s string) float64 {
+	f, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(s), ",", ""), 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+func clamp(f float64) float64 {
+	return math.Min(math.Max(f, 0), 1)
+}
+
+func round(f float64) float64 {
+	return math.Round(f*1e4) / 1e4
+}
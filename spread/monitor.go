@@ -0,0 +1,320 @@
+package spread
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/songzhibin97/CryptoPulse/config"
+	"github.com/songzhibin97/CryptoPulse/exchange"
+	"github.com/songzhibin97/CryptoPulse/metrics"
+	"github.com/songzhibin97/CryptoPulse/queue"
+	"github.com/songzhibin97/CryptoPulse/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxAlerts bounds each monitor's alert log; older alerts are dropped
+const maxAlerts = 100
+
+// ErrNotFound is returned for an unknown monitor
+var ErrNotFound = errors.New("spread monitor not found")
+
+// Spec is what a spread monitor compares
+type Spec struct {
+	Symbol string   `json:"symbol"`
+	Venues []string `json:"venues"`
+	// Size is the base asset quantity executable prices are computed for
+	Size float64 `json:"size"`
+	// ThresholdBps raises an alert when the mid divergence reaches it
+	ThresholdBps float64         `json:"threshold_bps"`
+	Interval     config.Duration `json:"interval"`
+}
+
+// Validate checks the spec after defaults were applied
+func (s Spec) Validate() error {
+	switch {
+	case s.Symbol == "":
+		return errors.New("symbol is required")
+	case len(s.Venues) < 2:
+		return errors.New("at least two venues are required")
+	case s.Size <= 0:
+		return errors.New("size must be positive")
+	case s.ThresholdBps < 0:
+		return errors.New("threshold_bps must not be negative")
+	case time.Duration(s.Interval) < config.MinSpreadInterval:
+		return fmt.Errorf("interval must be at least %s", config.MinSpreadInterval)
+	}
+	for i, v := range s.Venues {
+		if !slices.Contains(config.DefaultVenues, v) {
+			return fmt.Errorf("%w: %s", exchange.ErrUnknownVenue, v)
+		}
+		if slices.Contains(s.Venues[:i], v) {
+			return fmt.Errorf("duplicate venue: %s", v)
+		}
+	}
+	return nil
+}
+
+// Status is a spread monitor with its latest snapshot and alerts
+type Status struct {
+	ID string `json:"id"`
+	Spec
+	OwnerID     string    `json:"owner_id,omitempty"`
+	WorkspaceID string    `json:"workspace_id"`
+	StartedAt   int64     `json:"started_at"`
+	Last        *Snapshot `json:"last,omitempty"`
+	// Dislocated is true while the divergence stays at the threshold
+	Dislocated bool    `json:"dislocated"`
+	Alerts     []Alert `json:"alerts"`
+}
+
+// monitor is a running spread monitor; fields other than cancel and done
+// are guarded by the manager's mutex
+type monitor struct {
+	status Status
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Manager runs the spread monitors
+type Manager struct {
+	ctx      context.Context
+	store    *config.Store
+	fetch    *queue.Pool
+	logger   zerolog.Logger
+	monitors map[string]*monitor
+	running  sync.WaitGroup
+	mu       sync.RWMutex
+}
+
+// NewManager creates a manager whose monitors fetch on the fetch pool and
+// run until ctx ends
+func NewManager(ctx context.Context, store *config.Store, fetch *queue.Pool, logger zerolog.Logger) *Manager {
+	return &Manager{ctx: ctx, store: store, fetch: fetch, logger: logger, monitors: make(map[string]*monitor)}
+}
+
+// Defaults fills in the configured venues and interval of a spec
+func (mgr *Manager) Defaults(spec Spec) Spec {
+	cfg := mgr.store.Get().Spreads
+	if len(spec.Venues) == 0 {
+		spec.Venues = slices.Clone(cfg.VenueNames())
+	}
+	if spec.Interval == 0 {
+		spec.Interval = config.Duration(cfg.Every())
+	}
+	return spec
+}
+
+// Start runs a monitor of spec, which has its defaults applied and is valid
+func (mgr *Manager) Start(spec Spec, ownerID, workspaceID string) (Status, error) {
+	if mgr.ctx.Err() != nil {
+		return Status{}, mgr.ctx.Err()
+	}
+	ctx, cancel := context.WithCancel(mgr.ctx)
+	m := &monitor{
+		status: Status{
+			ID:          uuid.New().String(),
+			Spec:        spec,
+			OwnerID:     ownerID,
+			WorkspaceID: workspaceID,
+			StartedAt:   time.Now().Unix(),
+			Alerts:      []Alert{},
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	status := m.copyLocked()
+	mgr.mu.Lock()
+	mgr.monitors[status.ID] = m
+	mgr.mu.Unlock()
+	mgr.running.Add(1)
+	go mgr.run(ctx, m)
+	mgr.logger.Info().
+		Str("spread_id", status.ID).
+		Str("symbol", spec.Symbol).
+		Strs("venues", spec.Venues).
+		Float64("size", spec.Size).
+		Float64("threshold_bps", spec.ThresholdBps).
+		Msg("Started spread monitor")
+	return status, nil
+}
+
+// Stop ends a monitor and waits for its loop to return
+func (mgr *Manager) Stop(id string) error {
+	mgr.mu.Lock()
+	m, ok := mgr.monitors[id]
+	delete(mgr.monitors, id)
+	mgr.mu.Unlock()
+	if !ok {
+		return ErrNotFound
+	}
+	m.cancel()
+	<-m.done
+	mgr.logger.Info().Str("spread_id", id).Msg("Stopped spread monitor")
+	return nil
+}
+
+// Get returns a monitor's status
+func (mgr *Manager) Get(id string) (Status, bool) {
+	mgr.mu.RLock()
+	defer mgr.mu.RUnlock()
+	m, ok := mgr.monitors[id]
+	if !ok {
+		return Status{}, false
+	}
+	return m.copyLocked(), true
+}
+
+// List returns the monitors of a workspace, or all monitors for ""
+func (mgr *Manager) List(workspaceID string) []Status {
+	mgr.mu.RLock()
+	defer mgr.mu.RUnlock()
+	out := make([]Status, 0, len(mgr.monitors))
+	for _, m := range mgr.monitors {
+		if workspaceID == "" || m.status.WorkspaceID == workspaceID {
+			out = append(out, m.copyLocked())
+		}
+	}
+	slices.SortFunc(out, func(a, b Status) int { return cmp.Compare(a.StartedAt, b.StartedAt) })
+	return out
+}
+
+// Count returns the number of monitors in a workspace
+func (mgr *Manager) Count(workspaceID string) int {
+	return len(mgr.List(workspaceID))
+}
+
+// Wait blocks until every monitor loop has returned or ctx ends
+func (mgr *Manager) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		mgr.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("spread monitors still running: %w", ctx.Err())
+	}
+}
+
+// Snapshot fetches and compares the books of a symbol once
+func (mgr *Manager) Snapshot(ctx context.Context, spec Spec, prio queue.Priority) (Snapshot, error) {
+	cfg := mgr.store.Get()
+	books := make([]exchange.Book, len(spec.Venues))
+	errs := make([]error, len(spec.Venues))
+	var wg sync.WaitGroup
+	for i, name := range spec.Venues {
+		venue, err := exchange.New(name, cfg)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = mgr.fetch.Do(ctx, prio, func(ctx context.Context) error {
+				ctx, span := tracing.Tracer().Start(ctx, "FetchOrderBook")
+				defer span.End()
+				span.SetAttributes(attribute.String("venue", name), attribute.String("symbol", spec.Symbol))
+				book, err := venue.Book(ctx, spec.Symbol, cfg.Spreads.Levels())
+				books[i] = book
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+
+	var fetched []exchange.Book
+	failed := make(map[string]error)
+	for i, name := range spec.Venues {
+		if errs[i] != nil {
+			failed[name] = errs[i]
+			continue
+		}
+		fetched = append(fetched, books[i])
+	}
+	if ctx.Err() != nil {
+		return Snapshot{}, ctx.Err()
+	}
+	if len(fetched) == 0 {
+		return Snapshot{}, fmt.Errorf("no order book of %s could be fetched: %w", spec.Symbol, errors.Join(errs...))
+	}
+	return Compare(spec.Symbol, spec.Size, fetched, failed), nil
+}
+
+// run takes snapshots at the monitor's interval until ctx ends
+func (mgr *Manager) run(ctx context.Context, m *monitor) {
+	defer mgr.running.Done()
+	defer close(m.done)
+	spec := m.status.Spec
+	ticker := time.NewTicker(time.Duration(spec.Interval))
+	defer ticker.Stop()
+	for {
+		snap, err := mgr.Snapshot(ctx, spec, queue.Background)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			mgr.logger.Warn().Err(err).Str("spread_id", m.status.ID).Msg("Spread snapshot failed")
+		default:
+			mgr.record(m, snap)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// record keeps a snapshot and raises an alert when the divergence reaches
+// the threshold; the next alert needs it to drop below the threshold first
+func (mgr *Manager) record(m *monitor, snap Snapshot) {
+	metrics.SetSpreadDivergence(snap.Symbol, snap.DivergenceBps)
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	s := &m.status
+	s.Last = &snap
+	dislocated := s.ThresholdBps > 0 && snap.HighVenue != "" && snap.DivergenceBps >= s.ThresholdBps
+	if dislocated && !s.Dislocated {
+		alert := Alert{
+			Time:           snap.Time,
+			Symbol:         snap.Symbol,
+			DivergenceBps:  snap.DivergenceBps,
+			ThresholdBps:   s.ThresholdBps,
+			HighVenue:      snap.HighVenue,
+			LowVenue:       snap.LowVenue,
+			CrossSpreadBps: snap.CrossSpreadBps,
+		}
+		s.Alerts = append(s.Alerts, alert)
+		if len(s.Alerts) > maxAlerts {
+			s.Alerts = s.Alerts[len(s.Alerts)-maxAlerts:]
+		}
+		metrics.CountSpreadAlert(snap.Symbol)
+		mgr.logger.Warn().
+			Str("spread_id", s.ID).
+			Str("symbol", snap.Symbol).
+			Float64("divergence_bps", snap.DivergenceBps).
+			Float64("threshold_bps", s.ThresholdBps).
+			Str("high_venue", snap.HighVenue).
+			Str("low_venue", snap.LowVenue).
+			Float64("cross_spread_bps", snap.CrossSpreadBps).
+			Msg("Cross-exchange divergence above threshold")
+	}
+	s.Dislocated = dislocated
+}
+
+// copyLocked returns the status with its own alert slice; callers hold mgr.mu
+func (m *monitor) copyLocked() Status {
+	s := m.status
+	s.Alerts = slices.Clone(s.Alerts)
+	return s
+}
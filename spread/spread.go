@@ -0,0 +1,128 @@
+package spread
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/songzhibin97/CryptoPulse/exchange"
+)
+
+// Quote is one venue's prices for a symbol
+type Quote struct {
+	Venue     string  `json:"venue"`
+	BestBid   float64 `json:"best_bid"`
+	BestAsk   float64 `json:"best_ask"`
+	Mid       float64 `json:"mid"`
+	SpreadBps float64 `json:"spread_bps"`
+	// BuyPrice and SellPrice are the average prices of buying and selling
+	// the monitor's size at market, walking the fetched levels
+	BuyPrice  float64 `json:"buy_price"`
+	SellPrice float64 `json:"sell_price"`
+	// ExecSpreadBps is the gap between BuyPrice and SellPrice over the mid
+	ExecSpreadBps float64 `json:"exec_spread_bps"`
+	// Filled is false when the fetched levels could not fill the size on
+	// a side, so its average price covers less than the size
+	Filled   bool   `json:"filled"`
+	BookTime int64  `json:"book_time,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Snapshot compares one round of order books of a symbol across venues
+type Snapshot struct {
+	Symbol string  `json:"symbol"`
+	Size   float64 `json:"size"`
+	Time   int64   `json:"time"`
+	Quotes []Quote `json:"quotes"`
+	// DivergenceBps is the gap between the highest and lowest mid over
+	// their mean, between HighVenue and LowVenue
+	DivergenceBps float64 `json:"divergence_bps"`
+	HighVenue     string  `json:"high_venue,omitempty"`
+	LowVenue      string  `json:"low_venue,omitempty"`
+	// BuyVenue has the cheapest executable buy of the size and SellVenue
+	// the best executable sell. CrossSpreadBps is the sell price minus the
+	// buy price over the mean mid: positive when buying on one venue and
+	// selling on the other would have paid before fees.
+	BuyVenue       string  `json:"buy_venue,omitempty"`
+	SellVenue      string  `json:"sell_venue,omitempty"`
+	CrossSpreadBps float64 `json:"cross_spread_bps"`
+}
+
+// Alert records a divergence crossing a monitor's threshold
+type Alert struct {
+	Time           int64   `json:"time"`
+	Symbol         string  `json:"symbol"`
+	DivergenceBps  float64 `json:"divergence_bps"`
+	ThresholdBps   float64 `json:"threshold_bps"`
+	HighVenue      string  `json:"high_venue"`
+	LowVenue       string  `json:"low_venue"`
+	CrossSpreadBps float64 `json:"cross_spread_bps"`
+}
+
+// Compare builds the snapshot of the books fetched for symbol. errs holds
+// the venues whose book could not be fetched; they are listed with their
+// error and left out of the cross-venue figures.
+func Compare(symbol string, size float64, books []exchange.Book, errs map[string]error) Snapshot {
+	snap := Snapshot{Symbol: symbol, Size: size, Time: time.Now().UnixMilli(), Quotes: []Quote{}}
+	var mids []float64
+	high, low := math.Inf(-1), math.Inf(1)
+	bestBuy, bestSell := math.Inf(1), math.Inf(-1)
+	for _, b := range books {
+		q := Quote{Venue: b.Venue, BestBid: b.BestBid(), BestAsk: b.BestAsk(), Mid: b.Mid(), BookTime: b.Time}
+		if q.Mid == 0 {
+			q.Error = "empty order book"
+			snap.Quotes = append(snap.Quotes, q)
+			continue
+		}
+		q.SpreadBps = bps(q.BestAsk-q.BestBid, q.Mid)
+		buy, bought := exchange.Fill(b.Asks, size)
+		sell, sold := exchange.Fill(b.Bids, size)
+		q.BuyPrice, q.SellPrice = round(buy, 8), round(sell, 8)
+		q.ExecSpreadBps = bps(buy-sell, q.Mid)
+		q.Filled = bought >= size && sold >= size
+		snap.Quotes = append(snap.Quotes, q)
+
+		mids = append(mids, q.Mid)
+		if q.Mid > high {
+			high, snap.HighVenue = q.Mid, q.Venue
+		}
+		if q.Mid < low {
+			low, snap.LowVenue = q.Mid, q.Venue
+		}
+		if buy < bestBuy {
+			bestBuy, snap.BuyVenue = buy, q.Venue
+		}
+		if sell > bestSell {
+			bestSell, snap.SellVenue = sell, q.Venue
+		}
+	}
+	for _, venue := range slices.Sorted(maps.Keys(errs)) {
+		snap.Quotes = append(snap.Quotes, Quote{Venue: venue, Error: errs[venue].Error()})
+	}
+	if len(mids) < 2 {
+		snap.HighVenue, snap.LowVenue, snap.BuyVenue, snap.SellVenue = "", "", "", ""
+		return snap
+	}
+	var sum float64
+	for _, m := range mids {
+		sum += m
+	}
+	avg := sum / float64(len(mids))
+	snap.DivergenceBps = bps(high-low, avg)
+	snap.CrossSpreadBps = bps(bestSell-bestBuy, avg)
+	return snap
+}
+
+// bps expresses diff as basis points of ref
+func bps(diff, ref float64) float64 {
+	if ref == 0 {
+		return 0
+	}
+	return round(diff/ref*1e4, 2)
+}
+
+func round(f float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(f*p) / p
+}
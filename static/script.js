@@ -6,14 +6,103 @@
 let selectedPair = '';
 let currentMonitorID = '';
 let isMonitoring = false;
+let isPaused = false;
 let chartUpdateInterval = null;
 let charts = {};
 
+// Send the selected workspace with every request and unauthenticated users
+// to the login page
+const originalFetch = window.fetch.bind(window);
+window.fetch = async (resource, options = {}) => {
+    const workspace = localStorage.getItem('workspace');
+    if (workspace) {
+        options = { ...options, headers: { ...(options.headers || {}), 'X-Workspace': workspace } };
+    }
+    const response = await originalFetch(resource, options);
+    if (response.status === 401 && window.location.pathname !== '/login') {
+        window.location.href = '/login';
+    }
+    return response;
+};
+
+// Show the logged-in user and enable logout when auth is enabled
+async function loadCurrentUser() {
+    try {
+        const response = await fetch('/api/me');
+        if (!response.ok) return;
+        const result = await response.json();
+        const userInfo = document.getElementById('user-info');
+        if (userInfo && result.auth_enabled) {
+            document.getElementById('user-name').textContent = result.principal.user_name;
+            userInfo.style.display = 'block';
+            await loadWorkspaces();
+        }
+    } catch (error) {
+        console.error('Load current user error:', error);
+    }
+}
+
+// Fill the workspace selector; switching workspace reloads the page
+async function loadWorkspaces() {
+    const select = document.getElementById('workspace-select');
+    if (!select) return;
+    const response = await fetch('/api/workspaces');
+    if (!response.ok) return;
+    const workspaces = await response.json();
+    const stored = localStorage.getItem('workspace');
+    if (stored && !workspaces.some(ws => ws.id === stored)) {
+        localStorage.removeItem('workspace');
+    }
+    select.innerHTML = '';
+    workspaces.forEach(ws => {
+        const option = document.createElement('option');
+        option.value = ws.id;
+        option.textContent = ws.name;
+        option.selected = ws.id === localStorage.getItem('workspace');
+        select.appendChild(option);
+    });
+    select.onchange = () => {
+        localStorage.setItem('workspace', select.value);
+        window.location.reload();
+    };
+}
+
+// Fill the watchlist selector; choosing one monitors the whole basket
+async function loadWatchlists() {
+    const select = document.getElementById('watchlist');
+    if (!select) return;
+    try {
+        const response = await fetch('/api/watchlists');
+        if (!response.ok) return;
+        const watchlists = await response.json();
+        watchlists.forEach(w => {
+            const option = document.createElement('option');
+            option.value = w.id;
+            option.textContent = `${w.name} (${w.symbols.length})`;
+            select.appendChild(option);
+        });
+    } catch (error) {
+        console.error('Load watchlists error:', error);
+    }
+}
+
+// Log out and return to the login page
+async function logout() {
+    try {
+        await fetch('/api/logout', { method: 'POST' });
+    } catch (error) {
+        console.error('Logout error:', error);
+    }
+    localStorage.removeItem('workspace');
+    window.location.href = '/login';
+}
+
 // Initialize application state
 function initializeState() {
     selectedPair = '';
     currentMonitorID = '';
     isMonitoring = false;
+    isPaused = false;
     if (chartUpdateInterval) {
         clearInterval(chartUpdateInterval);
         chartUpdateInterval = null;
@@ -27,6 +116,11 @@ function initializeState() {
     if (monitorId) monitorId.textContent = 'None';
     const stopMonitorBtn = document.getElementById('stop-monitor');
     if (stopMonitorBtn) stopMonitorBtn.disabled = true;
+    const pauseMonitorBtn = document.getElementById('pause-monitor');
+    if (pauseMonitorBtn) {
+        pauseMonitorBtn.disabled = true;
+        pauseMonitorBtn.textContent = 'Pause Monitor';
+    }
     const selectedPairSpan = document.getElementById('selected-pair');
     if (selectedPairSpan) selectedPairSpan.textContent = 'None';
     const chartsContainer = document.getElementById('charts');
@@ -79,8 +173,9 @@ async function searchPairs() {
 
 // Start monitoring
 async function startMonitor() {
-    if (!selectedPair) {
-        alert('Please select a trading pair!');
+    const watchlistID = document.getElementById('watchlist')?.value || '';
+    if (!selectedPair && !watchlistID) {
+        alert('Please select a trading pair or a watchlist!');
         return;
     }
     const intervals = Array.from(document.getElementById('intervals')?.selectedOptions || []).map(o => o.value);
@@ -94,7 +189,14 @@ async function startMonitor() {
         return;
     }
 
-    const payload = { symbol: selectedPair, intervals, cycle };
+    const align = document.getElementById('align')?.value || '';
+    if (align && !intervals.includes(align)) {
+        alert('The candle close interval must be one of the selected intervals!');
+        return;
+    }
+
+    const payload = watchlistID ? { watchlist_id: watchlistID, intervals, cycle } : { symbol: selectedPair, intervals, cycle };
+    if (align) payload.align = align;
     console.log('Starting monitor with payload:', payload);
 
     document.getElementById('loading').style.display = 'inline';
@@ -121,10 +223,15 @@ async function startMonitor() {
         document.getElementById('monitor-status').style.display = 'block';
         document.getElementById('chart-status').textContent = 'Monitoring active, updating charts...';
         document.getElementById('stop-monitor').disabled = false;
-        if (result.chart_data) {
-            plotCharts(result.chart_data);
+        document.getElementById('pause-monitor').disabled = false;
+        if (watchlistID) {
+            subscribeSnapshots();
+        } else {
+            if (result.chart_data) {
+                plotCharts(result.chart_data);
+            }
+            subscribeChartUpdates();
         }
-        subscribeChartUpdates();
         await fetchPrompt();
     } catch (error) {
         console.error('Start monitor error:', error);
@@ -166,6 +273,33 @@ async function stopMonitor() {
     }
 }
 
+// Pause or resume the current monitor
+async function togglePauseMonitor() {
+    if (!currentMonitorID) {
+        alert('No active monitor!');
+        return;
+    }
+    const action = isPaused ? 'resume' : 'pause';
+    document.getElementById('loading').style.display = 'inline';
+    try {
+        const response = await fetch(`/api/monitors/${currentMonitorID}/${action}`, { method: 'POST' });
+        if (!response.ok) {
+            const errorText = await response.text();
+            throw new Error(`API error: ${response.status} ${errorText}`);
+        }
+        const result = await response.json();
+        console.log(`Monitor ${action}d:`, result);
+        isPaused = result.status === 'paused';
+        document.getElementById('pause-monitor').textContent = isPaused ? 'Resume Monitor' : 'Pause Monitor';
+        document.getElementById('chart-status').textContent = isPaused ? 'Monitoring paused' : 'Monitoring active, updating charts...';
+    } catch (error) {
+        console.error(`${action} monitor error:`, error);
+        alert(`Failed to ${action} monitor: ${error.message}`);
+    } finally {
+        document.getElementById('loading').style.display = 'none';
+    }
+}
+
 // Fetch AI prompt (overridden in index.html)
 async function fetchPrompt() {
     console.log('fetchPrompt is overridden in index.html');
@@ -214,13 +348,16 @@ async function submitResponse() {
     }
 }
 
-// Download report
-async function downloadReport(reportID) {
-    console.log('Downloading report:', reportID);
+// File name suffixes of the report export formats
+const reportExtensions = { json: '.json', markdown: '.md', html: '.html', 'levels.csv': '-levels.csv', 'alerts.csv': '-alerts.csv' };
+
+// Download report, as JSON or rendered in another export format
+async function downloadReport(reportID, format = 'json') {
+    console.log('Downloading report:', reportID, format);
     try {
         const controller = new AbortController();
         const timeoutId = setTimeout(() => controller.abort(), 5000);
-        const response = await fetch(`/api/report?report_id=${encodeURIComponent(reportID)}`, {
+        const response = await fetch(`/api/report?report_id=${encodeURIComponent(reportID)}&format=${encodeURIComponent(format)}`, {
             signal: controller.signal
         });
         clearTimeout(timeoutId);
@@ -232,7 +369,7 @@ async function downloadReport(reportID) {
         const url = window.URL.createObjectURL(blob);
         const a = document.createElement('a');
         a.href = url;
-        a.download = `report-${reportID}.json`;
+        a.download = `report-${reportID}${reportExtensions[format] || ''}`;
         document.body.appendChild(a);
         a.click();
         document.body.removeChild(a);
@@ -301,6 +438,319 @@ function subscribeChartUpdates() {
     }, intervalMs);
 }
 
+// Poll the basket monitor's per-symbol snapshots
+function subscribeSnapshots() {
+    if (chartUpdateInterval) {
+        clearInterval(chartUpdateInterval);
+    }
+    const update = async () => {
+        if (!isMonitoring) {
+            clearInterval(chartUpdateInterval);
+            chartUpdateInterval = null;
+            return;
+        }
+        try {
+            const response = await fetch(`/api/monitors/${currentMonitorID}/snapshots`);
+            if (!response.ok) {
+                const errorText = await response.text();
+                throw new Error(`API error: ${response.status} ${errorText}`);
+            }
+            const data = await response.json();
+            renderSnapshots(data.snapshots || []);
+        } catch (error) {
+            console.error('Snapshot update error:', error);
+        }
+    };
+    update();
+    chartUpdateInterval = setInterval(update, 30000);
+}
+
+// Render basket snapshots as a table
+function renderSnapshots(snapshots) {
+    const chartsContainer = document.getElementById('charts');
+    if (!chartsContainer) return;
+    const rows = snapshots.map(s => {
+        const changes = Object.entries(s.changes || {}).map(([interval, pct]) => `${interval}: ${pct.toFixed(2)}%`).join(' ');
+        return `<tr><td>${s.symbol}</td><td>${s.last_price}</td><td>${s.change_pct_24h.toFixed(2)}%</td>` +
+            `<td>${Math.round(s.quote_volume_24h)}</td><td>${changes}</td><td>${s.error || ''}</td></tr>`;
+    }).join('');
+    chartsContainer.innerHTML = '<table><thead><tr><th>Symbol</th><th>Last</th><th>24h</th><th>Quote Volume</th>' +
+        `<th>Changes</th><th>Error</th></tr></thead><tbody>${rows}</tbody></table>`;
+}
+
+// Plot the correlation heatmap and relative strength of the selected watchlist
+async function loadCorrelation() {
+    const container = document.getElementById('correlation');
+    const watchlistID = document.getElementById('watchlist')?.value;
+    if (!container) return;
+    if (!watchlistID) {
+        alert('Please select a watchlist!');
+        return;
+    }
+    const interval = document.getElementById('intervals')?.selectedOptions[0]?.value || '1h';
+    try {
+        const response = await fetch(`/api/watchlists/${watchlistID}/correlation?interval=${interval}`);
+        if (!response.ok) {
+            const errorText = await response.text();
+            throw new Error(`API error: ${response.status} ${errorText}`);
+        }
+        const data = await response.json();
+        const m = data.correlation;
+        container.innerHTML = '<div id="correlation-heatmap" style="height:400px"></div><div id="correlation-ranking"></div>';
+        Plotly.newPlot('correlation-heatmap', [{
+            type: 'heatmap',
+            x: m.symbols,
+            y: m.symbols,
+            z: m.matrix,
+            zmin: -1,
+            zmax: 1,
+            colorscale: 'RdBu',
+            reversescale: true
+        }], {
+            title: `${data.watchlist} ${m.interval} correlation (last ${m.window} returns, ${data.source})`
+        });
+        const rows = (m.ranking || []).map(r => `<tr><td>${r.rank}</td><td>${r.symbol}</td><td>${r.return_pct}%</td>` +
+            `<td>${r.excess_pct}%</td><td>${r.correlation}</td><td>${r.beta}</td><td>${r.avg_correlation}</td></tr>`).join('');
+        document.getElementById('correlation-ranking').innerHTML = '<table><thead><tr><th>Rank</th><th>Symbol</th><th>Return</th>' +
+            `<th>vs ${m.benchmark}</th><th>Corr</th><th>Beta</th><th>Avg Corr</th></tr></thead><tbody>${rows}</tbody></table>`;
+    } catch (error) {
+        console.error('Load correlation error:', error);
+        alert(`Failed to load correlation: ${error.message}`);
+    }
+}
+
+// Show the ranked hits of the last market scan
+async function loadScanner() {
+    const container = document.getElementById('scanner-results');
+    const status = document.getElementById('scanner-status');
+    if (!container || !status) return;
+    try {
+        const response = await fetch('/api/scanner?limit=50');
+        if (!response.ok) {
+            const errorText = await response.text();
+            throw new Error(`API error: ${response.status} ${errorText}`);
+        }
+        const data = await response.json();
+        const result = data.result;
+        if (!result) {
+            status.textContent = data.running ? 'Scanning...' : (data.enabled ? 'No scan yet' : 'Scanner disabled');
+            container.innerHTML = '';
+            return;
+        }
+        status.textContent = `${data.running ? 'Scanning... ' : ''}Last scan ${new Date(result.finished_at * 1000).toLocaleString()}: ` +
+            `${result.hits.length} hits of ${result.evaluated} pairs${result.error ? ` (error: ${result.error})` : ''}`;
+        const rows = result.hits.map(h => {
+            const signals = h.signals.map(s => `${s.type} ${s.value}`).join(', ');
+            return `<tr><td>${h.symbol}</td><td>${h.score}</td><td>${h.last_price}</td><td>${h.change_pct_24h.toFixed(2)}%</td>` +
+                `<td>${Math.round(h.quote_volume_24h)}</td><td>${h.rsi || ''}</td><td>${signals}</td>` +
+                `<td><button data-symbol="${h.symbol}">Monitor</button></td></tr>`;
+        }).join('');
+        container.innerHTML = '<table><thead><tr><th>Symbol</th><th>Score</th><th>Last</th><th>24h</th><th>Quote Volume</th>' +
+            `<th>RSI</th><th>Signals</th><th></th></tr></thead><tbody>${rows}</tbody></table>`;
+        container.querySelectorAll('button[data-symbol]').forEach(btn => {
+            btn.onclick = () => monitorHit(btn.dataset.symbol);
+        });
+    } catch (error) {
+        console.error('Load scanner error:', error);
+        status.textContent = `Failed to load scanner: ${error.message}`;
+    }
+}
+
+// Trigger a market scan now
+async function runScanner() {
+    try {
+        const response = await fetch('/api/scanner/run', { method: 'POST' });
+        if (!response.ok) {
+            const errorText = await response.text();
+            throw new Error(`API error: ${response.status} ${errorText}`);
+        }
+        document.getElementById('scanner-status').textContent = 'Scanning...';
+    } catch (error) {
+        console.error('Run scanner error:', error);
+        alert(`Failed to start scan: ${error.message}`);
+    }
+}
+
+// Start a monitor for a scanner hit with the selected intervals, or 1h and 4h
+async function monitorHit(symbol) {
+    if (isMonitoring) {
+        alert('Stop the current monitor first!');
+        return;
+    }
+    selectedPair = symbol;
+    document.getElementById('selected-pair').textContent = symbol;
+    const watchlist = document.getElementById('watchlist');
+    if (watchlist) watchlist.value = '';
+    const intervals = document.getElementById('intervals');
+    if (intervals && intervals.selectedOptions.length === 0) {
+        Array.from(intervals.options).forEach(o => { o.selected = o.value === '1h' || o.value === '4h'; });
+    }
+    await startMonitor();
+}
+
+// Start a cross-exchange spread monitor of the selected pair
+async function startSpreadMonitor() {
+    if (!selectedPair) {
+        alert('Please select a trading pair!');
+        return;
+    }
+    const size = parseFloat(document.getElementById('spread-size').value);
+    const threshold = parseFloat(document.getElementById('spread-threshold').value) || 0;
+    try {
+        const response = await fetch('/api/spreads', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ symbol: selectedPair, size: size, threshold_bps: threshold })
+        });
+        if (!response.ok) {
+            const errorText = await response.text();
+            throw new Error(`API error: ${response.status} ${errorText}`);
+        }
+        loadSpreadMonitors();
+    } catch (error) {
+        console.error('Start spread monitor error:', error);
+        alert(`Failed to start spread monitor: ${error.message}`);
+    }
+}
+
+// Stop a spread monitor
+async function stopSpreadMonitor(id) {
+    try {
+        const response = await fetch(`/api/spreads/${id}`, { method: 'DELETE' });
+        if (!response.ok) {
+            const errorText = await response.text();
+            throw new Error(`API error: ${response.status} ${errorText}`);
+        }
+        loadSpreadMonitors();
+    } catch (error) {
+        console.error('Stop spread monitor error:', error);
+        alert(`Failed to stop spread monitor: ${error.message}`);
+    }
+}
+
+// Show the spread monitors of the workspace with their latest comparison
+async function loadSpreadMonitors() {
+    const container = document.getElementById('spread-monitors');
+    if (!container) return;
+    try {
+        const response = await fetch('/api/spreads');
+        if (!response.ok) return;
+        const monitors = await response.json();
+        const rows = monitors.map(m => {
+            const last = m.last || {};
+            const quotes = (last.quotes || []).map(q => q.error ? `${q.venue}: ${q.error}` :
+                `${q.venue}: ${q.best_bid}/${q.best_ask} exec ${q.exec_spread_bps}bp${q.filled ? '' : ' (partial)'}`).join('<br>');
+            const lastAlert = m.alerts.length ? new Date(m.alerts[m.alerts.length - 1].time).toLocaleString() : '';
+            return `<tr><td>${m.symbol}</td><td>${m.size}</td><td>${quotes}</td>` +
+                `<td>${last.divergence_bps ?? ''}bp ${last.high_venue || ''}/${last.low_venue || ''}</td>` +
+                `<td>${last.cross_spread_bps ?? ''}bp</td><td>${m.dislocated ? 'ALERT' : ''} ${lastAlert}</td>` +
+                `<td><button data-spread="${m.id}">Stop</button></td></tr>`;
+        }).join('');
+        container.innerHTML = monitors.length === 0 ? '' : '<table><thead><tr><th>Symbol</th><th>Size</th><th>Venues</th>' +
+            `<th>Divergence</th><th>Cross Spread</th><th>Last Alert</th><th></th></tr></thead><tbody>${rows}</tbody></table>`;
+        container.querySelectorAll('button[data-spread]').forEach(btn => {
+            btn.onclick = () => stopSpreadMonitor(btn.dataset.spread);
+        });
+    } catch (error) {
+        console.error('Load spread monitors error:', error);
+    }
+}
+
+// Send a simulated market order for the quote amount of the selected pair
+async function placePaperOrder(side) {
+    if (!selectedPair) {
+        alert('Please select a trading pair!');
+        return;
+    }
+    const amount = parseFloat(document.getElementById('paper-qty').value);
+    try {
+        const response = await fetch('/api/paper/orders', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ symbol: selectedPair, side: side, quote_qty: amount })
+        });
+        if (!response.ok) {
+            const errorText = await response.text();
+            throw new Error(`API error: ${response.status} ${errorText}`);
+        }
+        loadPaperAccount();
+    } catch (error) {
+        console.error('Paper order error:', error);
+        alert(`Paper order failed: ${error.message}`);
+    }
+}
+
+// Turn trading on report signals on or off, with the order amount as notional
+async function togglePaperAutoTrade() {
+    const enabled = document.getElementById('paper-auto').checked;
+    const notional = parseFloat(document.getElementById('paper-qty').value) || 0;
+    try {
+        const current = await (await fetch('/api/paper/account')).json();
+        const settings = current.settings;
+        settings.auto_trade.enabled = enabled;
+        if (enabled) settings.auto_trade.notional = notional;
+        const response = await fetch('/api/paper/account', {
+            method: 'PUT',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(settings)
+        });
+        if (!response.ok) {
+            const errorText = await response.text();
+            throw new Error(`API error: ${response.status} ${errorText}`);
+        }
+        loadPaperAccount();
+    } catch (error) {
+        console.error('Paper settings error:', error);
+        alert(`Failed to update paper account: ${error.message}`);
+    }
+}
+
+// Reset the paper account to the configured starting balance
+async function resetPaperAccount() {
+    if (!confirm('Reset the paper account? Positions and history are dropped.')) return;
+    try {
+        const response = await fetch('/api/paper/reset', { method: 'POST' });
+        if (!response.ok) {
+            const errorText = await response.text();
+            throw new Error(`API error: ${response.status} ${errorText}`);
+        }
+        loadPaperAccount();
+    } catch (error) {
+        console.error('Paper reset error:', error);
+        alert(`Failed to reset paper account: ${error.message}`);
+    }
+}
+
+// Show the workspace's paper account, its positions and equity curve
+async function loadPaperAccount() {
+    const container = document.getElementById('paper-account');
+    if (!container) return;
+    try {
+        const response = await fetch('/api/paper/account');
+        if (!response.ok) return;
+        const account = await response.json();
+        document.getElementById('paper-auto').checked = account.settings.auto_trade.enabled;
+        const rows = account.positions.map(p =>
+            `<tr><td>${p.symbol}</td><td>${p.qty}</td><td>${p.avg_price}</td><td>${p.mark_price}</td>` +
+            `<td>${p.unrealized_pnl}</td><td>${p.realized_pnl}</td></tr>`).join('');
+        container.innerHTML = `<strong>Equity:</strong> ${account.equity} <strong>Cash:</strong> ${account.cash} ` +
+            `<strong>PnL:</strong> ${account.pnl} (${account.return_pct}%) <strong>Fees:</strong> ${account.fees}` +
+            (account.positions.length === 0 ? '' : '<table><thead><tr><th>Symbol</th><th>Qty</th><th>Avg Price</th>' +
+            `<th>Mark</th><th>Unrealized</th><th>Realized</th></tr></thead><tbody>${rows}</tbody></table>`);
+
+        const equity = await (await fetch('/api/paper/equity')).json();
+        Plotly.react('paper-equity', [{
+            x: equity.map(e => new Date(e.time)),
+            y: equity.map(e => e.equity),
+            type: 'scatter',
+            mode: 'lines',
+            name: 'Equity'
+        }], { title: 'Paper Equity', height: 300, margin: { t: 40, b: 40 } });
+    } catch (error) {
+        console.error('Load paper account error:', error);
+    }
+}
+
 // Plot charts using Plotly
 function plotCharts(data, update = false) {
     console.log('Plotting charts, update:', update);
@@ -385,6 +835,13 @@ function plotCharts(data, update = false) {
 document.addEventListener('DOMContentLoaded', () => {
     console.log('DOM loaded, initializing...');
     initializeState();
+    loadCurrentUser();
+    loadWatchlists();
+    loadScanner();
+    loadSpreadMonitors();
+    setInterval(loadSpreadMonitors, 10000);
+    loadPaperAccount();
+    setInterval(loadPaperAccount, 30000);
 
     // Bind events
     const pairSearch = document.getElementById('pair-search');
@@ -410,6 +867,56 @@ document.addEventListener('DOMContentLoaded', () => {
         console.error('Stop monitor button not found');
     }
 
+    const pauseMonitorBtn = document.getElementById('pause-monitor');
+    if (pauseMonitorBtn) {
+        pauseMonitorBtn.addEventListener('click', togglePauseMonitor);
+    }
+
+    const refreshScannerBtn = document.getElementById('refresh-scanner');
+    if (refreshScannerBtn) {
+        refreshScannerBtn.addEventListener('click', loadScanner);
+    }
+
+    const runScannerBtn = document.getElementById('run-scanner');
+    if (runScannerBtn) {
+        runScannerBtn.addEventListener('click', runScanner);
+    }
+
+    const startSpreadBtn = document.getElementById('start-spread');
+    if (startSpreadBtn) {
+        startSpreadBtn.addEventListener('click', startSpreadMonitor);
+    }
+
+    const paperBuyBtn = document.getElementById('paper-buy');
+    if (paperBuyBtn) {
+        paperBuyBtn.addEventListener('click', () => placePaperOrder('buy'));
+    }
+
+    const paperSellBtn = document.getElementById('paper-sell');
+    if (paperSellBtn) {
+        paperSellBtn.addEventListener('click', () => placePaperOrder('sell'));
+    }
+
+    const paperAuto = document.getElementById('paper-auto');
+    if (paperAuto) {
+        paperAuto.addEventListener('change', togglePaperAutoTrade);
+    }
+
+    const paperResetBtn = document.getElementById('paper-reset');
+    if (paperResetBtn) {
+        paperResetBtn.addEventListener('click', resetPaperAccount);
+    }
+
+    const correlationBtn = document.getElementById('show-correlation');
+    if (correlationBtn) {
+        correlationBtn.addEventListener('click', loadCorrelation);
+    }
+
+    const logoutBtn = document.getElementById('logout');
+    if (logoutBtn) {
+        logoutBtn.addEventListener('click', logout);
+    }
+
     const submitResponseBtn = document.getElementById('submit-response');
     if (submitResponseBtn) {
         submitResponseBtn.addEventListener('click', submitResponse);
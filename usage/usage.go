@@ -0,0 +1,290 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/CryptoPulse/config"
+)
+
+// Record is the AI usage of a single backend call chain for one analysis
+type Record struct {
+	AnalysisID       string  `json:"analysis_id"`
+	MonitorID        string  `json:"monitor_id,omitempty"`
+	OwnerID          string  `json:"owner_id,omitempty"`
+	WorkspaceID      string  `json:"workspace_id,omitempty"`
+	Symbol           string  `json:"symbol"`
+	Backend          string  `json:"backend"`
+	Provider         string  `json:"provider,omitempty"`
+	Model            string  `json:"model,omitempty"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Attempts         int     `json:"attempts"`
+	LatencyMs        int64   `json:"latency_ms"`
+	CostUSD          float64 `json:"cost_usd"`
+	Failed           bool    `json:"failed,omitempty"`
+	Timestamp        int64   `json:"timestamp"`
+}
+
+// Day returns the UTC day of the record as YYYY-MM-DD
+func (r Record) Day() string {
+	return time.UnixMilli(r.Timestamp).UTC().Format("2006-01-02")
+}
+
+// Totals aggregates a set of records
+type Totals struct {
+	Analyses         int     `json:"analyses"`
+	Failed           int     `json:"failed"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+	AvgLatencyMs     int64   `json:"avg_latency_ms"`
+	totalLatencyMs   int64
+}
+
+func (t *Totals) add(r Record) {
+	t.Analyses++
+	if r.Failed {
+		t.Failed++
+	}
+	t.PromptTokens += r.PromptTokens
+	t.CompletionTokens += r.CompletionTokens
+	t.CostUSD += r.CostUSD
+	t.totalLatencyMs += r.LatencyMs
+	t.AvgLatencyMs = t.totalLatencyMs / int64(t.Analyses)
+}
+
+// Breakdown is the totals for one monitor, model or day
+type Breakdown struct {
+	Key string `json:"key"`
+	Totals
+}
+
+// Filter selects records for a summary; empty fields match everything
+type Filter struct {
+	MonitorID   string
+	OwnerID     string
+	WorkspaceID string
+	From        string // inclusive day, YYYY-MM-DD
+	To          string // inclusive day, YYYY-MM-DD
+}
+
+func (f Filter) match(r Record) bool {
+	if f.MonitorID != "" && r.MonitorID != f.MonitorID {
+		return false
+	}
+	if f.OwnerID != "" && r.OwnerID != f.OwnerID {
+		return false
+	}
+	if f.WorkspaceID != "" && r.WorkspaceID != f.WorkspaceID {
+		return false
+	}
+	day := r.Day()
+	if f.From != "" && day < f.From {
+		return false
+	}
+	if f.To != "" && day > f.To {
+		return false
+	}
+	return true
+}
+
+// Summary is the usage report served by /api/usage
+type Summary struct {
+	Total     Totals      `json:"total"`
+	ByMonitor []Breakdown `json:"by_monitor"`
+	ByModel   []Breakdown `json:"by_model"`
+	ByDay     []Breakdown `json:"by_day"`
+	Analyses  []Record    `json:"analyses,omitempty"`
+}
+
+// spend is the running usage of one monitor or workspace, in total and
+// by day, so budget checks need not scan the records
+type spend struct {
+	total Totals
+	days  map[string]*Totals
+}
+
+func (s *spend) add(r Record) {
+	s.total.add(r)
+	addTo(s.days, r.Day(), r)
+}
+
+// Tracker records AI usage and enforces per-monitor budgets. Records are
+// appended to a JSON lines file so accounting survives restarts.
+type Tracker struct {
+	path        string
+	records     []Record
+	byMonitor   map[string]*spend
+	byWorkspace map[string]*spend
+	mu          sync.RWMutex
+}
+
+// NewTracker creates a Tracker persisting to path, loading existing records
+func NewTracker(path string) (*Tracker, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	t := &Tracker{path: path, byMonitor: make(map[string]*spend), byWorkspace: make(map[string]*spend)}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("parse usage record failed: %w", err)
+		}
+		t.add(r)
+	}
+	return t, scanner.Err()
+}
+
+// add keeps a record and counts it towards its monitor and workspace
+func (t *Tracker) add(r Record) {
+	t.records = append(t.records, r)
+	addSpend(t.byMonitor, r.MonitorID, r)
+	addSpend(t.byWorkspace, r.WorkspaceID, r)
+}
+
+func addSpend(m map[string]*spend, key string, r Record) {
+	s, ok := m[key]
+	if !ok {
+		s = &spend{days: make(map[string]*Totals)}
+		m[key] = s
+	}
+	s.add(r)
+}
+
+// Record stores a usage record
+func (t *Tracker) Record(r Record) error {
+	if r.Timestamp == 0 {
+		r.Timestamp = time.Now().UnixMilli()
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	t.add(r)
+	return nil
+}
+
+// Check verifies that the usage file can be appended to
+func (t *Tracker) Check() error {
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Exceeded reports whether a monitor has used up its budget at time now,
+// with a description of the limit that was hit
+func (t *Tracker) Exceeded(monitorID string, budget config.AIBudget, now time.Time) (bool, string) {
+	return t.exceeded(t.byMonitor, monitorID, budget, now)
+}
+
+// WorkspaceExceeded reports whether a workspace's monitors together have
+// used up the workspace budget at time now
+func (t *Tracker) WorkspaceExceeded(workspaceID string, budget config.AIBudget, now time.Time) (bool, string) {
+	exceeded, reason := t.exceeded(t.byWorkspace, workspaceID, budget, now)
+	if exceeded {
+		reason = "workspace " + reason
+	}
+	return exceeded, reason
+}
+
+// exceeded checks budget against the spend under key in index
+func (t *Tracker) exceeded(index map[string]*spend, key string, budget config.AIBudget, now time.Time) (bool, string) {
+	if budget.DailyUSD <= 0 && budget.DailyTokens <= 0 && budget.TotalUSD <= 0 {
+		return false, ""
+	}
+	var day, total Totals
+	t.mu.RLock()
+	if s, ok := index[key]; ok {
+		total = s.total
+		if d, ok := s.days[now.UTC().Format("2006-01-02")]; ok {
+			day = *d
+		}
+	}
+	t.mu.RUnlock()
+
+	switch {
+	case budget.DailyUSD > 0 && day.CostUSD >= budget.DailyUSD:
+		return true, fmt.Sprintf("daily cost $%.4f reached budget $%.4f", day.CostUSD, budget.DailyUSD)
+	case budget.DailyTokens > 0 && day.PromptTokens+day.CompletionTokens >= budget.DailyTokens:
+		return true, fmt.Sprintf("daily tokens %d reached budget %d", day.PromptTokens+day.CompletionTokens, budget.DailyTokens)
+	case budget.TotalUSD > 0 && total.CostUSD >= budget.TotalUSD:
+		return true, fmt.Sprintf("total cost $%.4f reached budget $%.4f", total.CostUSD, budget.TotalUSD)
+	}
+	return false, ""
+}
+
+// Summarize aggregates the records matching filter by monitor, model and
+// day. Individual analyses are included when filtering by monitor.
+func (t *Tracker) Summarize(filter Filter) Summary {
+	var s Summary
+	byMonitor := make(map[string]*Totals)
+	byModel := make(map[string]*Totals)
+	byDay := make(map[string]*Totals)
+	t.mu.RLock()
+	for _, r := range t.records {
+		if !filter.match(r) {
+			continue
+		}
+		s.Total.add(r)
+		addTo(byMonitor, r.MonitorID, r)
+		addTo(byModel, r.Model, r)
+		addTo(byDay, r.Day(), r)
+		if filter.MonitorID != "" {
+			s.Analyses = append(s.Analyses, r)
+		}
+	}
+	t.mu.RUnlock()
+	s.ByMonitor = breakdowns(byMonitor)
+	s.ByModel = breakdowns(byModel)
+	s.ByDay = breakdowns(byDay)
+	return s
+}
+
+func addTo(m map[string]*Totals, key string, r Record) {
+	if key == "" {
+		key = "unknown"
+	}
+	t, ok := m[key]
+	if !ok {
+		t = &Totals{}
+		m[key] = t
+	}
+	t.add(r)
+}
+
+func breakdowns(m map[string]*Totals) []Breakdown {
+	out := make([]Breakdown, 0, len(m))
+	for k, t := range m {
+		out = append(out, Breakdown{Key: k, Totals: *t})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
@@ -0,0 +1,223 @@
+package watchlist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxSymbols bounds a watchlist; every symbol costs a kline request per
+// interval and cycle
+const MaxSymbols = 50
+
+var (
+	// ErrNotFound is returned for unknown watchlist IDs
+	ErrNotFound = errors.New("watchlist not found")
+	// ErrDuplicate is returned when a workspace already has a watchlist of
+	// the same name
+	ErrDuplicate = errors.New("watchlist name already in use")
+)
+
+var symbolPattern = regexp.MustCompile(`^[A-Z0-9]{2,20}$`)
+
+// Watchlist is a named basket of symbols in a workspace
+type Watchlist struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Symbols     []string `json:"symbols"`
+	OwnerID     string   `json:"owner_id,omitempty"`
+	WorkspaceID string   `json:"workspace_id"`
+	CreatedAt   int64    `json:"created_at"`
+	UpdatedAt   int64    `json:"updated_at"`
+}
+
+// Store keeps watchlists in memory and persists them to a JSON file
+type Store struct {
+	path  string
+	lists map[string]*Watchlist
+	mu    sync.RWMutex
+}
+
+// Open loads the watchlists stored at path, which need not exist yet
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	s := &Store{path: path, lists: make(map[string]*Watchlist)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lists []*Watchlist
+	if err := json.Unmarshal(data, &lists); err != nil {
+		return nil, fmt.Errorf("parse watchlist file %s: %w", path, err)
+	}
+	for _, w := range lists {
+		s.lists[w.ID] = w
+	}
+	return s, nil
+}
+
+// NormalizeSymbols upper-cases and de-duplicates symbols, keeping their
+// order, and checks them against the exchange's symbol format
+func NormalizeSymbols(symbols []string) ([]string, error) {
+	if len(symbols) == 0 {
+		return nil, errors.New("symbols are required")
+	}
+	seen := make(map[string]bool, len(symbols))
+	out := make([]string, 0, len(symbols))
+	for _, s := range symbols {
+		s = strings.ToUpper(strings.TrimSpace(s))
+		if !symbolPattern.MatchString(s) {
+			return nil, fmt.Errorf("invalid symbol %q", s)
+		}
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	if len(out) > MaxSymbols {
+		return nil, fmt.Errorf("a watchlist holds at most %d symbols", MaxSymbols)
+	}
+	return out, nil
+}
+
+// Create validates and stores a new watchlist
+func (s *Store) Create(w Watchlist) (Watchlist, error) {
+	w.Name = strings.TrimSpace(w.Name)
+	if w.Name == "" {
+		return Watchlist{}, errors.New("name is required")
+	}
+	symbols, err := NormalizeSymbols(w.Symbols)
+	if err != nil {
+		return Watchlist{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.nameTakenLocked(w.WorkspaceID, w.Name, "") {
+		return Watchlist{}, ErrDuplicate
+	}
+	now := time.Now().Unix()
+	w.ID, w.Symbols, w.CreatedAt, w.UpdatedAt = uuid.New().String(), symbols, now, now
+	s.lists[w.ID] = &w
+	if err := s.save(); err != nil {
+		delete(s.lists, w.ID)
+		return Watchlist{}, err
+	}
+	return w, nil
+}
+
+// Update renames a watchlist and/or replaces its symbols; empty arguments
+// keep the current value
+func (s *Store) Update(id, name string, symbols []string) (Watchlist, error) {
+	name = strings.TrimSpace(name)
+	if symbols != nil {
+		var err error
+		if symbols, err = NormalizeSymbols(symbols); err != nil {
+			return Watchlist{}, err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w, ok := s.lists[id]
+	if !ok {
+		return Watchlist{}, ErrNotFound
+	}
+	if name != "" && s.nameTakenLocked(w.WorkspaceID, name, id) {
+		return Watchlist{}, ErrDuplicate
+	}
+	prev := *w
+	if name != "" {
+		w.Name = name
+	}
+	if symbols != nil {
+		w.Symbols = symbols
+	}
+	w.UpdatedAt = time.Now().Unix()
+	if err := s.save(); err != nil {
+		*w = prev
+		return Watchlist{}, err
+	}
+	return *w, nil
+}
+
+// Delete removes a watchlist
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w, ok := s.lists[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.lists, id)
+	if err := s.save(); err != nil {
+		s.lists[id] = w
+		return err
+	}
+	return nil
+}
+
+// Get returns a watchlist by ID
+func (s *Store) Get(id string) (Watchlist, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	w, ok := s.lists[id]
+	if !ok {
+		return Watchlist{}, false
+	}
+	return *w, true
+}
+
+// List returns the watchlists of a workspace by name, or all of them when
+// workspaceID is empty
+func (s *Store) List(workspaceID string) []Watchlist {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	lists := make([]Watchlist, 0, len(s.lists))
+	for _, w := range s.lists {
+		if workspaceID == "" || w.WorkspaceID == workspaceID {
+			lists = append(lists, *w)
+		}
+	}
+	sort.Slice(lists, func(i, j int) bool { return lists[i].Name < lists[j].Name })
+	return lists
+}
+
+func (s *Store) nameTakenLocked(workspaceID, name, exceptID string) bool {
+	for _, w := range s.lists {
+		if w.ID != exceptID && w.WorkspaceID == workspaceID && strings.EqualFold(w.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// save writes all watchlists; callers hold s.mu
+func (s *Store) save() error {
+	lists := make([]*Watchlist, 0, len(s.lists))
+	for _, w := range s.lists {
+		lists = append(lists, w)
+	}
+	sort.Slice(lists, func(i, j int) bool { return lists[i].CreatedAt < lists[j].CreatedAt })
+	data, err := json.MarshalIndent(lists, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}